@@ -5,18 +5,43 @@ CREATE TABLE "public"."repositories" (
     "name" CHARACTER VARYING(200) NOT NULL,
     "status" CHARACTER VARYING(20) NOT NULL,
     "updated_at" TIMESTAMP NOT NULL,
+    "worker_id" CHARACTER VARYING(200) NULL,
+    "lease_expires_at" TIMESTAMP NULL,
+    "filters" JSONB NOT NULL DEFAULT '{}',
+    "error_msg" TEXT NULL,
+    "attempts" INTEGER NOT NULL DEFAULT 0,
+    "retry_at" TIMESTAMP WITH TIME ZONE NULL,
+    "credentials" BYTEA NULL,
+    "pull_requests" BOOLEAN NOT NULL DEFAULT FALSE,
+    "base_branch" CHARACTER VARYING(200) NOT NULL DEFAULT '',
+    "merge_preview" BOOLEAN NOT NULL DEFAULT FALSE,
+    "hook_handler_id" BIGINT NULL,
+    "sync_requested" BOOLEAN NOT NULL DEFAULT FALSE,
     PRIMARY KEY ("id")
 );
 
 CREATE TABLE "public"."branches" (
      "id" SERIAL NOT NULL,
      "repository_id" BIGINT NOT NULL,
-     "type" CHARACTER VARYING(10) NOT NULL,
+     "type" CHARACTER VARYING(20) NOT NULL,
      "name" CHARACTER VARYING(200) NOT NULL,
      "hash" CHARACTER VARYING(200) NOT NULL,
+     "base_hash" CHARACTER VARYING(200) NOT NULL DEFAULT '',
      "status" CHARACTER VARYING(20) NOT NULL,
      "error_msg" TEXT NULL,
-     PRIMARY KEY ("id")
+     "worker_id" CHARACTER VARYING(200) NULL,
+     "lease_expires_at" TIMESTAMP NULL,
+     "author" TEXT NOT NULL DEFAULT '',
+     "committed_at" TIMESTAMP WITH TIME ZONE NULL,
+     "subject" TEXT NOT NULL DEFAULT '',
+     "message" TEXT NOT NULL DEFAULT '',
+     "components" JSONB NOT NULL DEFAULT '[]',
+     "job_id" CHARACTER VARYING(200) NOT NULL DEFAULT '',
+     "progress" INTEGER NOT NULL DEFAULT 0,
+     "stage" TEXT NOT NULL DEFAULT '',
+     "retry_at" TIMESTAMP WITH TIME ZONE NULL,
+     PRIMARY KEY ("id"),
+     UNIQUE ("repository_id", "type", "name")
 );
 
 CREATE TABLE "public"."deployments" (
@@ -28,3 +53,24 @@ CREATE TABLE "public"."deployments" (
      "error_msg" TEXT NULL,
      PRIMARY KEY ("id")
 );
+
+CREATE TABLE "public"."logs" (
+     "id" BIGSERIAL NOT NULL,
+     "kind" CHARACTER VARYING(20) NOT NULL,
+     "owner_id" BIGINT NOT NULL,
+     "build" INTEGER NOT NULL,
+     "text" TEXT NOT NULL,
+     "created_at" TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+     PRIMARY KEY ("id")
+);
+
+CREATE INDEX "logs_owner_idx" ON "public"."logs" ("kind", "owner_id", "build", "id");
+
+CREATE TABLE "public"."hook_handlers" (
+     "id" SERIAL NOT NULL,
+     "name" CHARACTER VARYING(200) NOT NULL,
+     "addr" CHARACTER VARYING(200) NOT NULL,
+     "deploy" BOOLEAN NOT NULL DEFAULT FALSE,
+     PRIMARY KEY ("id"),
+     UNIQUE ("name")
+);
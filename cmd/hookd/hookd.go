@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/pkg"
+	"github.com/beldeveloper/app-lego/pkg/hookserver"
+	appos "github.com/beldeveloper/app-lego/pkg/os"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// inheritedEnv lists the variables of the hookd environment that the steps get,
+// the rest of them, e.g. HOOKD_SECRET, are never exposed to the manifests.
+var inheritedEnv = []string{"PATH", "HOME"}
+
+// newHookd creates a new instance of the hook handler.
+// The artifacts directory is optional, the branches get no artifact directories if it is empty.
+func newHookd(reposDir, artifactsDir, manifest string) hookd {
+	return hookd{reposDir: reposDir, artifactsDir: artifactsDir, manifest: manifest}
+}
+
+// hookd is the hook handler that runs the steps of the manifest from the branch working tree.
+type hookd struct {
+	reposDir     string
+	artifactsDir string
+	manifest     string
+}
+
+// BuildBranch runs the build steps in the branch working tree.
+// The branch without the manifest has nothing to build, so it is ready.
+func (h hookd) BuildBranch(ctx context.Context, req pkg.HookBuildBranchReq) (pkg.HookBuildBranchResp, error) {
+	m, ok, err := readManifest(req.Dir, h.manifest)
+	if err != nil {
+		return failedBuild(err), nil
+	}
+	if !ok {
+		req.Log(fmt.Sprintf("The branch has no %s, nothing to build\n", h.manifest))
+		return pkg.HookBuildBranchResp{Status: pkg.HookStatusReady}, nil
+	}
+	artifacts, err := h.prepareArtifacts(req.Repo.Alias, req.Branch.ID)
+	if err != nil {
+		return pkg.HookBuildBranchResp{}, err
+	}
+	env := append(m.envList(), branchEnv(req.Repo, req.Branch, req.Dir)...)
+	env = append(env,
+		"APP_LEGO_ARTIFACTS_DIR="+artifacts,
+		"APP_LEGO_COMMIT_HASH="+req.Commit.Hash,
+		"APP_LEGO_COMMIT_AUTHOR="+req.Commit.Author,
+		"APP_LEGO_COMMIT_SUBJECT="+req.Commit.Subject,
+	)
+	err = runSteps(ctx, m.Build, req.Dir, env, req.Log)
+	if err != nil {
+		return failedBuild(err), nil
+	}
+	return pkg.HookBuildBranchResp{Status: pkg.HookStatusReady}, nil
+}
+
+// Deploy runs the deploy steps of every branch of the updated deployments, the rest deployments stay ready.
+// The branches are deployed in the order of their repository aliases, the branch without the manifest is skipped.
+func (h hookd) Deploy(ctx context.Context, req pkg.HookDeployReq) (pkg.HookDeployResp, error) {
+	repos := make(map[uint64]pkg.HookRepo, len(req.Repos))
+	for _, r := range req.Repos {
+		repos[r.ID] = r
+	}
+	res := pkg.HookDeployResp{Statuses: make(map[uint64]pkg.HookDeployStatus, len(req.Deployments))}
+	for _, d := range req.Deployments {
+		if !d.Updated {
+			res.Statuses[d.ID] = pkg.HookDeployStatus{Status: pkg.HookStatusReady}
+			continue
+		}
+		id := d.ID
+		err := h.deploy(ctx, d, repos, func(text string) {
+			req.Log(id, text)
+		})
+		if err != nil {
+			msg := err.Error()
+			res.Statuses[d.ID] = pkg.HookDeployStatus{Status: pkg.HookStatusFailed, ErrorMsg: &msg}
+			continue
+		}
+		res.Statuses[d.ID] = pkg.HookDeployStatus{Status: pkg.HookStatusReady}
+	}
+	return res, nil
+}
+
+func (h hookd) deploy(ctx context.Context, d pkg.HookDeployment, repos map[uint64]pkg.HookRepo, log func(string)) error {
+	aliases := make([]string, 0, len(d.Branches))
+	for alias := range d.Branches {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		err := validAlias(alias)
+		if err != nil {
+			return err
+		}
+		b := d.Branches[alias]
+		dir := pkg.HookBranchDir(h.reposDir, alias, b.ID)
+		m, ok, err := readManifest(dir, h.manifest)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log(fmt.Sprintf("The branch %s of %s has no %s, nothing to deploy\n", b.Name, alias, h.manifest))
+			continue
+		}
+		env := append(m.envList(), branchEnv(repos[b.RepoID], b, dir)...)
+		env = append(env,
+			"APP_LEGO_ARTIFACTS_DIR="+h.artifactDir(alias, b.ID),
+			"APP_LEGO_DEPLOYMENT_ID="+fmt.Sprint(d.ID),
+		)
+		err = runSteps(ctx, m.Deploy, dir, env, log)
+		if err != nil {
+			return fmt.Errorf("%s: %w", alias, err)
+		}
+	}
+	return nil
+}
+
+// CleanBranches removes the artifact directories of the deleted branches.
+func (h hookd) CleanBranches(ctx context.Context, repo pkg.HookRepo, ids []uint64) error {
+	if h.artifactsDir == "" {
+		return nil
+	}
+	err := validAlias(repo.Alias)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		err = appos.RemoveDir(h.artifactDir(repo.Alias, id))
+		if err != nil {
+			return fmt.Errorf("hookd.CleanBranches -> %w; repository=%d; branch=%d", err, repo.ID, id)
+		}
+	}
+	return nil
+}
+
+// prepareArtifacts creates the empty artifact directory of the branch, the previous build artifacts are removed.
+func (h hookd) prepareArtifacts(alias string, branchID uint64) (string, error) {
+	if h.artifactsDir == "" {
+		return "", nil
+	}
+	err := validAlias(alias)
+	if err != nil {
+		return "", err
+	}
+	dir := h.artifactDir(alias, branchID)
+	err = os.MkdirAll(filepath.Dir(dir), 0755)
+	if err != nil {
+		return "", fmt.Errorf("hookd.prepareArtifacts -> cannot create: %w; dir=%s", err, filepath.Dir(dir))
+	}
+	err = appos.RecreateDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("hookd.prepareArtifacts -> %w", err)
+	}
+	return dir, nil
+}
+
+// artifactDir returns the artifact directory of the branch, it is empty if the artifacts are not kept.
+func (h hookd) artifactDir(alias string, branchID uint64) string {
+	if h.artifactsDir == "" {
+		return ""
+	}
+	return filepath.Join(h.artifactsDir, alias, fmt.Sprint(branchID))
+}
+
+// runSteps runs the steps one by one until the first failure, the output is sent to the log.
+// The steps get the inherited variables along with the given ones only.
+func runSteps(ctx context.Context, steps []Step, dir string, env []string, log func(string)) error {
+	env = append(stepEnv(), env...)
+	for _, s := range steps {
+		log(fmt.Sprintf("==> %s\n", s.Title()))
+		_, err := appos.Exec(ctx, appos.Cmd{
+			Name:     "sh",
+			Args:     []string{"-c", s.Run},
+			Env:      env,
+			Isolated: true,
+			Dir:      filepath.Join(dir, s.Dir),
+			Log:      true,
+			Output:   hookserver.LogWriter(log),
+		})
+		if err != nil {
+			return fmt.Errorf("the step %q failed: %w", s.Title(), err)
+		}
+	}
+	return nil
+}
+
+// stepEnv returns the inherited variables that are set in the hookd environment.
+func stepEnv() []string {
+	env := make([]string, 0, len(inheritedEnv))
+	for _, name := range inheritedEnv {
+		if v, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+v)
+		}
+	}
+	return env
+}
+
+// branchEnv returns the environment variables that describe the branch.
+func branchEnv(r pkg.HookRepo, b pkg.HookBranch, dir string) []string {
+	return []string{
+		"APP_LEGO_REPO_ID=" + fmt.Sprint(r.ID),
+		"APP_LEGO_REPO_TYPE=" + r.Type,
+		"APP_LEGO_REPO_ALIAS=" + r.Alias,
+		"APP_LEGO_BRANCH_ID=" + fmt.Sprint(b.ID),
+		"APP_LEGO_BRANCH_TYPE=" + b.Type,
+		"APP_LEGO_BRANCH_NAME=" + b.Name,
+		"APP_LEGO_BRANCH_HASH=" + b.Hash,
+		"APP_LEGO_BRANCH_BASE_HASH=" + b.BaseHash,
+		"APP_LEGO_BRANCH_DIR=" + dir,
+	}
+}
+
+// validAlias checks that the repository alias can't point the artifact directory outside the artifacts one.
+func validAlias(alias string) error {
+	if alias == "" || alias == "." || alias == ".." || strings.ContainsAny(alias, `/\`) {
+		return fmt.Errorf("hookd -> invalid repository alias: %q", alias)
+	}
+	return nil
+}
+
+func failedBuild(err error) pkg.HookBuildBranchResp {
+	msg := err.Error()
+	return pkg.HookBuildBranchResp{Status: pkg.HookStatusFailed, ErrorMsg: &msg}
+}
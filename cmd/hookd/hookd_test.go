@@ -0,0 +1,75 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestStepEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "inherited",
+			env:  map[string]string{"PATH": "/usr/bin", "HOME": "/home/hookd"},
+			want: []string{"PATH=/usr/bin", "HOME=/home/hookd"},
+		},
+		{
+			name: "secrets",
+			env:  map[string]string{"PATH": "/usr/bin", "HOOKD_SECRET": "secret", "AWS_SECRET_ACCESS_KEY": "secret"},
+			want: []string{"PATH=/usr/bin"},
+		},
+		{
+			name: "empty value",
+			env:  map[string]string{"PATH": "/usr/bin", "HOME": ""},
+			want: []string{"PATH=/usr/bin", "HOME="},
+		},
+		{
+			name: "nothing inherited",
+			env:  map[string]string{"HOOKD_SECRET": "secret"},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range append(inheritedEnv, "HOOKD_SECRET", "AWS_SECRET_ACCESS_KEY") {
+				t.Setenv(name, "")
+				os.Unsetenv(name)
+			}
+			for name, v := range tt.env {
+				t.Setenv(name, v)
+			}
+			if env := stepEnv(); !reflect.DeepEqual(env, tt.want) {
+				t.Errorf("stepEnv = %q, want %q", env, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidAlias(t *testing.T) {
+	tests := []struct {
+		alias string
+		valid bool
+	}{
+		{alias: "shop", valid: true},
+		{alias: "shop.v2", valid: true},
+		{alias: "..shop", valid: true},
+		{alias: ""},
+		{alias: "."},
+		{alias: ".."},
+		{alias: "../shop"},
+		{alias: "shop/api"},
+		{alias: "/etc"},
+		{alias: `..\shop`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			if err := validAlias(tt.alias); (err == nil) != tt.valid {
+				t.Errorf("validAlias(%q) = %v, want valid %v", tt.alias, err, tt.valid)
+			}
+		})
+	}
+}
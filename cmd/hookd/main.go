@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/pkg/grpcauth"
+	"github.com/beldeveloper/app-lego/pkg/hookserver"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultManifest defines the name of the manifest file in the branch working tree.
+const DefaultManifest = ".app-lego.yml"
+
+func main() {
+	reposDir := os.Getenv("HOOKD_REPOS_DIR")
+	if reposDir == "" {
+		log.Fatal("main: HOOKD_REPOS_DIR is not set\n")
+	}
+	srv, err := hookserver.New(
+		newHookd(reposDir, os.Getenv("HOOKD_ARTIFACTS_DIR"), envString("HOOKD_MANIFEST", DefaultManifest)),
+		hookserver.Config{
+			Addr: envString("HOOKD_ADDR", ":9000"),
+			TLS: grpcauth.TLSConfig{
+				CAFile:   os.Getenv("HOOKD_TLS_CA"),
+				CertFile: os.Getenv("HOOKD_TLS_CRT"),
+				KeyFile:  os.Getenv("HOOKD_TLS_KEY"),
+			},
+			Verifier: grpcauth.Verifier{
+				Secret: os.Getenv("HOOKD_SECRET"),
+				Names:  envList("HOOKD_CLIENT_NAMES"),
+			},
+			ShutdownTimeout: envDuration("HOOKD_SHUTDOWN_TIMEOUT", hookserver.DefaultShutdownTimeout),
+		},
+	)
+	if err != nil {
+		log.Fatalf("main: %v\n", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	err = srv.Run(ctx)
+	if err != nil {
+		log.Fatalf("main: %v\n", err)
+	}
+}
+
+func envString(name, def string) string {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// envList splits the comma separated list.
+func envList(name string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
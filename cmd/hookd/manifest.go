@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Manifest describes the steps of the repository, it is read from the branch working tree.
+type Manifest struct {
+	// Env is passed to every step along with the request data.
+	Env    map[string]string `yaml:"env"`
+	Build  []Step            `yaml:"build"`
+	Deploy []Step            `yaml:"deploy"`
+}
+
+// Step is a shell command that runs in the branch working tree or in its subdirectory.
+type Step struct {
+	Name string `yaml:"name"`
+	Run  string `yaml:"run"`
+	Dir  string `yaml:"dir"`
+}
+
+// Title returns the name of the step for the output, it is the command itself if the name is not set.
+func (s Step) Title() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Run
+}
+
+// readManifest reads the manifest of the branch, it is false if the branch has no manifest.
+func readManifest(branchDir, name string) (Manifest, bool, error) {
+	var m Manifest
+	path := filepath.Join(branchDir, name)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, false, nil
+	}
+	if err != nil {
+		return m, false, fmt.Errorf("readManifest -> cannot read: %w; file=%s", err, path)
+	}
+	err = yaml.Unmarshal(data, &m)
+	if err != nil {
+		return m, false, fmt.Errorf("readManifest -> invalid manifest: %w; file=%s", err, path)
+	}
+	for section, steps := range map[string][]Step{"build": m.Build, "deploy": m.Deploy} {
+		for i, s := range steps {
+			if strings.TrimSpace(s.Run) == "" {
+				return m, false, fmt.Errorf("readManifest -> the %s step #%d has no command; file=%s", section, i+1, path)
+			}
+			dir := filepath.Clean(s.Dir)
+			if filepath.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+				return m, false, fmt.Errorf("readManifest -> the %s step dir must be inside the branch: %s; file=%s", section, s.Dir, path)
+			}
+		}
+	}
+	return m, true, nil
+}
+
+// envList converts the manifest environment to the list, the names are sorted for the stable order.
+func (m Manifest) envList() []string {
+	names := make([]string, 0, len(m.Env))
+	for k := range m.Env {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	list := make([]string, len(names))
+	for i, k := range names {
+		list[i] = k + "=" + m.Env[k]
+	}
+	return list
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadManifest(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		found    bool
+		err      string
+	}{
+		{name: "missing"},
+		{
+			name:     "valid",
+			manifest: "env:\n  MODE: test\nbuild:\n  - run: make\n  - run: npm ci\n    dir: web\ndeploy:\n  - run: ./deploy.sh\n",
+			found:    true,
+		},
+		{name: "nested dir", manifest: "build:\n  - run: make\n    dir: web/app/../api\n", found: true},
+		{name: "dir named with dots", manifest: "build:\n  - run: make\n    dir: ..web\n", found: true},
+		{name: "current dir", manifest: "build:\n  - run: make\n    dir: .\n", found: true},
+		{name: "invalid yaml", manifest: "build: [", err: "invalid manifest"},
+		{name: "no command", manifest: "build:\n  - name: build\n    run: ' '\n", err: "the build step #1 has no command"},
+		{name: "absolute dir", manifest: "deploy:\n  - run: ls\n    dir: /etc\n", err: "the deploy step dir must be inside the branch"},
+		{name: "parent dir", manifest: "build:\n  - run: ls\n    dir: ..\n", err: "the build step dir must be inside the branch"},
+		{name: "parent subdir", manifest: "build:\n  - run: ls\n    dir: ../other\n", err: "the build step dir must be inside the branch"},
+		{name: "escaping subdir", manifest: "build:\n  - run: ls\n    dir: web/../../other\n", err: "the build step dir must be inside the branch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.manifest != "" {
+				err := os.WriteFile(filepath.Join(dir, ".app-lego.yml"), []byte(tt.manifest), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			_, found, err := readManifest(dir, ".app-lego.yml")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("readManifest error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readManifest: %v", err)
+			}
+			if found != tt.found {
+				t.Errorf("readManifest found = %v, want %v", found, tt.found)
+			}
+		})
+	}
+}
@@ -5,14 +5,20 @@ import (
 	"fmt"
 	"github.com/beldeveloper/app-lego/internal/app"
 	"github.com/beldeveloper/app-lego/internal/app/svc"
+	"github.com/beldeveloper/app-lego/pkg/crypto"
+	"github.com/beldeveloper/app-lego/pkg/grpcauth"
 	"github.com/beldeveloper/app-lego/rpc/hook"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/julienschmidt/httprouter"
 	"google.golang.org/grpc"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -23,21 +29,38 @@ func main() {
 	if err != nil {
 		log.Fatalf("main: %v\n", err)
 	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 	// run watcher that maintains the GIT repositories in background
-	go c.watcher.Watch()
+	watcherDone := make(chan struct{})
+	go func() {
+		c.watcher.Watch(ctx)
+		close(watcherDone)
+	}()
+	// run gRPC server that receives the reports of the builds accepted by the hook handler
+	callbackDone := make(chan struct{})
+	go func() {
+		runCallbackServer(ctx, c.callback)
+		close(callbackDone)
+	}()
 	// run http server
-	runHttpServer(c.router)
+	runHttpServer(ctx, c.router)
+	// wait for the running jobs
+	<-watcherDone
+	<-callbackDone
 }
 
 type container struct {
-	watcher svc.Watcher
-	router  *httprouter.Router
+	watcher  svc.Watcher
+	router   *httprouter.Router
+	callback hook.CallbackServer
 }
 
-func newContainer(watcher svc.Watcher, router *httprouter.Router) container {
+func newContainer(watcher svc.Watcher, router *httprouter.Router, callback hook.CallbackServer) container {
 	return container{
-		watcher: watcher,
-		router:  router,
+		watcher:  watcher,
+		router:   router,
+		callback: callback,
 	}
 }
 
@@ -45,29 +68,109 @@ func reposDir() app.ReposDir {
 	return app.ReposDir(os.Getenv("APP_LEGO_REPOS_DIR"))
 }
 
+func newVcs(reposDir app.ReposDir) app.VcsSvc {
+	git := svc.NewGit(reposDir)
+	switch backend := os.Getenv("APP_LEGO_GIT_BACKEND"); backend {
+	case "", "cli":
+	case "go-git":
+		git = svc.NewGoGit(reposDir)
+	default:
+		log.Fatalf("main.newVcs: unknown git backend: %s\n", backend)
+	}
+	backends := []app.VcsSvc{git, svc.NewHg(reposDir)}
+	if root := os.Getenv("APP_LEGO_LOCAL_ROOT"); root != "" {
+		ignore := envList("APP_LEGO_LOCAL_IGNORE")
+		for _, p := range ignore {
+			if _, err := path.Match(p, ""); err != nil {
+				log.Fatalf("main.newVcs: invalid local ignore pattern: %s\n", p)
+			}
+		}
+		backends = append(backends, svc.NewLocal(root, ignore))
+	}
+	return svc.NewVcs(backends...)
+}
+
 func newAccessKey() app.ApiAccessKey {
 	return app.ApiAccessKey(os.Getenv("APP_LEGO_ACCESS_KEY"))
 }
 
-func newWatcher(repo app.RepositorySvc, branch app.BranchSvc, deploy app.DeploymentSvc) svc.Watcher {
+func newSecretBox() app.SecretBox {
+	key := os.Getenv("APP_LEGO_SECRET_KEY")
+	if key == "" {
+		log.Print("APP_LEGO_SECRET_KEY is not set, the repository credentials can't be saved or used\n")
+	}
+	return crypto.NewBox(key)
+}
+
+func newWebhookSecret() app.WebhookSecret {
+	return app.WebhookSecret(os.Getenv("APP_LEGO_WEBHOOK_SECRET"))
+}
+
+// newCallbackAddr returns the address of the callback service that the hook handler dials,
+// it is the listening address unless the public one is set.
+func newCallbackAddr() app.CallbackAddr {
+	addr := os.Getenv("APP_LEGO_CALLBACK_PUBLIC_ADDR")
+	if addr == "" {
+		addr = os.Getenv("APP_LEGO_CALLBACK_ADDR")
+	}
+	return app.CallbackAddr(addr)
+}
+
+func newWorkerID() app.WorkerID {
+	id := os.Getenv("APP_LEGO_WORKER_ID")
+	if id != "" {
+		return app.WorkerID(id)
+	}
+	host, _ := os.Hostname()
+	return app.WorkerID(fmt.Sprintf("%s-%d", host, os.Getpid()))
+}
+
+func newWatcher(
+	repo app.RepositorySvc,
+	branch app.BranchSvc,
+	deploy app.DeploymentSvc,
+	locker app.JobLocker,
+	listener app.EventListener,
+) svc.Watcher {
+	buildWorkers, err := strconv.Atoi(os.Getenv("APP_LEGO_BUILD_WORKERS"))
+	if err != nil || buildWorkers < 1 {
+		buildWorkers = 1
+	}
+	shutdownTimeout := envDuration("APP_LEGO_SHUTDOWN_TIMEOUT", 30*time.Second)
 	return svc.NewWatcher([]app.WatcherJob{
 		{
-			Name: "downloadRepo",
-			Do:   repo.DownloadJob,
+			Name:     "downloadRepo",
+			Do:       repo.DownloadJob,
+			Prepare:  repo.Recover,
+			Interval: 5 * time.Second,
+			Eager:    true,
+			Events:   []string{app.EventRepositoryPending},
 		},
 		{
-			Name: "syncRepo",
-			Do:   repo.SyncJob,
+			Name:      "syncRepo",
+			Do:        repo.SyncJob,
+			Interval:  5 * time.Second,
+			Jitter:    time.Second,
+			Eager:     true,
+			Singleton: true,
+			Events:    []string{app.EventRepositorySync},
 		},
 		{
-			Name: "buildBranch",
-			Do:   branch.BuildJob,
+			Name:     "buildBranch",
+			Do:       branch.BuildJob,
+			Workers:  buildWorkers,
+			Interval: time.Second,
+			Eager:    true,
+			Events:   []string{app.EventBranchEnqueued},
 		},
 		{
-			Name: "watchDeploy",
-			Do:   deploy.WatchJob,
+			Name:      "watchDeploy",
+			Do:        deploy.WatchJob,
+			Interval:  5 * time.Second,
+			Singleton: true,
+			Events:    []string{app.EventDeploymentEnqueued},
 		},
-	})
+	}, locker, listener, shutdownTimeout)
 }
 
 func newPostgresConn() *pgxpool.Pool {
@@ -86,18 +189,43 @@ func newPostgresConn() *pgxpool.Pool {
 	return conn
 }
 
-func newHookConn() hook.HookClient {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
-	defer cancel()
-	addr := os.Getenv("APP_LEGO_HOOK_HANDLER_ADDR")
-	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
-	if err != nil {
-		log.Fatalf("main.newHookConn: dial: %v; addr=%s\n", err, addr)
+func newHookHandlerAddr() app.HookHandlerAddr {
+	return app.HookHandlerAddr(os.Getenv("APP_LEGO_HOOK_HANDLER_ADDR"))
+}
+
+func newHookTimeouts() app.HookTimeouts {
+	return app.HookTimeouts{
+		BuildBranch:   envDuration("APP_LEGO_HOOK_BUILD_TIMEOUT", time.Hour),
+		Deploy:        envDuration("APP_LEGO_HOOK_DEPLOY_TIMEOUT", time.Hour),
+		CleanBranches: envDuration("APP_LEGO_HOOK_CLEAN_TIMEOUT", time.Minute),
 	}
-	return hook.NewHookClient(conn)
 }
 
-func runHttpServer(router *httprouter.Router) {
+// newHookDialOptions returns the credentials of the hook handler connections.
+// The connections are encrypted if any of the TLS files is set,
+// the shared secret is sent over the unencrypted connection only if TLS is not configured at all.
+func newHookDialOptions() svc.HookDialOptions {
+	cfg := grpcauth.TLSConfig{
+		CAFile:     os.Getenv("APP_LEGO_HOOK_TLS_CA"),
+		CertFile:   os.Getenv("APP_LEGO_HOOK_TLS_CRT"),
+		KeyFile:    os.Getenv("APP_LEGO_HOOK_TLS_KEY"),
+		ServerName: os.Getenv("APP_LEGO_HOOK_TLS_SERVER_NAME"),
+	}
+	opts := svc.HookDialOptions{grpc.WithInsecure()}
+	if !cfg.Empty() {
+		creds, err := grpcauth.ClientTLS(cfg)
+		if err != nil {
+			log.Fatalf("main.newHookDialOptions: %v\n", err)
+		}
+		opts = svc.HookDialOptions{grpc.WithTransportCredentials(creds)}
+	}
+	if secret := os.Getenv("APP_LEGO_HOOK_SECRET"); secret != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(grpcauth.NewSecret(secret, !cfg.Empty())))
+	}
+	return opts
+}
+
+func runHttpServer(ctx context.Context, router *httprouter.Router) {
 	httpPort := os.Getenv("APP_LEGO_HTTP_PORT")
 	crtFile := os.Getenv("APP_LEGO_HTTPS_CRT")
 	keyFile := os.Getenv("APP_LEGO_HTTPS_KEY")
@@ -105,8 +233,6 @@ func runHttpServer(router *httprouter.Router) {
 		Addr:    ":" + httpPort,
 		Handler: router,
 	}
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		var err error
 		if len(crtFile) > 0 {
@@ -119,11 +245,70 @@ func runHttpServer(router *httprouter.Router) {
 		}
 	}()
 	log.Printf("Listening :%s for HTTP connections...\n", httpPort)
-	<-done
+	<-ctx.Done()
 	log.Print("Stopping the application...\n")
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("main.runHttpServer: server shutdown: %v\n", err)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		// the followed build logs keep their connections open until the builds are finished
+		log.Printf("main.runHttpServer: server shutdown: %v\n", err)
+		_ = srv.Close()
+	}
+}
+
+func runCallbackServer(ctx context.Context, callback hook.CallbackServer) {
+	addr := os.Getenv("APP_LEGO_CALLBACK_ADDR")
+	if addr == "" {
+		return
+	}
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Fatalf("main.runCallbackServer: listen: %v; addr=%s\n", err, addr)
+	}
+	var opts []grpc.ServerOption
+	cfg := grpcauth.TLSConfig{
+		CAFile:   os.Getenv("APP_LEGO_CALLBACK_TLS_CA"),
+		CertFile: os.Getenv("APP_LEGO_CALLBACK_TLS_CRT"),
+		KeyFile:  os.Getenv("APP_LEGO_CALLBACK_TLS_KEY"),
+	}
+	if !cfg.Empty() {
+		creds, err := grpcauth.ServerTLS(cfg)
+		if err != nil {
+			log.Fatalf("main.runCallbackServer: %v\n", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	if secret := os.Getenv("APP_LEGO_HOOK_SECRET"); secret != "" {
+		// the hook handler authenticates its reports with the same secret
+		opts = append(opts, grpc.UnaryInterceptor(grpcauth.Verifier{Secret: secret}.UnaryServerInterceptor()))
+	}
+	srv := grpc.NewServer(opts...)
+	hook.RegisterCallbackServer(srv, callback)
+	go func() {
+		err := srv.Serve(lis)
+		if err != nil {
+			log.Fatalf("main.runCallbackServer: serve grpc: %v; addr=%s\n", err, addr)
+		}
+	}()
+	log.Printf("Listening %s for gRPC callbacks...\n", addr)
+	<-ctx.Done()
+	srv.GracefulStop()
+}
+
+func envDuration(name string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(name))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+func envList(name string) []string {
+	var res []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
 	}
+	return res
 }
@@ -4,6 +4,7 @@
 package main
 
 import (
+	"github.com/beldeveloper/app-lego/internal/app/grpc"
 	"github.com/beldeveloper/app-lego/internal/app/http"
 	"github.com/beldeveloper/app-lego/internal/app/postgres"
 	"github.com/beldeveloper/app-lego/internal/app/svc"
@@ -15,19 +16,34 @@ func initializeContainer() (container, error) {
 		postgres.NewRepository,
 		postgres.NewBranch,
 		postgres.NewDeployment,
+		postgres.NewLog,
+		postgres.NewHookHandler,
+		postgres.NewJobLock,
+		postgres.NewListener,
 		svc.NewRepository,
 		svc.NewBranch,
+		svc.NewBranchRemover,
 		svc.NewDeployment,
-		svc.NewGit,
-		svc.NewHook,
+		svc.NewHookPool,
+		svc.NewHookRouter,
+		svc.NewHookHandler,
+		svc.NewLog,
 		http.NewHandler,
 		http.NewRouter,
+		grpc.NewCallback,
 		newContainer,
 		newWatcher,
+		newVcs,
 		newPostgresConn,
 		reposDir,
 		newAccessKey,
-		newHookConn,
+		newWebhookSecret,
+		newSecretBox,
+		newWorkerID,
+		newCallbackAddr,
+		newHookHandlerAddr,
+		newHookTimeouts,
+		newHookDialOptions,
 	)
 	return container{}, nil
 }
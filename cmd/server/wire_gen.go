@@ -7,6 +7,7 @@
 package main
 
 import (
+	"github.com/beldeveloper/app-lego/internal/app/grpc"
 	"github.com/beldeveloper/app-lego/internal/app/http"
 	"github.com/beldeveloper/app-lego/internal/app/postgres"
 	"github.com/beldeveloper/app-lego/internal/app/svc"
@@ -16,20 +17,35 @@ import (
 
 func initializeContainer() (container, error) {
 	appReposDir := reposDir()
-	vcsSvc := svc.NewGit(appReposDir)
-	hookClient := newHookConn()
-	hookSvc := svc.NewHook(hookClient)
+	vcsSvc := newVcs(appReposDir)
+	hookHandlerAddr := newHookHandlerAddr()
+	hookTimeouts := newHookTimeouts()
+	hookDialOptions := newHookDialOptions()
+	hookPool := svc.NewHookPool(hookHandlerAddr, hookTimeouts, hookDialOptions)
 	pool := newPostgresConn()
+	hookHandlerRepo := postgres.NewHookHandler(pool)
+	workerID := newWorkerID()
+	secretBox := newSecretBox()
+	repositoryRepo := postgres.NewRepository(pool, workerID, secretBox)
+	hookSvc := svc.NewHookRouter(hookPool, hookHandlerRepo, repositoryRepo)
+	logRepo := postgres.NewLog(pool)
+	branchRepo := postgres.NewBranch(pool, workerID)
 	deploymentRepo := postgres.NewDeployment(pool)
-	branchRepo := postgres.NewBranch(pool)
-	repositoryRepo := postgres.NewRepository(pool)
-	deploymentSvc := svc.NewDeployment(hookSvc, deploymentRepo, branchRepo, repositoryRepo)
-	branchSvc := svc.NewBranch(vcsSvc, deploymentSvc, hookSvc, branchRepo, repositoryRepo)
-	repositorySvc := svc.NewRepository(vcsSvc, branchSvc, repositoryRepo)
-	watcher := newWatcher(repositorySvc, branchSvc, deploymentSvc)
+	logSvc := svc.NewLog(logRepo, branchRepo, deploymentRepo)
+	branchRemover := svc.NewBranchRemover(vcsSvc, hookSvc, logSvc, branchRepo)
+	deploymentSvc := svc.NewDeployment(vcsSvc, hookSvc, logSvc, deploymentRepo, branchRepo, repositoryRepo, branchRemover)
+	callbackAddr := newCallbackAddr()
+	branchSvc := svc.NewBranch(vcsSvc, deploymentSvc, hookSvc, logSvc, branchRepo, repositoryRepo, branchRemover, callbackAddr)
+	repositorySvc := svc.NewRepository(vcsSvc, branchSvc, deploymentSvc, repositoryRepo, hookHandlerRepo)
+	jobLocker := postgres.NewJobLock(pool)
+	eventListener := postgres.NewListener(pool)
+	watcher := newWatcher(repositorySvc, branchSvc, deploymentSvc, jobLocker, eventListener)
+	hookHandlerSvc := svc.NewHookHandler(hookHandlerRepo, repositoryRepo, hookPool)
 	apiAccessKey := newAccessKey()
-	handler := http.NewHandler(repositorySvc, branchSvc, deploymentSvc, apiAccessKey)
+	webhookSecret := newWebhookSecret()
+	handler := http.NewHandler(repositorySvc, branchSvc, deploymentSvc, logSvc, hookHandlerSvc, apiAccessKey, webhookSecret)
 	router := http.NewRouter(handler)
-	mainContainer := newContainer(watcher, router)
+	callbackServer := grpc.NewCallback(branchSvc)
+	mainContainer := newContainer(watcher, router, callbackServer)
 	return mainContainer, nil
 }
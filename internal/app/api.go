@@ -2,3 +2,6 @@ package app
 
 // ApiAccessKey is a data type for storing the key that is intended for accessing the API, used for DI.
 type ApiAccessKey string
+
+// WebhookSecret is a data type for storing the secret that signs the VCS hosting webhooks, used for DI.
+type WebhookSecret string
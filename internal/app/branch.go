@@ -2,6 +2,7 @@ package app
 
 import (
 	"context"
+	"time"
 )
 
 const (
@@ -9,6 +10,12 @@ const (
 	BranchTypeHead = "head"
 	// BranchTypeTag defines the type for the repository tag.
 	BranchTypeTag = "tag"
+	// BranchTypePull defines the type for the pull request (GitHub) or merge request (GitLab) of the repository.
+	BranchTypePull = "pull"
+	// BranchTypePreview defines the type for the head merged onto the repository base branch.
+	BranchTypePreview = "preview"
+	// BranchTypeIntegration defines the type for the several branches of the repository merged in order.
+	BranchTypeIntegration = "integration"
 
 	// BranchStatusEnqueued defines the status that means the branch was updated and is ready to be built.
 	BranchStatusEnqueued = "enqueued"
@@ -20,36 +27,114 @@ const (
 	BranchStatusFailed = "failed"
 	// BranchStatusSkipped defines the status that means the branch shouldn't be built.
 	BranchStatusSkipped = "skipped"
+	// BranchStatusConflict defines the status that means the merge preview or integration branch can't be built
+	// because of the merge conflict.
+	BranchStatusConflict = "conflict"
+
+	// BranchSortName defines the branches order by the name.
+	BranchSortName = "name"
+	// BranchSortCommittedAt defines the branches order by the last commit time, the recent first.
+	BranchSortCommittedAt = "committedAt"
 )
 
+// AcceptedBuildTTL defines how long the build accepted by the hook handler waits for the next report,
+// the branch is built again afterwards.
+const AcceptedBuildTTL = time.Hour
+
+// CallbackAddr is a data type for storing the address of the callback service that is passed to the hook handler,
+// used for DI.
+type CallbackAddr string
+
 // Branch is a model that represents a repository branch.
 type Branch struct {
-	ID           uint64  `json:"id"`
-	RepositoryID uint64  `json:"repositoryId"`
-	Type         string  `json:"type"`
-	Name         string  `json:"name"`
-	Hash         string  `json:"hash"`
-	Status       string  `json:"status"`
-	ErrorMsg     *string `json:"errorMsg"`
+	ID           uint64 `json:"id"`
+	RepositoryID uint64 `json:"repositoryId"`
+	Type         string `json:"type"`
+	Name         string `json:"name"`
+	Hash         string `json:"hash"`
+	// BaseHash is a revision of the base branch that the merge preview is merged onto, it is empty for the rest types.
+	BaseHash    string     `json:"baseHash"`
+	Status      string     `json:"status"`
+	ErrorMsg    *string    `json:"errorMsg"`
+	Author      string     `json:"author"`
+	CommittedAt *time.Time `json:"committedAt"`
+	Subject     string     `json:"subject"`
+	Message     string     `json:"message"`
+	// Components are the branches that the integration branch merges in order, they are empty for the rest types.
+	Components []BranchComponent `json:"components,omitempty"`
+	// JobID identifies the build that the hook handler accepted and goes on with in background.
+	JobID string `json:"jobId"`
+	// Progress is the percent of the accepted build reported by the hook handler.
+	Progress int    `json:"progress"`
+	Stage    string `json:"stage"`
+	// RetryAt is the time when the enqueued branch is built again after the hook handler was unreachable.
+	RetryAt *time.Time `json:"retryAt"`
+}
+
+// BranchComponent is a snapshot of the branch that is merged into the integration branch.
+type BranchComponent struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+// BuildReport is a model that represents the progress or the result of the accepted build reported by the hook handler.
+type BuildReport struct {
+	BranchID uint64
+	JobID    string
+	Progress int
+	Stage    string
+	Log      string
+	// Status is set by the final report only.
+	Status   string
+	ErrorMsg *string
+}
+
+// SetCommit copies the metadata of the head commit to the branch.
+func (b *Branch) SetCommit(c Commit) {
+	b.Author = c.Author
+	b.CommittedAt = nil
+	if !c.CommittedAt.IsZero() {
+		committedAt := c.CommittedAt
+		b.CommittedAt = &committedAt
+	}
+	b.Subject = c.Subject
+	b.Message = c.Message
 }
 
 // BranchSvc describes the branch service.
 type BranchSvc interface {
-	List(context.Context) ([]Branch, error)
+	List(ctx context.Context, sort string) ([]Branch, error)
 	Rebuild(context.Context, uint64) error
+	Changelog(ctx context.Context, id uint64, from string) (Changelog, error)
 	Sync(ctx context.Context, r Repository) error
+	Delete(ctx context.Context, r Repository) error
+	CheckIdle(ctx context.Context, r Repository) error
+	Requeue(ctx context.Context, r Repository) error
 	BuildJob(ctx context.Context) error
+	Report(ctx context.Context, r BuildReport) error
+}
+
+// BranchRemover describes the removal of the branches along with their logs, working trees and hook handler state.
+type BranchRemover interface {
+	Remove(ctx context.Context, r Repository, branches []Branch) error
 }
 
 // BranchRepo describes interactions with the branch DB.
 type BranchRepo interface {
 	FindAll(ctx context.Context) ([]Branch, error)
+	FindSorted(ctx context.Context, sort string) ([]Branch, error)
 	FindByIDs(ctx context.Context, ids []uint64) ([]Branch, error)
 	FindByID(ctx context.Context, id uint64) (Branch, error)
 	FindByRepository(ctx context.Context, r Repository) ([]Branch, error)
-	FindEnqueued(ctx context.Context) (Branch, error)
+	ClaimEnqueued(ctx context.Context) (Branch, error)
 	Add(ctx context.Context, b Branch) (Branch, error)
 	Update(ctx context.Context, b Branch) (Branch, error)
 	UpdateStatus(ctx context.Context, b Branch) error
+	UpdateCommit(ctx context.Context, b Branch) error
 	DeleteByIDs(ctx context.Context, ids []uint64) error
+	ExtendLease(ctx context.Context, b Branch) error
+	Accept(ctx context.Context, b Branch) error
+	UpdateProgress(ctx context.Context, b Branch) error
+	Postpone(ctx context.Context, b Branch, delay time.Duration) error
 }
@@ -53,6 +53,8 @@ type DeploymentSvc interface {
 	Rebuild(context.Context, FormReDeployment) (Deployment, error)
 	RebuildWithBranch(ctx context.Context, b Branch) error
 	Close(context.Context, uint64) error
+	Changelog(ctx context.Context, id uint64) (map[string]Changelog, error)
+	CloseWithRepository(ctx context.Context, r Repository) error
 	WatchJob(ctx context.Context) error
 }
 
@@ -63,4 +65,5 @@ type DeploymentRepo interface {
 	FindByID(ctx context.Context, id uint64) (Deployment, error)
 	Add(ctx context.Context, d Deployment) (Deployment, error)
 	Update(ctx context.Context, d Deployment) (Deployment, error)
+	Requeue(ctx context.Context, d Deployment) error
 }
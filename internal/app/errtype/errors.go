@@ -9,4 +9,11 @@ var (
 	ErrBadInput = errors.New("bad input")
 	// ErrUnauthorized represents the error for the cases when the authorization is required.
 	ErrUnauthorized = errors.New("unauthorized")
+	// ErrConflict represents the error for the cases when the current state doesn't allow the operation,
+	// e.g. the branches can't be merged automatically.
+	ErrConflict = errors.New("conflict")
+	// ErrUnavailable represents the error for the cases when the external service can't be reached for now.
+	ErrUnavailable = errors.New("unavailable")
+	// ErrIdle represents the error for the cases when the background job has nothing to do.
+	ErrIdle = errors.New("idle")
 )
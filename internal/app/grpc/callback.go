@@ -0,0 +1,66 @@
+package grpc
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/app-lego/rpc/hook"
+	"github.com/beldeveloper/go-errors-context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+)
+
+// NewCallback creates a new instance of the callback service.
+func NewCallback(branchSvc app.BranchSvc) hook.CallbackServer {
+	return Callback{branchSvc: branchSvc}
+}
+
+// Callback implements the service that receives the reports of the accepted builds from the hook handler.
+type Callback struct {
+	hook.UnimplementedCallbackServer
+	branchSvc app.BranchSvc
+}
+
+// ReportBuild saves the progress or the result of the accepted build.
+// The NotFound code means the build is superseded or the branch is deleted, so the hook handler may stop it.
+// The Aborted code means the build acceptance isn't saved yet, so the report should be sent again.
+func (s Callback) ReportBuild(ctx context.Context, req *hook.BuildReport) (*hook.EmptyMsg, error) {
+	r := app.BuildReport{
+		BranchID: req.BranchId,
+		JobID:    req.JobId,
+		Progress: int(req.Progress),
+		Stage:    req.Stage,
+		Log:      req.Log,
+	}
+	if req.Result != nil {
+		r.Status = req.Result.Status
+		if req.Result.ErrorMsg != "" {
+			r.ErrorMsg = &req.Result.ErrorMsg
+		}
+	}
+	err := s.branchSvc.Report(ctx, r)
+	if err != nil {
+		return nil, rpcError(err)
+	}
+	return &hook.EmptyMsg{}, nil
+}
+
+// rpcError converts the error to the gRPC status, the unexpected errors are logged and not exposed.
+func rpcError(err error) error {
+	code := codes.Internal
+	switch true {
+	case errors.Is(err, errtype.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, errtype.ErrBadInput):
+		code = codes.InvalidArgument
+	case errors.Is(err, errtype.ErrConflict):
+		code = codes.Aborted
+	case errors.Is(err, errtype.ErrUnauthorized):
+		code = codes.Unauthenticated
+	default:
+		log.Println(err)
+		return status.Error(code, "internal error")
+	}
+	return status.Error(code, err.Error())
+}
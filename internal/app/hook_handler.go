@@ -0,0 +1,70 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// HookHandlerAddr is a data type for storing the address of the default hook handler, used for DI.
+type HookHandlerAddr string
+
+// HookTimeouts defines the deadlines of the hook handler calls, the zero timeout means no deadline, used for DI.
+type HookTimeouts struct {
+	BuildBranch   time.Duration
+	Deploy        time.Duration
+	CleanBranches time.Duration
+}
+
+// HookHandler is a model that represents the hook handler endpoint, it builds the branches of the assigned repositories.
+type HookHandler struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+	// Addr is the gRPC address of the hook handler.
+	Addr string `json:"addr"`
+	// Deploy makes the handler receive the deployments, the default handler receives them if no one does.
+	Deploy bool `json:"deploy"`
+}
+
+// HookHealth is a model that represents the connection state of the hook handler.
+type HookHealth struct {
+	// ID is 0 for the default hook handler.
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+	Addr string `json:"addr"`
+	// State is one of idle, connecting, ready, transient_failure and shutdown.
+	State   string `json:"state"`
+	Healthy bool   `json:"healthy"`
+}
+
+// FormAddHookHandler is a new hook handler form.
+type FormAddHookHandler struct {
+	Name   string `json:"name"`
+	Addr   string `json:"addr"`
+	Deploy bool   `json:"deploy"`
+}
+
+// FormUpdateHookHandler is a hook handler settings form, the omitted fields aren't changed.
+type FormUpdateHookHandler struct {
+	ID     uint64  `json:"-"`
+	Name   *string `json:"name"`
+	Addr   *string `json:"addr"`
+	Deploy *bool   `json:"deploy"`
+}
+
+// HookHandlerSvc describes the hook handlers service.
+type HookHandlerSvc interface {
+	List(ctx context.Context) ([]HookHandler, error)
+	Add(ctx context.Context, f FormAddHookHandler) (HookHandler, error)
+	Update(ctx context.Context, f FormUpdateHookHandler) (HookHandler, error)
+	Delete(ctx context.Context, id uint64) error
+	Health(ctx context.Context) ([]HookHealth, error)
+}
+
+// HookHandlerRepo describes interactions with the hook handlers DB.
+type HookHandlerRepo interface {
+	FindAll(ctx context.Context) ([]HookHandler, error)
+	FindByID(ctx context.Context, id uint64) (HookHandler, error)
+	Add(ctx context.Context, h HookHandler) (HookHandler, error)
+	Update(ctx context.Context, h HookHandler) (HookHandler, error)
+	Delete(ctx context.Context, id uint64) error
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/beldeveloper/app-lego/internal/app/errtype"
 	"github.com/beldeveloper/go-errors-context"
 	"github.com/julienschmidt/httprouter"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 )
@@ -16,22 +18,31 @@ func NewHandler(
 	repoSvc app.RepositorySvc,
 	branchSvc app.BranchSvc,
 	deploySvc app.DeploymentSvc,
+	logSvc app.LogSvc,
+	handlerSvc app.HookHandlerSvc,
 	accessKey app.ApiAccessKey,
+	webhookSecret app.WebhookSecret,
 ) Handler {
 	return Handler{
-		repoSvc:   repoSvc,
-		branchSvc: branchSvc,
-		deploySvc: deploySvc,
-		accessKey: string(accessKey),
+		repoSvc:       repoSvc,
+		branchSvc:     branchSvc,
+		deploySvc:     deploySvc,
+		logSvc:        logSvc,
+		handlerSvc:    handlerSvc,
+		accessKey:     string(accessKey),
+		webhookSecret: string(webhookSecret),
 	}
 }
 
 // Handler handles the RESP API requests.
 type Handler struct {
-	repoSvc   app.RepositorySvc
-	branchSvc app.BranchSvc
-	deploySvc app.DeploymentSvc
-	accessKey string
+	repoSvc       app.RepositorySvc
+	branchSvc     app.BranchSvc
+	deploySvc     app.DeploymentSvc
+	logSvc        app.LogSvc
+	handlerSvc    app.HookHandlerSvc
+	accessKey     string
+	webhookSecret string
 }
 
 // Repositories returns the list of repositories.
@@ -70,14 +81,123 @@ func (h Handler) AddRepository(w http.ResponseWriter, r *http.Request, ps httpro
 	apiSuccess(w, res)
 }
 
-// Branches returns the list of branches.
+// UpdateRepository modifies the repository settings, e.g. the remote URL or alias.
+func (h Handler) UpdateRepository(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		apiError(w, fmt.Errorf("%w: invalid repository id: %v", errtype.ErrBadInput, err))
+		return
+	}
+	var f app.FormUpdateRepository
+	err = json.NewDecoder(r.Body).Decode(&f)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	f.ID = uint64(id)
+	res, err := h.repoSvc.Update(r.Context(), f)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, res)
+}
+
+// DeleteRepository removes the repository along with its branches.
+func (h Handler) DeleteRepository(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		apiError(w, fmt.Errorf("%w: invalid repository id: %v", errtype.ErrBadInput, err))
+		return
+	}
+	err = h.repoSvc.Delete(r.Context(), uint64(id))
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, nil)
+}
+
+// DownloadRepository enqueues the failed repository for downloading again.
+func (h Handler) DownloadRepository(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		apiError(w, fmt.Errorf("%w: invalid repository id: %v", errtype.ErrBadInput, err))
+		return
+	}
+	err = h.repoSvc.Download(r.Context(), uint64(id))
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, nil)
+}
+
+// Webhook handles the push notification of the VCS hosting and syncs the repository branches right away.
+func (h Handler) Webhook(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, webhookMaxSize))
+	if err != nil {
+		apiError(w, fmt.Errorf("%w: failed to read webhook payload: %v", errtype.ErrBadInput, err))
+		return
+	}
+	push, ok, err := parseWebhook(ps.ByName("provider"), r.Header, body, h.webhookSecret)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	if ok {
+		err = h.repoSvc.Push(r.Context(), push)
+		if err != nil {
+			apiError(w, err)
+			return
+		}
+	}
+	apiSuccess(w, nil)
+}
+
+// Branches returns the list of branches, the optional sort parameter defines their order.
 func (h Handler) Branches(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	err := h.validateKey(r)
 	if err != nil {
 		apiError(w, err)
 		return
 	}
-	res, err := h.branchSvc.List(r.Context())
+	res, err := h.branchSvc.List(r.Context(), r.URL.Query().Get("sort"))
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, res)
+}
+
+// BranchChangelog returns the commits of the branch since the revision that is passed in the from parameter.
+func (h Handler) BranchChangelog(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		apiError(w, fmt.Errorf("%w: invalid branch id: %v", errtype.ErrBadInput, err))
+		return
+	}
+	res, err := h.branchSvc.Changelog(r.Context(), uint64(id), r.URL.Query().Get("from"))
 	if err != nil {
 		apiError(w, err)
 		return
@@ -85,6 +205,11 @@ func (h Handler) Branches(w http.ResponseWriter, r *http.Request, ps httprouter.
 	apiSuccess(w, res)
 }
 
+// BranchLogs streams the branch build log as the server-sent events.
+func (h Handler) BranchLogs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.streamLogs(w, r, ps, app.LogKindBranch)
+}
+
 // RebuildBranch enqueues the existing branch for rebuilding.
 func (h Handler) RebuildBranch(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	err := h.validateKey(r)
@@ -141,6 +266,31 @@ func (h Handler) AddDeployment(w http.ResponseWriter, r *http.Request, ps httpro
 	apiSuccess(w, res)
 }
 
+// DeploymentChangelog returns the commits that arrived to the deployment branches since they were deployed.
+func (h Handler) DeploymentChangelog(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		apiError(w, fmt.Errorf("%w: invalid deployment id: %v", errtype.ErrBadInput, err))
+		return
+	}
+	res, err := h.deploySvc.Changelog(r.Context(), uint64(id))
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, res)
+}
+
+// DeploymentLogs streams the deployment log as the server-sent events.
+func (h Handler) DeploymentLogs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	h.streamLogs(w, r, ps, app.LogKindDeployment)
+}
+
 // RebuildDeployment enqueues the existing deployment for rebuilding.
 func (h Handler) RebuildDeployment(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	err := h.validateKey(r)
@@ -188,9 +338,165 @@ func (h Handler) CloseDeployment(w http.ResponseWriter, r *http.Request, ps http
 	apiSuccess(w, nil)
 }
 
+// HookHandlers returns the list of hook handlers.
+func (h Handler) HookHandlers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	res, err := h.handlerSvc.List(r.Context())
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, res)
+}
+
+// HookHandlersHealth returns the connection states of the hook handlers.
+func (h Handler) HookHandlersHealth(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	res, err := h.handlerSvc.Health(r.Context())
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, res)
+}
+
+// AddHookHandler registers new hook handler endpoint.
+func (h Handler) AddHookHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	var f app.FormAddHookHandler
+	err = json.NewDecoder(r.Body).Decode(&f)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	res, err := h.handlerSvc.Add(r.Context(), f)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, res)
+}
+
+// UpdateHookHandler modifies the hook handler settings, e.g. its address.
+func (h Handler) UpdateHookHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		apiError(w, fmt.Errorf("%w: invalid hook handler id: %v", errtype.ErrBadInput, err))
+		return
+	}
+	var f app.FormUpdateHookHandler
+	err = json.NewDecoder(r.Body).Decode(&f)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	f.ID = uint64(id)
+	res, err := h.handlerSvc.Update(r.Context(), f)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, res)
+}
+
+// DeleteHookHandler removes the hook handler that isn't assigned to any repository.
+func (h Handler) DeleteHookHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		apiError(w, fmt.Errorf("%w: invalid hook handler id: %v", errtype.ErrBadInput, err))
+		return
+	}
+	err = h.handlerSvc.Delete(r.Context(), uint64(id))
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	apiSuccess(w, nil)
+}
+
 func (h Handler) validateKey(r *http.Request) error {
 	if r.URL.Query().Get("accessKey") != h.accessKey {
 		return errors.WrapContext(errtype.ErrUnauthorized, errors.Context{})
 	}
 	return nil
 }
+
+// streamLogs sends every log line as the "line" event, the "end" event is sent when the log is over.
+// The query parameters are build (the latest one by default), tail (the number of the last lines)
+// and follow (keep sending the new lines until the build is finished).
+func (h Handler) streamLogs(w http.ResponseWriter, r *http.Request, ps httprouter.Params, kind string) {
+	err := h.validateKey(r)
+	if err != nil {
+		apiError(w, err)
+		return
+	}
+	q := app.LogQuery{Kind: kind}
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		apiError(w, fmt.Errorf("%w: invalid %s id: %v", errtype.ErrBadInput, kind, err))
+		return
+	}
+	q.OwnerID = uint64(id)
+	query := r.URL.Query()
+	if v := query.Get("build"); v != "" {
+		q.Build, err = strconv.Atoi(v)
+		if err != nil {
+			apiError(w, fmt.Errorf("%w: invalid build: %v", errtype.ErrBadInput, err))
+			return
+		}
+	}
+	if v := query.Get("tail"); v != "" {
+		q.Tail, err = strconv.Atoi(v)
+		if err != nil {
+			apiError(w, fmt.Errorf("%w: invalid tail: %v", errtype.ErrBadInput, err))
+			return
+		}
+	}
+	if v := query.Get("follow"); v != "" {
+		q.Follow, err = strconv.ParseBool(v)
+		if err != nil {
+			apiError(w, fmt.Errorf("%w: invalid follow: %v", errtype.ErrBadInput, err))
+			return
+		}
+	}
+	stream := eventStream{w: w}
+	err = h.logSvc.Read(r.Context(), q, func(l app.LogLine) error {
+		return stream.send("line", l)
+	})
+	if err != nil {
+		if !stream.started {
+			apiError(w, err)
+			return
+		}
+		if r.Context().Err() == nil {
+			log.Println(err)
+		}
+		return
+	}
+	err = stream.send("end", struct{}{})
+	if err != nil && r.Context().Err() == nil {
+		log.Println(err)
+	}
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/beldeveloper/app-lego/internal/app/errtype"
 	"github.com/beldeveloper/go-errors-context"
 	"log"
@@ -39,3 +40,34 @@ func apiSuccess(w http.ResponseWriter, data interface{}) {
 		log.Println(err)
 	}
 }
+
+// eventStream sends the server-sent events, the headers are sent along with the first event.
+type eventStream struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+// send writes the event with the JSON data and flushes it to the client.
+func (s *eventStream) send(event string, data interface{}) error {
+	if !s.started {
+		SetDefaultHeaders(s.w)
+		h := s.w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Accel-Buffering", "no") // the reverse proxies must not buffer the stream
+		s.w.WriteHeader(http.StatusOK)
+		s.started = true
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, body)
+	if err != nil {
+		return err
+	}
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
@@ -11,12 +11,25 @@ func NewRouter(h Handler) *httprouter.Router {
 
 	r.GET("/repositories", h.Repositories)
 	r.POST("/repositories", h.AddRepository)
+	r.PATCH("/repository/:id", h.UpdateRepository)
+	r.DELETE("/repository/:id", h.DeleteRepository)
+	r.POST("/repository/:id/download", h.DownloadRepository)
+	r.POST("/webhooks/:provider", h.Webhook)
 	r.GET("/branches", h.Branches)
 	r.POST("/branch/:id", h.RebuildBranch)
+	r.GET("/branch/:id/changelog", h.BranchChangelog)
+	r.GET("/branch/:id/logs", h.BranchLogs)
 	r.GET("/deployments", h.Deployments)
 	r.POST("/deployments", h.AddDeployment)
 	r.POST("/deployment/:id", h.RebuildDeployment)
+	r.GET("/deployment/:id/changelog", h.DeploymentChangelog)
+	r.GET("/deployment/:id/logs", h.DeploymentLogs)
 	r.DELETE("/deployment/:id", h.CloseDeployment)
+	r.GET("/hook-handlers", h.HookHandlers)
+	r.POST("/hook-handlers", h.AddHookHandler)
+	r.GET("/hook-handlers/health", h.HookHandlersHealth)
+	r.PATCH("/hook-handler/:id", h.UpdateHookHandler)
+	r.DELETE("/hook-handler/:id", h.DeleteHookHandler)
 
 	r.GlobalOPTIONS = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		SetDefaultHeaders(w)
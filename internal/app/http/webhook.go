@@ -0,0 +1,128 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"net/http"
+	"strings"
+)
+
+const (
+	// WebhookProviderGithub defines the GitHub webhooks.
+	WebhookProviderGithub = "github"
+	// WebhookProviderGitlab defines the GitLab webhooks.
+	WebhookProviderGitlab = "gitlab"
+	// WebhookProviderGitea defines the Gitea webhooks.
+	WebhookProviderGitea = "gitea"
+
+	// webhookMaxSize defines the maximal size of the webhook payload.
+	webhookMaxSize = 10 << 20
+)
+
+// githubPush is a payload of GitHub and Gitea push, create and delete events.
+type githubPush struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+		GitURL   string `json:"git_url"`
+		HTMLURL  string `json:"html_url"`
+	} `json:"repository"`
+}
+
+// gitlabPush is a payload of GitLab push and tag push events.
+type gitlabPush struct {
+	Ref     string `json:"ref"`
+	Project struct {
+		GitSSHURL  string `json:"git_ssh_url"`
+		GitHTTPURL string `json:"git_http_url"`
+		WebURL     string `json:"web_url"`
+	} `json:"project"`
+}
+
+// parseWebhook verifies the webhook signature and turns the payload into the push model.
+// It returns false if the event doesn't change the repository branches, e.g. it is a ping.
+func parseWebhook(provider string, h http.Header, body []byte, secret string) (app.WebhookPush, bool, error) {
+	push := app.WebhookPush{Provider: provider}
+	if secret == "" {
+		return push, false, fmt.Errorf("%w: webhooks are disabled", errtype.ErrUnauthorized)
+	}
+	switch provider {
+	case WebhookProviderGithub:
+		err := verifyHMAC(strings.TrimPrefix(h.Get("X-Hub-Signature-256"), "sha256="), body, secret)
+		if err != nil {
+			return push, false, err
+		}
+		switch h.Get("X-GitHub-Event") {
+		case "push", "create", "delete":
+			return parseGithubPush(push, body)
+		}
+		return push, false, nil
+	case WebhookProviderGitea:
+		err := verifyHMAC(h.Get("X-Gitea-Signature"), body, secret)
+		if err != nil {
+			return push, false, err
+		}
+		switch h.Get("X-Gitea-Event") {
+		case "push", "create", "delete":
+			return parseGithubPush(push, body)
+		}
+		return push, false, nil
+	case WebhookProviderGitlab:
+		if subtle.ConstantTimeCompare([]byte(h.Get("X-Gitlab-Token")), []byte(secret)) != 1 {
+			return push, false, fmt.Errorf("%w: invalid webhook token", errtype.ErrUnauthorized)
+		}
+		switch h.Get("X-Gitlab-Event") {
+		case "Push Hook", "Tag Push Hook":
+			var p gitlabPush
+			if err := json.Unmarshal(body, &p); err != nil {
+				return push, false, fmt.Errorf("%w: invalid webhook payload: %v", errtype.ErrBadInput, err)
+			}
+			push.Ref = p.Ref
+			push.URLs = nonEmpty(p.Project.GitHTTPURL, p.Project.GitSSHURL, p.Project.WebURL)
+			return push, true, nil
+		}
+		return push, false, nil
+	}
+	return push, false, fmt.Errorf("%w: unknown webhook provider: %s", errtype.ErrBadInput, provider)
+}
+
+func parseGithubPush(push app.WebhookPush, body []byte) (app.WebhookPush, bool, error) {
+	var p githubPush
+	if err := json.Unmarshal(body, &p); err != nil {
+		return push, false, fmt.Errorf("%w: invalid webhook payload: %v", errtype.ErrBadInput, err)
+	}
+	push.Ref = p.Ref
+	push.URLs = nonEmpty(p.Repository.CloneURL, p.Repository.SSHURL, p.Repository.GitURL, p.Repository.HTMLURL)
+	return push, true, nil
+}
+
+// verifyHMAC checks the hex encoded HMAC-SHA256 signature of the payload.
+func verifyHMAC(signature string, body []byte, secret string) error {
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) == 0 {
+		return fmt.Errorf("%w: invalid webhook signature", errtype.ErrUnauthorized)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return fmt.Errorf("%w: invalid webhook signature", errtype.ErrUnauthorized)
+	}
+	return nil
+}
+
+func nonEmpty(values ...string) []string {
+	res := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,229 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testWebhookSecret = "s3cr3t"
+
+func sign(body []byte, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join("testdata", "webhooks", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestParseWebhookFixtures(t *testing.T) {
+	github := []string{
+		"https://github.com/octo-org/shop.git",
+		"git@github.com:octo-org/shop.git",
+		"git://github.com/octo-org/shop.git",
+		"https://github.com/octo-org/shop",
+	}
+	gitlab := []string{
+		"https://gitlab.example.com/mike/shop.git",
+		"git@gitlab.example.com:mike/shop.git",
+		"https://gitlab.example.com/mike/shop",
+	}
+	tests := []struct {
+		fixture  string
+		provider string
+		headers  func(body []byte) http.Header
+		ref      string
+		urls     []string
+	}{
+		{
+			fixture:  "github_push.json",
+			provider: WebhookProviderGithub,
+			headers: func(body []byte) http.Header {
+				return http.Header{
+					"X-Github-Event":      {"push"},
+					"X-Hub-Signature-256": {"sha256=" + sign(body, testWebhookSecret)},
+				}
+			},
+			ref:  "refs/heads/feature/login",
+			urls: github,
+		},
+		{
+			fixture:  "github_tag.json",
+			provider: WebhookProviderGithub,
+			headers: func(body []byte) http.Header {
+				return http.Header{
+					"X-Github-Event":      {"push"},
+					"X-Hub-Signature-256": {"sha256=" + sign(body, testWebhookSecret)},
+				}
+			},
+			ref:  "refs/tags/v1.4.0",
+			urls: github,
+		},
+		{
+			fixture:  "gitea_push.json",
+			provider: WebhookProviderGitea,
+			headers: func(body []byte) http.Header {
+				return http.Header{
+					"X-Gitea-Event":     {"push"},
+					"X-Gitea-Signature": {sign(body, testWebhookSecret)},
+				}
+			},
+			ref: "refs/heads/develop",
+			urls: []string{
+				"https://gitea.example.com/team/shop.git",
+				"git@gitea.example.com:team/shop.git",
+				"https://gitea.example.com/team/shop",
+			},
+		},
+		{
+			fixture:  "gitlab_push.json",
+			provider: WebhookProviderGitlab,
+			headers: func([]byte) http.Header {
+				return http.Header{"X-Gitlab-Event": {"Push Hook"}, "X-Gitlab-Token": {testWebhookSecret}}
+			},
+			ref:  "refs/heads/feature/cart",
+			urls: gitlab,
+		},
+		{
+			fixture:  "gitlab_tag_push.json",
+			provider: WebhookProviderGitlab,
+			headers: func([]byte) http.Header {
+				return http.Header{"X-Gitlab-Event": {"Tag Push Hook"}, "X-Gitlab-Token": {testWebhookSecret}}
+			},
+			ref:  "refs/tags/v2.0.1",
+			urls: gitlab,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			body := readFixture(t, tt.fixture)
+			push, ok, err := parseWebhook(tt.provider, tt.headers(body), body, testWebhookSecret)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !ok {
+				t.Fatal("the event is ignored")
+			}
+			if push.Provider != tt.provider {
+				t.Errorf("provider = %q, want %q", push.Provider, tt.provider)
+			}
+			if push.Ref != tt.ref {
+				t.Errorf("ref = %q, want %q", push.Ref, tt.ref)
+			}
+			if !reflect.DeepEqual(push.URLs, tt.urls) {
+				t.Errorf("urls = %v, want %v", push.URLs, tt.urls)
+			}
+		})
+	}
+}
+
+func TestParseWebhookIgnoredEvent(t *testing.T) {
+	body := readFixture(t, "github_push.json")
+	h := http.Header{"X-Github-Event": {"ping"}, "X-Hub-Signature-256": {"sha256=" + sign(body, testWebhookSecret)}}
+	_, ok, err := parseWebhook(WebhookProviderGithub, h, body, testWebhookSecret)
+	if err != nil || ok {
+		t.Fatalf("ok = %v, err = %v; want the ignored event", ok, err)
+	}
+}
+
+func TestParseWebhookDisabled(t *testing.T) {
+	body := readFixture(t, "gitlab_push.json")
+	h := http.Header{"X-Gitlab-Event": {"Push Hook"}, "X-Gitlab-Token": {""}}
+	_, _, err := parseWebhook(WebhookProviderGitlab, h, body, "")
+	if !errors.Is(err, errtype.ErrUnauthorized) {
+		t.Fatalf("err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestGitlabToken(t *testing.T) {
+	body := readFixture(t, "gitlab_push.json")
+	tests := []struct {
+		name  string
+		token []string
+		ok    bool
+	}{
+		{name: "valid", token: []string{testWebhookSecret}, ok: true},
+		{name: "wrong", token: []string{"wrong"}},
+		{name: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := http.Header{"X-Gitlab-Event": {"Push Hook"}}
+			if tt.token != nil {
+				h["X-Gitlab-Token"] = tt.token
+			}
+			_, _, err := parseWebhook(WebhookProviderGitlab, h, body, testWebhookSecret)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, errtype.ErrUnauthorized) {
+				t.Fatalf("err = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	tests := []struct {
+		name      string
+		signature string
+		ok        bool
+	}{
+		{name: "valid", signature: sign(body, testWebhookSecret), ok: true},
+		{name: "wrong secret", signature: sign(body, "other")},
+		{name: "other body", signature: sign([]byte(`{}`), testWebhookSecret)},
+		{name: "not hex", signature: "zz"},
+		{name: "missing", signature: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyHMAC(tt.signature, body, testWebhookSecret)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, errtype.ErrUnauthorized) {
+				t.Fatalf("err = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
+
+func TestParseWebhookSignatureHeaders(t *testing.T) {
+	tests := []struct {
+		fixture  string
+		provider string
+		header   http.Header
+	}{
+		{
+			fixture:  "github_push.json",
+			provider: WebhookProviderGithub,
+			header:   http.Header{"X-Github-Event": {"push"}, "X-Hub-Signature-256": {"sha256=00"}},
+		},
+		{fixture: "github_push.json", provider: WebhookProviderGithub, header: http.Header{"X-Github-Event": {"push"}}},
+		{fixture: "gitea_push.json", provider: WebhookProviderGitea, header: http.Header{"X-Gitea-Event": {"push"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			body := readFixture(t, tt.fixture)
+			_, _, err := parseWebhook(tt.provider, tt.header, body, testWebhookSecret)
+			if !errors.Is(err, errtype.ErrUnauthorized) {
+				t.Fatalf("err = %v, want ErrUnauthorized", err)
+			}
+		})
+	}
+}
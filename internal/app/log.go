@@ -0,0 +1,59 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// LogKindBranch defines the logs of the branch builds.
+	LogKindBranch = "branch"
+	// LogKindDeployment defines the logs of the deployments.
+	LogKindDeployment = "deployment"
+)
+
+// LogLine is a model that represents a line of the build output.
+type LogLine struct {
+	ID        uint64    `json:"id"`
+	Build     int       `json:"build"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// LogQuery defines which build log lines are read.
+type LogQuery struct {
+	Kind    string
+	OwnerID uint64
+	// Build is a number of the build, the latest build is read if it is 0.
+	Build int
+	// Tail limits the number of the last lines, all lines are read if it is 0.
+	Tail int
+	// Follow keeps reading the new lines until the build is finished.
+	Follow bool
+}
+
+// BuildLog collects the output of the particular build.
+type BuildLog interface {
+	// Write saves the chunk of the output, the incomplete last line is saved along with the next chunk.
+	Write(ctx context.Context, text string)
+	// Close saves the incomplete last line.
+	Close(ctx context.Context)
+}
+
+// LogSvc describes the build logs service.
+type LogSvc interface {
+	Start(ctx context.Context, kind string, ownerID uint64) (BuildLog, error)
+	Resume(ctx context.Context, kind string, ownerID uint64) (BuildLog, error)
+	Read(ctx context.Context, q LogQuery, send func(LogLine) error) error
+	Delete(ctx context.Context, kind string, ownerIDs []uint64) error
+}
+
+// LogRepo describes interactions with the build logs DB.
+type LogRepo interface {
+	LastBuild(ctx context.Context, kind string, ownerID uint64) (int, error)
+	Add(ctx context.Context, kind string, ownerID uint64, build int, lines []string) error
+	FindAfter(ctx context.Context, kind string, ownerID uint64, build int, afterID uint64) ([]LogLine, error)
+	FindTail(ctx context.Context, kind string, ownerID uint64, build int, tail int) ([]LogLine, error)
+	DeleteBefore(ctx context.Context, kind string, ownerID uint64, build int) error
+	DeleteByOwners(ctx context.Context, kind string, ownerIDs []uint64) error
+}
@@ -10,32 +10,67 @@ import (
 	"github.com/jackc/pgx/v4/pgxpool"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // NewBranch creates a new instance of the repository.
-func NewBranch(conn *pgxpool.Pool) app.BranchRepo {
-	return Branch{conn: conn}
+func NewBranch(conn *pgxpool.Pool, workerID app.WorkerID) app.BranchRepo {
+	return Branch{conn: conn, workerID: string(workerID)}
 }
 
 // Branch implements a repository.
 type Branch struct {
-	conn *pgxpool.Pool
+	conn     *pgxpool.Pool
+	workerID string
+}
+
+// branchColumns is a list of the columns that are scanned by scanBranch.
+const branchColumns = `"id", "repository_id", "type", "name", "hash", "base_hash", "status", "error_msg",
+	"author", "committed_at", "subject", "message", "components", "job_id", "progress", "stage", "retry_at"`
+
+// branchOrders maps the sort options to the ORDER BY clauses, the branches without commits go last.
+var branchOrders = map[string]string{
+	app.BranchSortName:        `"name"`,
+	app.BranchSortCommittedAt: `"committed_at" DESC NULLS LAST, "name"`,
+}
+
+func scanBranch(row pgx.Row, b *app.Branch) error {
+	b.Components = nil // the decoded JSON reuses the slice of the previously scanned branch otherwise
+	return row.Scan(&b.ID, &b.RepositoryID, &b.Type, &b.Name, &b.Hash, &b.BaseHash, &b.Status, &b.ErrorMsg,
+		&b.Author, &b.CommittedAt, &b.Subject, &b.Message, &b.Components, &b.JobID, &b.Progress, &b.Stage, &b.RetryAt)
 }
 
 // FindAll returns all branches.
 func (r Branch) FindAll(ctx context.Context) ([]app.Branch, error) {
-	q := `SELECT "id", "repository_id", "type", "name", "hash", "status", "error_msg" FROM "branches" ORDER BY "name"`
+	return r.FindSorted(ctx, app.BranchSortName)
+}
+
+// FindSorted returns all branches in the specific order.
+func (r Branch) FindSorted(ctx context.Context, sort string) ([]app.Branch, error) {
+	order, exists := branchOrders[sort]
+	if !exists {
+		return nil, errors.WrapContext(fmt.Errorf("%w: unknown sort: %s", errtype.ErrBadInput, sort), errors.Context{
+			Path: "postgres.Branch.FindSorted.order",
+		})
+	}
+	q := `SELECT ` + branchColumns + ` FROM "branches" ORDER BY ` + order
 	rows, err := r.conn.Query(ctx, q)
 	if err != nil {
-		return nil, errors.WrapContext(err, errors.Context{Path: "postgres.Branch.FindAll.Query"})
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "postgres.Branch.FindSorted.Query",
+			Params: errors.Params{"sort": sort},
+		})
 	}
 	defer rows.Close()
 	res := make([]app.Branch, 0)
 	var b app.Branch
 	for rows.Next() {
-		err = rows.Scan(&b.ID, &b.RepositoryID, &b.Type, &b.Name, &b.Hash, &b.Status, &b.ErrorMsg)
+		err = scanBranch(rows, &b)
 		if err != nil {
-			return nil, errors.WrapContext(err, errors.Context{Path: "postgres.Branch.FindAll.Scan"})
+			return nil, errors.WrapContext(err, errors.Context{
+				Path:   "postgres.Branch.FindSorted.Scan",
+				Params: errors.Params{"sort": sort},
+			})
 		}
 		res = append(res, b)
 	}
@@ -52,7 +87,7 @@ func (r Branch) FindByIDs(ctx context.Context, ids []uint64) ([]app.Branch, erro
 		idsStr[i] = strconv.Itoa(int(id))
 	}
 	q := fmt.Sprintf(
-		`SELECT "id", "repository_id", "type", "name", "hash", "status", "error_msg" FROM "branches" WHERE "id" IN (%s)`,
+		`SELECT `+branchColumns+` FROM "branches" WHERE "id" IN (%s)`,
 		strings.Join(idsStr, ","),
 	)
 	rows, err := r.conn.Query(ctx, q)
@@ -66,7 +101,7 @@ func (r Branch) FindByIDs(ctx context.Context, ids []uint64) ([]app.Branch, erro
 	res := make([]app.Branch, 0)
 	var b app.Branch
 	for rows.Next() {
-		err = rows.Scan(&b.ID, &b.RepositoryID, &b.Type, &b.Name, &b.Hash, &b.Status, &b.ErrorMsg)
+		err = scanBranch(rows, &b)
 		if err != nil {
 			return nil, errors.WrapContext(err, errors.Context{
 				Path:   "postgres.Branch.FindByIDs.Scan",
@@ -80,7 +115,7 @@ func (r Branch) FindByIDs(ctx context.Context, ids []uint64) ([]app.Branch, erro
 
 // FindByRepository returns all branches that belong to the specific repository.
 func (r Branch) FindByRepository(ctx context.Context, repo app.Repository) ([]app.Branch, error) {
-	q := `SELECT "id", "repository_id", "type", "name", "hash", "status", "error_msg" FROM "branches" WHERE "repository_id" = $1`
+	q := `SELECT ` + branchColumns + ` FROM "branches" WHERE "repository_id" = $1`
 	rows, err := r.conn.Query(ctx, q, repo.ID)
 	if err != nil {
 		return nil, errors.WrapContext(err, errors.Context{
@@ -92,7 +127,7 @@ func (r Branch) FindByRepository(ctx context.Context, repo app.Repository) ([]ap
 	res := make([]app.Branch, 0)
 	var b app.Branch
 	for rows.Next() {
-		err = rows.Scan(&b.ID, &b.RepositoryID, &b.Type, &b.Name, &b.Hash, &b.Status, &b.ErrorMsg)
+		err = scanBranch(rows, &b)
 		if err != nil {
 			return nil, errors.WrapContext(err, errors.Context{
 				Path:   "postgres.Branch.FindByRepository.Scan",
@@ -107,8 +142,8 @@ func (r Branch) FindByRepository(ctx context.Context, repo app.Repository) ([]ap
 // FindByID returns the one branch with the specific ID.
 func (r Branch) FindByID(ctx context.Context, id uint64) (app.Branch, error) {
 	var b app.Branch
-	q := `SELECT "id", "repository_id", "type", "name", "hash", "status", "error_msg" FROM "branches" WHERE "id" = $1`
-	err := r.conn.QueryRow(ctx, q, id).Scan(&b.ID, &b.RepositoryID, &b.Type, &b.Name, &b.Hash, &b.Status, &b.ErrorMsg)
+	q := `SELECT ` + branchColumns + ` FROM "branches" WHERE "id" = $1`
+	err := scanBranch(r.conn.QueryRow(ctx, q, id), &b)
 	if err == pgx.ErrNoRows {
 		err = errtype.ErrNotFound
 	}
@@ -118,37 +153,64 @@ func (r Branch) FindByID(ctx context.Context, id uint64) (app.Branch, error) {
 	})
 }
 
-// FindEnqueued returns the one branch that is enqueued or in building status (it means the process was interrupted earlier).
-func (r Branch) FindEnqueued(ctx context.Context) (app.Branch, error) {
+// ClaimEnqueued leases the one enqueued branch, marks it as building and returns it, so no one else picks it up.
+// The branch that awaits the retry is skipped until the retry time comes.
+// The building branch with the expired lease is claimed as well (it means the process was interrupted earlier
+// or the hook handler stopped reporting the accepted build).
+func (r Branch) ClaimEnqueued(ctx context.Context) (app.Branch, error) {
 	var b app.Branch
-	q := `SELECT "id", "repository_id", "type", "name", "hash", "status", "error_msg" FROM "branches" WHERE "status" IN ($1, $2) LIMIT 1`
-	err := r.conn.QueryRow(ctx, q, app.BranchStatusEnqueued, app.BranchStatusBuilding).
-		Scan(&b.ID, &b.RepositoryID, &b.Type, &b.Name, &b.Hash, &b.Status, &b.ErrorMsg)
+	q := `UPDATE "branches" SET "status" = $2, "worker_id" = $3, "lease_expires_at" = NOW() + $4 * INTERVAL '1 second',
+		"job_id" = '', "progress" = 0, "stage" = '', "retry_at" = NULL
+		WHERE "id" = (
+			SELECT "id" FROM "branches"
+			WHERE ("status" = $1 AND ("retry_at" IS NULL OR "retry_at" <= NOW()))
+				OR ("status" = $2 AND ("lease_expires_at" IS NULL OR "lease_expires_at" < NOW()))
+			ORDER BY "id" LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING ` + branchColumns
+	row := r.conn.QueryRow(ctx, q, app.BranchStatusEnqueued, app.BranchStatusBuilding, r.workerID, app.LeaseTTL.Seconds())
+	err := scanBranch(row, &b)
 	if err == pgx.ErrNoRows {
 		err = errtype.ErrNotFound
 	}
-	return b, errors.WrapContext(err, errors.Context{Path: "postgres.Branch.FindEnqueued.Scan"})
+	return b, errors.WrapContext(err, errors.Context{Path: "postgres.Branch.ClaimEnqueued.Scan"})
 }
 
 // Add saves a new branch.
+// The branch that is already added by the concurrent sync is not saved again, ErrConflict is returned then.
 func (r Branch) Add(ctx context.Context, b app.Branch) (app.Branch, error) {
-	q := `INSERT INTO "branches" ("repository_id", "type", "name", "hash", "status")
-		VALUES ($1, $2, $3, $4, $5) RETURNING "id"`
-	err := r.conn.QueryRow(ctx, q, b.RepositoryID, b.Type, b.Name, b.Hash, b.Status).Scan(&b.ID)
+	q := `INSERT INTO "branches"
+		("repository_id", "type", "name", "hash", "base_hash", "status", "author", "committed_at", "subject", "message",
+		"components")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		ON CONFLICT ("repository_id", "type", "name") DO NOTHING RETURNING "id"`
+	err := r.conn.QueryRow(ctx, q, b.RepositoryID, b.Type, b.Name, b.Hash, b.BaseHash, b.Status,
+		b.Author, b.CommittedAt, b.Subject, b.Message, components(b)).Scan(&b.ID)
+	if err == pgx.ErrNoRows {
+		err = errtype.ErrConflict
+	}
 	if err != nil {
 		return b, errors.WrapContext(err, errors.Context{Path: "postgres.Branch.Add.Scan"})
 	}
+	r.notifyEnqueued(ctx, b)
 	return b, nil
 }
 
 // Update modifies a specific branch.
 func (r Branch) Update(ctx context.Context, b app.Branch) (app.Branch, error) {
-	q := `UPDATE "branches" SET "hash" = $2, "status" = $3, "error_msg" = $4 WHERE "id" = $1`
-	_, err := r.conn.Exec(ctx, q, b.ID, b.Hash, b.Status, b.ErrorMsg)
-	return b, errors.WrapContext(err, errors.Context{
-		Path:   "postgres.Branch.Update.Exec",
-		Params: errors.Params{"branch": b.ID, "status": b.Status, "hash": b.Hash},
-	})
+	q := `UPDATE "branches" SET "hash" = $2, "status" = $3, "error_msg" = $4, "worker_id" = NULL, "lease_expires_at" = NULL,
+		"job_id" = '', "retry_at" = NULL, "author" = $5, "committed_at" = $6, "subject" = $7, "message" = $8, "base_hash" = $9,
+		"components" = $10
+		WHERE "id" = $1`
+	_, err := r.conn.Exec(ctx, q, b.ID, b.Hash, b.Status, b.ErrorMsg, b.Author, b.CommittedAt, b.Subject, b.Message, b.BaseHash,
+		components(b))
+	if err != nil {
+		return b, errors.WrapContext(err, errors.Context{
+			Path:   "postgres.Branch.Update.Exec",
+			Params: errors.Params{"branch": b.ID, "status": b.Status, "hash": b.Hash},
+		})
+	}
+	r.notifyEnqueued(ctx, b)
+	return b, nil
 }
 
 // DeleteByIDs deletes all branches with the specific ids.
@@ -171,12 +233,101 @@ func (r Branch) DeleteByIDs(ctx context.Context, ids []uint64) error {
 	return nil
 }
 
-// UpdateStatus modifies the branch status.
+// UpdateStatus modifies the status of the branch and finishes its build.
+// The build is identified by the job ID, so the superseded one is not found and doesn't overwrite the status.
 func (r Branch) UpdateStatus(ctx context.Context, b app.Branch) error {
-	q := `UPDATE "branches" SET "status" = $2, "error_msg" = $3 WHERE "id" = $1`
-	_, err := r.conn.Exec(ctx, q, b.ID, b.Status, b.ErrorMsg)
+	q := `UPDATE "branches" SET "status" = $2, "error_msg" = $3, "worker_id" = NULL, "lease_expires_at" = NULL,
+		"job_id" = '', "retry_at" = NULL
+		WHERE "id" = $1 AND "job_id" = $4`
+	tag, err := r.conn.Exec(ctx, q, b.ID, b.Status, b.ErrorMsg, b.JobID)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "postgres.Branch.UpdateStatus.Exec",
+			Params: errors.Params{"branch": b.ID, "status": b.Status},
+		})
+	}
+	if tag.RowsAffected() == 0 {
+		return errtype.ErrNotFound
+	}
+	r.notifyEnqueued(ctx, b)
+	return nil
+}
+
+// UpdateCommit modifies the metadata of the branch head commit.
+func (r Branch) UpdateCommit(ctx context.Context, b app.Branch) error {
+	q := `UPDATE "branches" SET "author" = $2, "committed_at" = $3, "subject" = $4, "message" = $5 WHERE "id" = $1`
+	_, err := r.conn.Exec(ctx, q, b.ID, b.Author, b.CommittedAt, b.Subject, b.Message)
 	return errors.WrapContext(err, errors.Context{
-		Path:   "postgres.Branch.UpdateStatus.Exec",
-		Params: errors.Params{"branch": b.ID, "status": b.Status},
+		Path:   "postgres.Branch.UpdateCommit.Exec",
+		Params: errors.Params{"branch": b.ID},
 	})
 }
+
+// ExtendLease prolongs the lease of the building branch that is claimed by the current application instance.
+func (r Branch) ExtendLease(ctx context.Context, b app.Branch) error {
+	q := `UPDATE "branches" SET "lease_expires_at" = NOW() + $3 * INTERVAL '1 second'
+		WHERE "id" = $1 AND "worker_id" = $2`
+	_, err := r.conn.Exec(ctx, q, b.ID, r.workerID, app.LeaseTTL.Seconds())
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Branch.ExtendLease.Exec",
+		Params: errors.Params{"branch": b.ID},
+	})
+}
+
+// Accept releases the building branch that the hook handler goes on with in background.
+// The accepted build is leased by no instance until the hook handler reports it.
+// ErrNotFound is returned if the branch is not leased by the current instance anymore.
+func (r Branch) Accept(ctx context.Context, b app.Branch) error {
+	q := `UPDATE "branches" SET "job_id" = $3, "progress" = 0, "stage" = '', "worker_id" = NULL,
+		"lease_expires_at" = NOW() + $4 * INTERVAL '1 second'
+		WHERE "id" = $1 AND "worker_id" = $2`
+	tag, err := r.conn.Exec(ctx, q, b.ID, r.workerID, b.JobID, app.AcceptedBuildTTL.Seconds())
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "postgres.Branch.Accept.Exec",
+			Params: errors.Params{"branch": b.ID, "job": b.JobID},
+		})
+	}
+	if tag.RowsAffected() == 0 {
+		return errtype.ErrNotFound
+	}
+	return nil
+}
+
+// UpdateProgress modifies the progress of the accepted build and prolongs its lease.
+func (r Branch) UpdateProgress(ctx context.Context, b app.Branch) error {
+	q := `UPDATE "branches" SET "progress" = $3, "stage" = $4, "lease_expires_at" = NOW() + $5 * INTERVAL '1 second'
+		WHERE "id" = $1 AND "job_id" = $2`
+	_, err := r.conn.Exec(ctx, q, b.ID, b.JobID, b.Progress, b.Stage, app.AcceptedBuildTTL.Seconds())
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Branch.UpdateProgress.Exec",
+		Params: errors.Params{"branch": b.ID, "job": b.JobID},
+	})
+}
+
+// Postpone enqueues the building branch again, it isn't claimed until the delay passes.
+// The build job isn't woken up, because the retry makes no sense before the delay passes.
+func (r Branch) Postpone(ctx context.Context, b app.Branch, delay time.Duration) error {
+	q := `UPDATE "branches" SET "status" = $2, "error_msg" = $3, "worker_id" = NULL, "lease_expires_at" = NULL,
+		"job_id" = '', "retry_at" = NOW() + $4 * INTERVAL '1 second'
+		WHERE "id" = $1`
+	_, err := r.conn.Exec(ctx, q, b.ID, app.BranchStatusEnqueued, b.ErrorMsg, delay.Seconds())
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Branch.Postpone.Exec",
+		Params: errors.Params{"branch": b.ID},
+	})
+}
+
+// components returns the components of the branch, the nil list is saved as the empty one.
+func components(b app.Branch) []app.BranchComponent {
+	if b.Components == nil {
+		return []app.BranchComponent{}
+	}
+	return b.Components
+}
+
+func (r Branch) notifyEnqueued(ctx context.Context, b app.Branch) {
+	if b.Status == app.BranchStatusEnqueued {
+		notify(ctx, r.conn, app.EventBranchEnqueued, b.ID)
+	}
+}
@@ -91,15 +91,39 @@ func (r Deployment) Add(ctx context.Context, d app.Deployment) (app.Deployment,
 	q := `INSERT INTO "deployments" ("status", "created_at", "auto_rebuild", "branches")
 		VALUES ($1, $2, $3, $4) RETURNING "id"`
 	err := r.conn.QueryRow(ctx, q, d.Status, d.CreatedAt, d.AutoRebuild, d.Branches).Scan(&d.ID)
-	return d, errors.WrapContext(err, errors.Context{Path: "postgres.Deployment.Add.Scan"})
+	if err != nil {
+		return d, errors.WrapContext(err, errors.Context{Path: "postgres.Deployment.Add.Scan"})
+	}
+	r.notifyEnqueued(ctx, d)
+	return d, nil
 }
 
 // Update modifies a specific deployment.
 func (r Deployment) Update(ctx context.Context, d app.Deployment) (app.Deployment, error) {
 	q := `UPDATE "deployments" SET "status" = $2, "branches" = $3, "error_msg" = $4 WHERE "id" = $1`
 	_, err := r.conn.Exec(ctx, q, d.ID, d.Status, d.Branches, d.ErrorMsg)
-	return d, errors.WrapContext(err, errors.Context{
-		Path:   "postgres.Deployment.Update.Exec",
+	if err != nil {
+		return d, errors.WrapContext(err, errors.Context{
+			Path:   "postgres.Deployment.Update.Exec",
+			Params: errors.Params{"deployment": d.ID},
+		})
+	}
+	r.notifyEnqueued(ctx, d)
+	return d, nil
+}
+
+// Requeue enqueues the deployment again without waking the watcher up, so it is deployed on the next job run.
+func (r Deployment) Requeue(ctx context.Context, d app.Deployment) error {
+	q := `UPDATE "deployments" SET "status" = $2, "error_msg" = $3 WHERE "id" = $1`
+	_, err := r.conn.Exec(ctx, q, d.ID, app.DeploymentStatusEnqueued, d.ErrorMsg)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Deployment.Requeue.Exec",
 		Params: errors.Params{"deployment": d.ID},
 	})
 }
+
+func (r Deployment) notifyEnqueued(ctx context.Context, d app.Deployment) {
+	if d.Status == app.DeploymentStatusEnqueued {
+		notify(ctx, r.conn, app.EventDeploymentEnqueued, d.ID)
+	}
+}
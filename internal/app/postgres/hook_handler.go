@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/go-errors-context"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// NewHookHandler creates a new instance of the repository.
+func NewHookHandler(conn *pgxpool.Pool) app.HookHandlerRepo {
+	return HookHandler{conn: conn}
+}
+
+// HookHandler implements a repository.
+type HookHandler struct {
+	conn *pgxpool.Pool
+}
+
+// FindAll returns all hook handlers ordered by the name.
+func (r HookHandler) FindAll(ctx context.Context) ([]app.HookHandler, error) {
+	q := `SELECT "id", "name", "addr", "deploy" FROM "hook_handlers" ORDER BY "name"`
+	rows, err := r.conn.Query(ctx, q)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{Path: "postgres.HookHandler.FindAll.Query"})
+	}
+	defer rows.Close()
+	res := make([]app.HookHandler, 0)
+	var h app.HookHandler
+	for rows.Next() {
+		err = rows.Scan(&h.ID, &h.Name, &h.Addr, &h.Deploy)
+		if err != nil {
+			return nil, errors.WrapContext(err, errors.Context{Path: "postgres.HookHandler.FindAll.Scan"})
+		}
+		res = append(res, h)
+	}
+	return res, nil
+}
+
+// FindByID returns the one hook handler with the specific ID.
+func (r HookHandler) FindByID(ctx context.Context, id uint64) (app.HookHandler, error) {
+	var h app.HookHandler
+	q := `SELECT "id", "name", "addr", "deploy" FROM "hook_handlers" WHERE "id" = $1`
+	err := r.conn.QueryRow(ctx, q, id).Scan(&h.ID, &h.Name, &h.Addr, &h.Deploy)
+	if err == pgx.ErrNoRows {
+		err = errtype.ErrNotFound
+	}
+	return h, errors.WrapContext(err, errors.Context{
+		Path:   "postgres.HookHandler.FindByID.Scan",
+		Params: errors.Params{"handler": id},
+	})
+}
+
+// Add saves a new hook handler.
+func (r HookHandler) Add(ctx context.Context, h app.HookHandler) (app.HookHandler, error) {
+	q := `INSERT INTO "hook_handlers" ("name", "addr", "deploy") VALUES ($1, $2, $3) RETURNING "id"`
+	err := r.conn.QueryRow(ctx, q, h.Name, h.Addr, h.Deploy).Scan(&h.ID)
+	return h, errors.WrapContext(err, errors.Context{Path: "postgres.HookHandler.Add.Scan"})
+}
+
+// Update modifies a specific hook handler.
+func (r HookHandler) Update(ctx context.Context, h app.HookHandler) (app.HookHandler, error) {
+	q := `UPDATE "hook_handlers" SET "name" = $2, "addr" = $3, "deploy" = $4 WHERE "id" = $1`
+	_, err := r.conn.Exec(ctx, q, h.ID, h.Name, h.Addr, h.Deploy)
+	return h, errors.WrapContext(err, errors.Context{
+		Path:   "postgres.HookHandler.Update.Exec",
+		Params: errors.Params{"handler": h.ID},
+	})
+}
+
+// Delete removes a specific hook handler.
+func (r HookHandler) Delete(ctx context.Context, id uint64) error {
+	q := `DELETE FROM "hook_handlers" WHERE "id" = $1`
+	_, err := r.conn.Exec(ctx, q, id)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.HookHandler.Delete.Exec",
+		Params: errors.Params{"handler": id},
+	})
+}
@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/go-errors-context"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log"
+	"strconv"
+)
+
+// NewListener creates a new instance of the events listener.
+func NewListener(conn *pgxpool.Pool) app.EventListener {
+	return Listener{conn: conn}
+}
+
+// Listener implements the events subscription using the postgres LISTEN/NOTIFY.
+type Listener struct {
+	conn *pgxpool.Pool
+}
+
+// Listen subscribes to the events and calls the function on every event until the context is canceled.
+func (l Listener) Listen(ctx context.Context, events []string, fn func(event string)) error {
+	c, err := l.conn.Acquire(ctx)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{Path: "postgres.Listener.Listen.Acquire"})
+	}
+	defer c.Release()
+	// the subscription belongs to the session, so the connection must not return to the pool
+	defer c.Conn().Close(context.Background())
+	for _, e := range events {
+		_, err = c.Exec(ctx, "LISTEN "+pgx.Identifier{e}.Sanitize())
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "postgres.Listener.Listen.Exec",
+				Params: errors.Params{"event": e},
+			})
+		}
+	}
+	for {
+		n, err := c.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{Path: "postgres.Listener.Listen.WaitForNotification"})
+		}
+		fn(n.Channel)
+	}
+}
+
+// notify emits the event for all application instances.
+// The failure is not critical because the jobs poll the database anyway.
+func notify(ctx context.Context, conn *pgxpool.Pool, event string, id uint64) {
+	_, err := conn.Exec(ctx, `SELECT PG_NOTIFY($1, $2)`, event, strconv.FormatUint(id, 10))
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "postgres.notify.Exec",
+			Params: errors.Params{"event": event, "id": id},
+		}))
+	}
+}
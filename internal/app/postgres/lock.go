@@ -0,0 +1,59 @@
+package postgres
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/go-errors-context"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"log"
+	"time"
+)
+
+// unlockTimeout defines the timeout for releasing the advisory lock.
+const unlockTimeout = 5 * time.Second
+
+// NewJobLock creates a new instance of the job lock.
+func NewJobLock(conn *pgxpool.Pool) app.JobLocker {
+	return JobLock{conn: conn}
+}
+
+// JobLock implements the lock that is shared between the application instances using the postgres advisory locks.
+type JobLock struct {
+	conn *pgxpool.Pool
+}
+
+// TryLock acquires the lock with the specific name if it is free.
+func (l JobLock) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	// the advisory lock belongs to the session, so the connection is kept until the lock is released
+	c, err := l.conn.Acquire(ctx)
+	if err != nil {
+		return nil, false, errors.WrapContext(err, errors.Context{
+			Path:   "postgres.JobLock.TryLock.Acquire",
+			Params: errors.Params{"name": name},
+		})
+	}
+	var ok bool
+	err = c.QueryRow(ctx, `SELECT PG_TRY_ADVISORY_LOCK(HASHTEXT($1))`, name).Scan(&ok)
+	if err != nil || !ok {
+		c.Release()
+		return nil, false, errors.WrapContext(err, errors.Context{
+			Path:   "postgres.JobLock.TryLock.Scan",
+			Params: errors.Params{"name": name},
+		})
+	}
+	unlock := func() {
+		defer c.Release()
+		ctx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancel()
+		_, err := c.Exec(ctx, `SELECT PG_ADVISORY_UNLOCK(HASHTEXT($1))`, name)
+		if err != nil {
+			log.Println(errors.WrapContext(err, errors.Context{
+				Path:   "postgres.JobLock.unlock.Exec",
+				Params: errors.Params{"name": name},
+			}))
+			// the session may still hold the lock, so the connection must not return to the pool
+			_ = c.Conn().Close(ctx)
+		}
+	}
+	return unlock, true, nil
+}
@@ -0,0 +1,116 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/go-errors-context"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"strconv"
+	"strings"
+)
+
+// NewLog creates a new instance of the repository.
+func NewLog(conn *pgxpool.Pool) app.LogRepo {
+	return Log{conn: conn}
+}
+
+// Log implements a repository.
+type Log struct {
+	conn *pgxpool.Pool
+}
+
+// LastBuild returns the number of the latest build, it is 0 if there are no builds.
+func (r Log) LastBuild(ctx context.Context, kind string, ownerID uint64) (int, error) {
+	var build int
+	q := `SELECT COALESCE(MAX("build"), 0) FROM "logs" WHERE "kind" = $1 AND "owner_id" = $2`
+	err := r.conn.QueryRow(ctx, q, kind, ownerID).Scan(&build)
+	return build, errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Log.LastBuild.Scan",
+		Params: errors.Params{"kind": kind, "owner": ownerID},
+	})
+}
+
+// Add saves the lines of the build output.
+func (r Log) Add(ctx context.Context, kind string, ownerID uint64, build int, lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	q := `INSERT INTO "logs" ("kind", "owner_id", "build", "text") SELECT $1, $2, $3, UNNEST($4::TEXT[])`
+	_, err := r.conn.Exec(ctx, q, kind, ownerID, build, lines)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Log.Add.Exec",
+		Params: errors.Params{"kind": kind, "owner": ownerID, "build": build},
+	})
+}
+
+// FindAfter returns the lines of the build that are saved after the specific one.
+func (r Log) FindAfter(ctx context.Context, kind string, ownerID uint64, build int, afterID uint64) ([]app.LogLine, error) {
+	q := `SELECT "id", "build", "text", "created_at" FROM "logs"
+		WHERE "kind" = $1 AND "owner_id" = $2 AND "build" = $3 AND "id" > $4
+		ORDER BY "id"`
+	res, err := r.find(ctx, q, kind, ownerID, build, afterID)
+	return res, errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Log.FindAfter.find",
+		Params: errors.Params{"kind": kind, "owner": ownerID, "build": build, "after": afterID},
+	})
+}
+
+// FindTail returns the last lines of the build.
+func (r Log) FindTail(ctx context.Context, kind string, ownerID uint64, build int, tail int) ([]app.LogLine, error) {
+	q := `SELECT * FROM (
+			SELECT "id", "build", "text", "created_at" FROM "logs"
+			WHERE "kind" = $1 AND "owner_id" = $2 AND "build" = $3
+			ORDER BY "id" DESC LIMIT $4
+		) "t" ORDER BY "id"`
+	res, err := r.find(ctx, q, kind, ownerID, build, tail)
+	return res, errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Log.FindTail.find",
+		Params: errors.Params{"kind": kind, "owner": ownerID, "build": build, "tail": tail},
+	})
+}
+
+// DeleteBefore deletes the logs of the builds that are older than the specific one.
+func (r Log) DeleteBefore(ctx context.Context, kind string, ownerID uint64, build int) error {
+	q := `DELETE FROM "logs" WHERE "kind" = $1 AND "owner_id" = $2 AND "build" < $3`
+	_, err := r.conn.Exec(ctx, q, kind, ownerID, build)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Log.DeleteBefore.Exec",
+		Params: errors.Params{"kind": kind, "owner": ownerID, "build": build},
+	})
+}
+
+// DeleteByOwners deletes the logs of all builds of the specific owners.
+func (r Log) DeleteByOwners(ctx context.Context, kind string, ownerIDs []uint64) error {
+	if len(ownerIDs) == 0 {
+		return nil
+	}
+	idsStr := make([]string, len(ownerIDs))
+	for i, id := range ownerIDs {
+		idsStr[i] = strconv.FormatUint(id, 10)
+	}
+	q := fmt.Sprintf(`DELETE FROM "logs" WHERE "kind" = $1 AND "owner_id" IN (%s)`, strings.Join(idsStr, ","))
+	_, err := r.conn.Exec(ctx, q, kind)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.Log.DeleteByOwners.Exec",
+		Params: errors.Params{"kind": kind, "owners": ownerIDs},
+	})
+}
+
+func (r Log) find(ctx context.Context, q string, args ...interface{}) ([]app.LogLine, error) {
+	rows, err := r.conn.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make([]app.LogLine, 0)
+	var l app.LogLine
+	for rows.Next() {
+		err = rows.Scan(&l.ID, &l.Build, &l.Text, &l.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, l)
+	}
+	return res, rows.Err()
+}
@@ -2,35 +2,92 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/beldeveloper/app-lego/internal/app"
 	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/app-lego/pkg/crypto"
 	"github.com/beldeveloper/go-errors-context"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"log"
+	"time"
 )
 
 // NewRepository creates a new instance of the repository.
-func NewRepository(conn *pgxpool.Pool) app.RepositoryRepo {
-	return Repository{conn: conn}
+func NewRepository(conn *pgxpool.Pool, workerID app.WorkerID, box app.SecretBox) app.RepositoryRepo {
+	return Repository{conn: conn, workerID: string(workerID), box: box}
 }
 
 // Repository (vcs) implements a (db) repository.
 type Repository struct {
-	conn *pgxpool.Pool
+	conn     *pgxpool.Pool
+	workerID string
+	box      app.SecretBox
+}
+
+// repositoryColumns is a list of the columns that are scanned by Repository.scan.
+const repositoryColumns = `"id", "type", "alias", "name", "status", "updated_at", "filters",
+	"error_msg", "attempts", "retry_at", "credentials", "pull_requests",
+	"base_branch", "merge_preview", "hook_handler_id"`
+
+// scan reads the repository and decrypts its credentials.
+// The repository is still readable if the credentials can't be decrypted, e.g. the secret key is not set
+// (it is reported once on start, so it is not logged here).
+func (r Repository) scan(row pgx.Row, repo *app.Repository) error {
+	var credentials []byte
+	err := row.Scan(&repo.ID, &repo.Type, &repo.Alias, &repo.Name, &repo.Status, &repo.UpdatedAt, &repo.Filters,
+		&repo.ErrorMsg, &repo.Attempts, &repo.RetryAt, &credentials, &repo.PullRequests,
+		&repo.BaseBranch, &repo.MergePreview, &repo.HookHandlerID)
+	if err != nil {
+		return err
+	}
+	repo.Credentials = app.Credentials{}
+	repo.HasCredentials = credentials != nil
+	if credentials == nil {
+		return nil
+	}
+	data, err := r.box.Open(credentials)
+	if err == nil {
+		err = json.Unmarshal(data, &repo.Credentials)
+	}
+	if err != nil && !errors.Is(err, crypto.ErrNoKey) {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "postgres.repository.scan.openCredentials",
+			Params: errors.Params{"repository": repo.ID},
+		}))
+	}
+	return nil
+}
+
+// sealCredentials encrypts the repository credentials, the empty credentials are stored as NULL.
+func (r Repository) sealCredentials(repo app.Repository) ([]byte, error) {
+	if repo.Credentials.Empty() {
+		return nil, nil
+	}
+	data, err := json.Marshal(repo.Credentials)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := r.box.Seal(data)
+	if errors.Is(err, crypto.ErrNoKey) {
+		return nil, fmt.Errorf("%w: credentials require APP_LEGO_SECRET_KEY", errtype.ErrBadInput)
+	}
+	return sealed, err
 }
 
 // FindAll repositories.
 func (r Repository) FindAll(ctx context.Context) ([]app.Repository, error) {
-	q := `SELECT "id", "type", "alias", "name", "status", "updated_at" FROM "repositories" ORDER BY "alias"`
+	q := `SELECT ` + repositoryColumns + ` FROM "repositories" ORDER BY "alias"`
 	rows, err := r.conn.Query(ctx, q)
 	if err != nil {
 		return nil, errors.WrapContext(err, errors.Context{Path: "postgres.repository.FindAll.query"})
 	}
 	defer rows.Close()
 	res := make([]app.Repository, 0, 30)
-	var repo app.Repository
 	for rows.Next() {
-		err = rows.Scan(&repo.ID, &repo.Type, &repo.Alias, &repo.Name, &repo.Status, &repo.UpdatedAt)
+		var repo app.Repository
+		err = r.scan(rows, &repo)
 		if err != nil {
 			return nil, errors.WrapContext(err, errors.Context{Path: "postgres.repository.FindAll.scan"})
 		}
@@ -42,8 +99,8 @@ func (r Repository) FindAll(ctx context.Context) ([]app.Repository, error) {
 // FindByID returns a repository by its ID.
 func (r Repository) FindByID(ctx context.Context, id uint64) (app.Repository, error) {
 	var repo app.Repository
-	q := `SELECT "id", "type", "alias", "name", "status", "updated_at" FROM "repositories" WHERE "id" = $1`
-	err := r.conn.QueryRow(ctx, q, id).Scan(&repo.ID, &repo.Type, &repo.Alias, &repo.Name, &repo.Status, &repo.UpdatedAt)
+	q := `SELECT ` + repositoryColumns + ` FROM "repositories" WHERE "id" = $1`
+	err := r.scan(r.conn.QueryRow(ctx, q, id), &repo)
 	if err == pgx.ErrNoRows {
 		err = errtype.ErrNotFound
 	}
@@ -53,45 +110,153 @@ func (r Repository) FindByID(ctx context.Context, id uint64) (app.Repository, er
 	})
 }
 
-// FindPending returns a repository that is awaiting to be downloaded,
-func (r Repository) FindPending(ctx context.Context) (app.Repository, error) {
+// ClaimOutdated returns the ready repository whose sync is requested or that is synced longer ago than others,
+// if it is synced longer than the age ago. The sync request is withdrawn, so the requests that arrive
+// during the sync aren't lost.
+func (r Repository) ClaimOutdated(ctx context.Context, age time.Duration) (app.Repository, error) {
 	var repo app.Repository
-	q := `SELECT "id", "type", "alias", "name", "status", "updated_at" FROM "repositories" WHERE "status" = $1 LIMIT 1`
-	err := r.conn.QueryRow(ctx, q, app.RepositoryStatusPending).
-		Scan(&repo.ID, &repo.Type, &repo.Alias, &repo.Name, &repo.Status, &repo.UpdatedAt)
+	q := `UPDATE "repositories" SET "sync_requested" = FALSE
+		WHERE "id" = (
+			SELECT "id" FROM "repositories"
+			WHERE "status" = $1 AND ("sync_requested" OR "updated_at" < $2)
+			ORDER BY "sync_requested" DESC, "updated_at" ASC LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING ` + repositoryColumns
+	err := r.scan(r.conn.QueryRow(ctx, q, app.RepositoryStatusReady, time.Now().Add(-age)), &repo)
 	if err == pgx.ErrNoRows {
 		return repo, errtype.ErrNotFound
 	}
-	return repo, errors.WrapContext(err, errors.Context{Path: "postgres.repository.FindPending.scan"})
+	return repo, errors.WrapContext(err, errors.Context{Path: "postgres.repository.ClaimOutdated.scan"})
 }
 
-// FindOutdated return the repository that is ready for pulling updates longer that others.
-func (r Repository) FindOutdated(ctx context.Context) (app.Repository, error) {
+// ClaimPending leases a repository that is awaiting to be downloaded and marks it as downloading.
+// The repository that awaits the retry is skipped until the retry time comes.
+// The downloading repository with the expired lease is claimed as well (it means the process was interrupted earlier).
+func (r Repository) ClaimPending(ctx context.Context) (app.Repository, error) {
 	var repo app.Repository
-	q := `SELECT "id", "type", "alias", "name", "status", "updated_at"
-		FROM "repositories" WHERE "status" = $1 ORDER BY "updated_at" ASC LIMIT 1`
-	err := r.conn.QueryRow(ctx, q, app.RepositoryStatusReady).
-		Scan(&repo.ID, &repo.Type, &repo.Alias, &repo.Name, &repo.Status, &repo.UpdatedAt)
+	q := `UPDATE "repositories" SET "status" = $2, "worker_id" = $3, "lease_expires_at" = NOW() + $4 * INTERVAL '1 second'
+		WHERE "id" = (
+			SELECT "id" FROM "repositories"
+			WHERE ("status" = $1 AND ("retry_at" IS NULL OR "retry_at" <= NOW()))
+				OR ("status" = $2 AND ("lease_expires_at" IS NULL OR "lease_expires_at" < NOW()))
+			ORDER BY "id" LIMIT 1 FOR UPDATE SKIP LOCKED
+		) RETURNING ` + repositoryColumns
+	row := r.conn.QueryRow(ctx, q, app.RepositoryStatusPending, app.RepositoryStatusDownloading, r.workerID, app.LeaseTTL.Seconds())
+	err := r.scan(row, &repo)
 	if err == pgx.ErrNoRows {
 		return repo, errtype.ErrNotFound
 	}
-	return repo, errors.WrapContext(err, errors.Context{Path: "postgres.repository.FindOutdated.scan"})
+	return repo, errors.WrapContext(err, errors.Context{Path: "postgres.repository.ClaimPending.scan"})
+}
+
+// ReleaseDownloading returns to the pending status the downloading repositories
+// that are leased by the current application instance or aren't leased at all.
+// It is intended to be called on start, when the instance can't be downloading anything yet.
+func (r Repository) ReleaseDownloading(ctx context.Context) (int64, error) {
+	q := `UPDATE "repositories" SET "status" = $1, "worker_id" = NULL, "lease_expires_at" = NULL
+		WHERE "status" = $2 AND ("worker_id" = $3 OR "lease_expires_at" IS NULL OR "lease_expires_at" < NOW())`
+	tag, err := r.conn.Exec(ctx, q, app.RepositoryStatusPending, app.RepositoryStatusDownloading, r.workerID)
+	if err != nil {
+		return 0, errors.WrapContext(err, errors.Context{Path: "postgres.repository.ReleaseDownloading.Exec"})
+	}
+	return tag.RowsAffected(), nil
 }
 
 // Add saves a new repository.
 func (r Repository) Add(ctx context.Context, repo app.Repository) (app.Repository, error) {
-	q := `INSERT INTO "repositories" ("type", "alias", "name", "status", "updated_at")
-		VALUES ($1, $2, $3, $4, $5) RETURNING "id"`
-	err := r.conn.QueryRow(ctx, q, repo.Type, repo.Alias, repo.Name, repo.Status, repo.UpdatedAt).Scan(&repo.ID)
-	return repo, errors.WrapContext(err, errors.Context{Path: "postgres.repository.Add.scan"})
+	credentials, err := r.sealCredentials(repo)
+	if err != nil {
+		return repo, errors.WrapContext(err, errors.Context{Path: "postgres.repository.Add.sealCredentials"})
+	}
+	q := `INSERT INTO "repositories" ("type", "alias", "name", "status", "updated_at", "filters", "credentials", "pull_requests",
+		"base_branch", "merge_preview", "hook_handler_id")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING "id"`
+	err = r.conn.QueryRow(ctx, q, repo.Type, repo.Alias, repo.Name, repo.Status, repo.UpdatedAt, repo.Filters, credentials,
+		repo.PullRequests, repo.BaseBranch, repo.MergePreview, repo.HookHandlerID).Scan(&repo.ID)
+	if err != nil {
+		return repo, errors.WrapContext(err, errors.Context{Path: "postgres.repository.Add.scan"})
+	}
+	repo.HasCredentials = credentials != nil
+	r.notifyPending(ctx, repo)
+	return repo, nil
 }
 
 // Update modifies a specific repository.
 func (r Repository) Update(ctx context.Context, repo app.Repository) (app.Repository, error) {
-	q := `UPDATE "repositories" SET "updated_at" = $2, "status" = $3 WHERE "id" = $1`
-	_, err := r.conn.Exec(ctx, q, repo.ID, repo.UpdatedAt, repo.Status)
+	q := `UPDATE "repositories" SET "updated_at" = $2, "status" = $3, "error_msg" = $4, "attempts" = $5, "retry_at" = $6,
+		"worker_id" = NULL, "lease_expires_at" = NULL
+		WHERE "id" = $1`
+	_, err := r.conn.Exec(ctx, q, repo.ID, repo.UpdatedAt, repo.Status, repo.ErrorMsg, repo.Attempts, repo.RetryAt)
+	if err != nil {
+		return repo, errors.WrapContext(err, errors.Context{
+			Path:   "postgres.repository.Add.Exec",
+			Params: errors.Params{"repository": repo.ID, "status": repo.Status},
+		})
+	}
+	r.notifyPending(ctx, repo)
+	return repo, nil
+}
+
+// UpdateSettings modifies the user defined settings of a specific repository.
+func (r Repository) UpdateSettings(ctx context.Context, repo app.Repository) (app.Repository, error) {
+	credentials, err := r.sealCredentials(repo)
+	if err != nil {
+		return repo, errors.WrapContext(err, errors.Context{
+			Path:   "postgres.repository.UpdateSettings.sealCredentials",
+			Params: errors.Params{"repository": repo.ID},
+		})
+	}
+	// the credentials that failed to be decrypted are kept unless they are removed explicitly
+	keep := credentials == nil && repo.HasCredentials
+	repo.HasCredentials = credentials != nil || keep
+	q := `UPDATE "repositories" SET "alias" = $2, "name" = $3, "filters" = $4,
+		"credentials" = CASE WHEN $6 THEN "credentials" ELSE $5 END, "pull_requests" = $7,
+		"base_branch" = $8, "merge_preview" = $9, "hook_handler_id" = $10
+		WHERE "id" = $1`
+	_, err = r.conn.Exec(ctx, q, repo.ID, repo.Alias, repo.Name, repo.Filters, credentials, keep, repo.PullRequests,
+		repo.BaseBranch, repo.MergePreview, repo.HookHandlerID)
 	return repo, errors.WrapContext(err, errors.Context{
-		Path:   "postgres.repository.Add.Exec",
-		Params: errors.Params{"repository": repo.ID, "status": repo.Status},
+		Path:   "postgres.repository.UpdateSettings.Exec",
+		Params: errors.Params{"repository": repo.ID},
+	})
+}
+
+// Delete removes a specific repository.
+func (r Repository) Delete(ctx context.Context, repo app.Repository) error {
+	q := `DELETE FROM "repositories" WHERE "id" = $1`
+	_, err := r.conn.Exec(ctx, q, repo.ID)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.repository.Delete.Exec",
+		Params: errors.Params{"repository": repo.ID},
 	})
 }
+
+// ExtendLease prolongs the lease of the downloading repository that is claimed by the current application instance.
+func (r Repository) ExtendLease(ctx context.Context, repo app.Repository) error {
+	q := `UPDATE "repositories" SET "lease_expires_at" = NOW() + $3 * INTERVAL '1 second'
+		WHERE "id" = $1 AND "worker_id" = $2`
+	_, err := r.conn.Exec(ctx, q, repo.ID, r.workerID, app.LeaseTTL.Seconds())
+	return errors.WrapContext(err, errors.Context{
+		Path:   "postgres.repository.ExtendLease.Exec",
+		Params: errors.Params{"repository": repo.ID},
+	})
+}
+
+// RequestSync marks the repository for the sync and wakes up the sync job of any application instance.
+// The mark outlives the notification, so the repository is synced by the instance that runs the job next.
+func (r Repository) RequestSync(ctx context.Context, repo app.Repository) error {
+	_, err := r.conn.Exec(ctx, `UPDATE "repositories" SET "sync_requested" = TRUE WHERE "id" = $1`, repo.ID)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "postgres.repository.RequestSync.Exec",
+			Params: errors.Params{"repository": repo.ID},
+		})
+	}
+	notify(ctx, r.conn, app.EventRepositorySync, repo.ID)
+	return nil
+}
+
+func (r Repository) notifyPending(ctx context.Context, repo app.Repository) {
+	if repo.Status == app.RepositoryStatusPending {
+		notify(ctx, r.conn, app.EventRepositoryPending, repo.ID)
+	}
+}
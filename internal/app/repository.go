@@ -8,6 +8,10 @@ import (
 const (
 	// RepositoryTypeGit defines the type for Git repositories.
 	RepositoryTypeGit = "git"
+	// RepositoryTypeHg defines the type for Mercurial repositories.
+	RepositoryTypeHg = "hg"
+	// RepositoryTypeLocal defines the type for the plain directories on disk, the name is a path to the directory.
+	RepositoryTypeLocal = "local"
 
 	// RepositoryStatusPending defines the status that means the repository was recently added and awaiting downloading.
 	RepositoryStatusPending = "pending"
@@ -15,41 +19,142 @@ const (
 	RepositoryStatusDownloading = "downloading"
 	// RepositoryStatusReady defines the status that means the repository is ready.
 	RepositoryStatusReady = "ready"
-	// RepositoryStatusFailed defines the status that means the repository was added with an error.
+	// RepositoryStatusFailed defines the status that means the repository downloading failed and won't be retried.
 	RepositoryStatusFailed = "failed"
 )
 
 // Repository is a model that represents a VCS repository.
 type Repository struct {
-	ID        uint64    `json:"id"`
-	Type      string    `json:"type"`
-	Alias     string    `json:"alias"`
-	Name      string    `json:"name"`
-	Status    string    `json:"status"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID        uint64            `json:"id"`
+	Type      string            `json:"type"`
+	Alias     string            `json:"alias"`
+	Name      string            `json:"name"`
+	Status    string            `json:"status"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+	Filters   RepositoryFilters `json:"filters"`
+	ErrorMsg  *string           `json:"errorMsg"`
+	// PullRequests enables syncing the pull/merge requests of the git repository as the branches.
+	PullRequests bool `json:"pullRequests"`
+	// BaseBranch is a name of the head that the merge previews are merged onto.
+	BaseBranch string `json:"baseBranch"`
+	// MergePreview enables building every head of the git repository merged onto the base branch.
+	MergePreview bool `json:"mergePreview"`
+	// HookHandlerID is the hook handler that builds the repository branches, it is nil for the default handler.
+	HookHandlerID *uint64 `json:"hookHandlerId"`
+	// Attempts is a number of the failed download attempts in a row.
+	Attempts int        `json:"attempts"`
+	RetryAt  *time.Time `json:"retryAt"`
+	// Credentials are never exposed, the API reports only whether they are set.
+	Credentials    Credentials `json:"-"`
+	HasCredentials bool        `json:"hasCredentials"`
+}
+
+// Credentials is a model of the secrets that are used for accessing the private remote.
+type Credentials struct {
+	// SSHKey is a private key in the OpenSSH format, e.g. a deploy key.
+	SSHKey string `json:"sshKey"`
+	// KnownHosts is a content of the known_hosts file that is used instead of the system one.
+	KnownHosts string `json:"knownHosts"`
+	// Username is used along with the token for the HTTPS remotes.
+	Username string `json:"username"`
+	// Token is a password or an access token for the HTTPS remotes.
+	Token string `json:"token"`
+}
+
+// Empty checks whether no credential is set.
+func (c Credentials) Empty() bool {
+	return c == Credentials{}
+}
+
+// TokenUsername returns the username that is sent along with the token.
+func (c Credentials) TokenUsername() string {
+	if c.Username == "" {
+		return "git" // the hosting services accept any username along with the access token
+	}
+	return c.Username
+}
+
+// SecretBox describes the encryption of the secrets that are stored in DB.
+type SecretBox interface {
+	Seal(data []byte) ([]byte, error)
+	Open(data []byte) ([]byte, error)
+}
+
+// RepositoryFilters defines which repository branches are built, the rest of them are skipped.
+type RepositoryFilters struct {
+	Heads BranchFilter `json:"heads"`
+	Tags  BranchFilter `json:"tags"`
+	Pulls BranchFilter `json:"pulls"`
+}
+
+// BranchFilter is a set of the branch name patterns. The pattern is a glob (* doesn't match /, ** matches everything)
+// or a regular expression enclosed in slashes, e.g. /^release-\d+$/.
+// The empty include list matches all branches, the exclude list is applied after the include one.
+type BranchFilter struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
 }
 
 // FormAddRepository is a new repository form.
 type FormAddRepository struct {
-	Type  string `json:"type"`
-	Alias string `json:"alias"`
-	Name  string `json:"name"`
+	Type         string            `json:"type"`
+	Alias        string            `json:"alias"`
+	Name         string            `json:"name"`
+	Filters      RepositoryFilters `json:"filters"`
+	PullRequests bool              `json:"pullRequests"`
+	BaseBranch   string            `json:"baseBranch"`
+	MergePreview bool              `json:"mergePreview"`
+	Credentials  Credentials       `json:"credentials"`
+	// HookHandlerID is the hook handler that builds the repository branches, the default handler is used if it is nil.
+	HookHandlerID *uint64 `json:"hookHandlerId"`
+}
+
+// FormUpdateRepository is a repository settings form, the omitted fields aren't changed.
+// The empty credentials object removes the credentials.
+type FormUpdateRepository struct {
+	ID           uint64             `json:"-"`
+	Alias        *string            `json:"alias"`
+	Name         *string            `json:"name"`
+	Filters      *RepositoryFilters `json:"filters"`
+	PullRequests *bool              `json:"pullRequests"`
+	BaseBranch   *string            `json:"baseBranch"`
+	MergePreview *bool              `json:"mergePreview"`
+	Credentials  *Credentials       `json:"credentials"`
+	// HookHandlerID assigns the hook handler to the repository, 0 assigns the default handler.
+	HookHandlerID *uint64 `json:"hookHandlerId"`
+}
+
+// WebhookPush is a model of the push notification that is received from the VCS hosting.
+type WebhookPush struct {
+	Provider string
+	Ref      string
+	URLs     []string
 }
 
 // RepositorySvc describes the repository service.
 type RepositorySvc interface {
 	List(context.Context) ([]Repository, error)
 	Add(context.Context, FormAddRepository) (Repository, error)
+	Update(context.Context, FormUpdateRepository) (Repository, error)
+	Delete(context.Context, uint64) error
+	Download(context.Context, uint64) error
+	Recover(ctx context.Context) error
 	DownloadJob(ctx context.Context) error
 	SyncJob(ctx context.Context) error
+	Push(ctx context.Context, p WebhookPush) error
 }
 
 // RepositoryRepo describes interactions with the repository DB.
 type RepositoryRepo interface {
 	FindAll(ctx context.Context) ([]Repository, error)
 	FindByID(ctx context.Context, id uint64) (Repository, error)
-	FindPending(ctx context.Context) (Repository, error)
-	FindOutdated(ctx context.Context) (Repository, error)
+	ClaimOutdated(ctx context.Context, age time.Duration) (Repository, error)
+	ClaimPending(ctx context.Context) (Repository, error)
+	ReleaseDownloading(ctx context.Context) (int64, error)
 	Add(ctx context.Context, r Repository) (Repository, error)
 	Update(ctx context.Context, r Repository) (Repository, error)
+	UpdateSettings(ctx context.Context, r Repository) (Repository, error)
+	Delete(ctx context.Context, r Repository) error
+	ExtendLease(ctx context.Context, r Repository) error
+	RequestSync(ctx context.Context, r Repository) error
 }
@@ -15,15 +15,21 @@ func NewBranch(
 	vcsSvc app.VcsSvc,
 	deploySvc app.DeploymentSvc,
 	hookSvc pkg.HookSvc,
+	logSvc app.LogSvc,
 	branchRepo app.BranchRepo,
 	repRepo app.RepositoryRepo,
+	remover app.BranchRemover,
+	callbackAddr app.CallbackAddr,
 ) app.BranchSvc {
 	return Branch{
-		vcsSvc:     vcsSvc,
-		deploySvc:  deploySvc,
-		hookSvc:    hookSvc,
-		branchRepo: branchRepo,
-		repRepo:    repRepo,
+		vcsSvc:       vcsSvc,
+		deploySvc:    deploySvc,
+		hookSvc:      hookSvc,
+		logSvc:       logSvc,
+		branchRepo:   branchRepo,
+		repRepo:      repRepo,
+		remover:      remover,
+		callbackAddr: string(callbackAddr),
 	}
 }
 
@@ -32,14 +38,24 @@ type Branch struct {
 	vcsSvc     app.VcsSvc
 	deploySvc  app.DeploymentSvc
 	hookSvc    pkg.HookSvc
+	logSvc     app.LogSvc
 	branchRepo app.BranchRepo
 	repRepo    app.RepositoryRepo
+	remover    app.BranchRemover
+	// callbackAddr is the address of the callback service, the builds are synchronous if it is empty.
+	callbackAddr string
 }
 
-// List all branches.
-func (s Branch) List(ctx context.Context) ([]app.Branch, error) {
-	res, err := s.branchRepo.FindAll(ctx)
-	return res, errors.WrapContext(err, errors.Context{Path: "svc.Branch.List.FindAll"})
+// List all branches in the specific order, they are ordered by the name by default.
+func (s Branch) List(ctx context.Context, sort string) ([]app.Branch, error) {
+	if sort == "" {
+		sort = app.BranchSortName
+	}
+	res, err := s.branchRepo.FindSorted(ctx, sort)
+	return res, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Branch.List.FindSorted",
+		Params: errors.Params{"sort": sort},
+	})
 }
 
 // Rebuild the particular branch.
@@ -48,7 +64,7 @@ func (s Branch) Rebuild(ctx context.Context, id uint64) error {
 	if err != nil {
 		return errors.WrapContext(err, errors.Context{Path: "svc.Branch.Rebuild.FindByID"})
 	}
-	if b.Status != app.BranchStatusFailed && b.Status != app.BranchStatusSkipped {
+	if b.Status != app.BranchStatusFailed && b.Status != app.BranchStatusSkipped && b.Status != app.BranchStatusConflict {
 		return errors.WrapContext(errtype.ErrBadInput, errors.Context{Path: "svc.Branch.Rebuild.checkStatus"})
 	}
 	b.Status = app.BranchStatusEnqueued
@@ -62,7 +78,33 @@ func (s Branch) Rebuild(ctx context.Context, id uint64) error {
 	return nil
 }
 
+// Changelog returns the commits of the branch since the specific revision.
+func (s Branch) Changelog(ctx context.Context, id uint64, from string) (app.Changelog, error) {
+	b, err := s.branchRepo.FindByID(ctx, id)
+	if err != nil {
+		return app.Changelog{}, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.Changelog.FindByID",
+			Params: errors.Params{"branch": id},
+		})
+	}
+	r, err := s.repRepo.FindByID(ctx, b.RepositoryID)
+	if err != nil {
+		return app.Changelog{}, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.Changelog.findRepo",
+			Params: errors.Params{"repository": b.RepositoryID},
+		})
+	}
+	res, err := changelog(ctx, s.vcsSvc, r, b, from)
+	return res, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Branch.Changelog.changelog",
+		Params: errors.Params{"branch": id, "from": from},
+	})
+}
+
 // Sync all repository branches with VCS.
+// The merge previews are rebuilt when either the head or the base branch is changed,
+// the integration branches are rebuilt when any component is changed and deleted along with any component.
+// The unchanged branches are skipped or enqueued again if the repository filters are changed.
 func (s Branch) Sync(ctx context.Context, r app.Repository) error {
 	vcsBranches, err := s.vcsSvc.Branches(ctx, r)
 	if err != nil {
@@ -71,6 +113,9 @@ func (s Branch) Sync(ctx context.Context, r app.Repository) error {
 			Params: errors.Params{"repository": r.ID},
 		})
 	}
+	if r.MergePreview {
+		vcsBranches = append(vcsBranches, mergePreviews(r, vcsBranches)...)
+	}
 	old, err := s.branchRepo.FindByRepository(ctx, r)
 	if err != nil {
 		return errors.WrapContext(err, errors.Context{
@@ -78,22 +123,38 @@ func (s Branch) Sync(ctx context.Context, r app.Repository) error {
 			Params: errors.Params{"repository": r.ID},
 		})
 	}
+	matcher, err := newBranchMatcher(r.Filters)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.Sync.newBranchMatcher",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
 	oldMap := make(map[string]app.Branch)
 	for _, b := range old {
 		oldMap[fmt.Sprintf("%s/%s", b.Type, b.Name)] = b
 	}
+	commits := s.commitLoader(r)
 	keepMap := make(map[uint64]bool)
+	hashes := make(map[uint64]string) // the current revisions of the kept branches
 	for _, b := range vcsBranches {
+		status := app.BranchStatusEnqueued
+		if !matcher.match(b) {
+			status = app.BranchStatusSkipped
+		}
 		oldBranch, exists := oldMap[fmt.Sprintf("%s/%s", b.Type, b.Name)]
 		if !exists {
-			_, err = s.branchRepo.Add(ctx, app.Branch{
+			newBranch := app.Branch{
 				RepositoryID: r.ID,
 				Type:         b.Type,
 				Name:         b.Name,
 				Hash:         b.Hash,
-				Status:       app.BranchStatusEnqueued,
-			})
-			if err != nil {
+				BaseHash:     b.BaseHash,
+				Status:       status,
+			}
+			newBranch.SetCommit(commits(ctx, b.Hash))
+			_, err = s.branchRepo.Add(ctx, newBranch)
+			if err != nil && !errors.Is(err, errtype.ErrConflict) {
 				return errors.WrapContext(err, errors.Context{
 					Path:   "svc.Branch.Sync.Add",
 					Params: errors.Params{"branchName": b.Name, "branchType": b.Type, "branchHash": b.Hash},
@@ -102,12 +163,21 @@ func (s Branch) Sync(ctx context.Context, r app.Repository) error {
 			continue
 		}
 		keepMap[oldBranch.ID] = true
-		if b.Hash == oldBranch.Hash ||
-			oldBranch.Status == app.BranchStatusBuilding || oldBranch.Status == app.BranchStatusEnqueued {
+		hashes[oldBranch.ID] = b.Hash
+		if b.Hash == oldBranch.Hash && b.BaseHash == oldBranch.BaseHash {
+			err = s.refilter(ctx, oldBranch, status)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if oldBranch.Status == app.BranchStatusBuilding || oldBranch.Status == app.BranchStatusEnqueued {
 			continue
 		}
 		oldBranch.Hash = b.Hash
-		oldBranch.Status = app.BranchStatusEnqueued
+		oldBranch.BaseHash = b.BaseHash
+		oldBranch.Status = status
+		oldBranch.SetCommit(commits(ctx, b.Hash))
 		_, err = s.branchRepo.Update(ctx, oldBranch)
 		if err != nil {
 			return errors.WrapContext(err, errors.Context{
@@ -116,38 +186,139 @@ func (s Branch) Sync(ctx context.Context, r app.Repository) error {
 			})
 		}
 	}
-	del := make([]uint64, 0, len(old))
+	for _, b := range old {
+		if b.Type != app.BranchTypeIntegration {
+			continue
+		}
+		components, ok := integrationComponents(b, hashes)
+		if !ok {
+			continue
+		}
+		keepMap[b.ID] = true
+		hash := integrationHash(components)
+		if hash == b.Hash || b.Status == app.BranchStatusBuilding || b.Status == app.BranchStatusEnqueued {
+			continue
+		}
+		b.Hash = hash
+		b.Components = components
+		b.Status = app.BranchStatusEnqueued
+		b.ErrorMsg = nil
+		_, err = s.branchRepo.Update(ctx, b)
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Branch.Sync.updateIntegration",
+				Params: errors.Params{"branch": b.ID},
+			})
+		}
+	}
+	del := make([]app.Branch, 0, len(old))
 	for _, b := range old {
 		if !keepMap[b.ID] {
-			del = append(del, b.ID)
+			del = append(del, b)
 		}
 	}
-	err = s.branchRepo.DeleteByIDs(ctx, del)
+	err = s.remover.Remove(ctx, r, del)
 	if err != nil {
 		log.Println(errors.WrapContext(err, errors.Context{
-			Path:   "svc.Branch.Sync.DeleteByIDs",
-			Params: errors.Params{"ids": del},
+			Path:   "svc.Branch.Sync.Remove",
+			Params: errors.Params{"repository": r.ID},
 		}))
 	}
-	err = s.hookSvc.CleanBranches(ctx, del)
+	return nil
+}
+
+// refilter moves the unchanged branch between the skipped and enqueued statuses if the filters are changed.
+// The built branches are kept as they are.
+func (s Branch) refilter(ctx context.Context, b app.Branch, status string) error {
+	switch {
+	case b.Status == app.BranchStatusSkipped && status == app.BranchStatusEnqueued:
+	case b.Status == app.BranchStatusEnqueued && status == app.BranchStatusSkipped:
+	default:
+		return nil
+	}
+	b.Status = status
+	b.ErrorMsg = nil
+	_, err := s.branchRepo.Update(ctx, b)
 	if err != nil {
-		log.Println(errors.WrapContext(err, errors.Context{
-			Path:   "svc.Branch.Sync.CleanBranches",
-			Params: errors.Params{"ids": del},
-		}))
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.refilter.Update",
+			Params: errors.Params{"branch": b.ID, "status": status},
+		})
+	}
+	log.Printf("The branch #%d is %s by the repository filters\n", b.ID, status)
+	return nil
+}
+
+// Delete all repository branches.
+func (s Branch) Delete(ctx context.Context, r app.Repository) error {
+	branches, err := s.branchRepo.FindByRepository(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.Delete.FindByRepository",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	return errors.WrapContext(s.remover.Remove(ctx, r, branches), errors.Context{
+		Path:   "svc.Branch.Delete.Remove",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// CheckIdle rejects the changes of the repository working trees while any branch of the repository is building.
+func (s Branch) CheckIdle(ctx context.Context, r app.Repository) error {
+	branches, err := s.branchRepo.FindByRepository(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.CheckIdle.FindByRepository",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	for _, b := range branches {
+		if b.Status == app.BranchStatusBuilding {
+			return fmt.Errorf("%w: branch #%d is building, try again later", errtype.ErrBadInput, b.ID)
+		}
+	}
+	return nil
+}
+
+// Requeue enqueues the ready branches of the repository for building again,
+// e.g. when their working trees are dropped along with the old repository directory.
+func (s Branch) Requeue(ctx context.Context, r app.Repository) error {
+	branches, err := s.branchRepo.FindByRepository(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.Requeue.FindByRepository",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	for _, b := range branches {
+		if b.Status != app.BranchStatusReady {
+			continue
+		}
+		b.Status = app.BranchStatusEnqueued
+		_, err = s.branchRepo.Update(ctx, b)
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Branch.Requeue.Update",
+				Params: errors.Params{"branch": b.ID},
+			})
+		}
 	}
 	return nil
 }
 
-// BuildJob fetches enqueued branches and builds them.
+// BuildJob claims the enqueued branch and builds it.
 func (s Branch) BuildJob(ctx context.Context) error {
-	b, err := s.branchRepo.FindEnqueued(ctx)
+	b, err := s.branchRepo.ClaimEnqueued(ctx)
 	if err != nil {
 		if !errors.Is(err, errtype.ErrNotFound) {
-			return errors.WrapContext(err, errors.Context{Path: "svc.Branch.BuildJob.FindEnqueued"})
+			return errors.WrapContext(err, errors.Context{Path: "svc.Branch.BuildJob.ClaimEnqueued"})
 		}
-		return nil
+		return errtype.ErrIdle
 	}
+	defer keepLease(ctx, func(ctx context.Context) error {
+		return s.branchRepo.ExtendLease(ctx, b)
+	})()
 	r, err := s.repRepo.FindByID(ctx, b.RepositoryID)
 	if err != nil {
 		log.Println(errors.WrapContext(err, errors.Context{
@@ -160,12 +331,29 @@ func (s Branch) BuildJob(ctx context.Context) error {
 		s.updateStatus(ctx, b)
 		return nil
 	}
-	b.Status = app.BranchStatusBuilding
-	if !s.updateStatus(ctx, b) {
-		return nil
-	}
+	buildLog := startLog(ctx, s.logSvc, app.LogKindBranch, b.ID)
+	buildLog.Write(ctx, fmt.Sprintf("Build the branch %s at %s\n", b.Name, b.Hash))
 	err = s.vcsSvc.SwitchBranch(ctx, r, b)
 	if err != nil {
+		buildLog.Write(ctx, err.Error()+"\n")
+		buildLog.Close(ctx)
+		if ctx.Err() != nil {
+			s.abandon(b)
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Branch.BuildJob.SwitchBranch",
+				Params: errors.Params{"branch": b.ID},
+			})
+		}
+		if errors.Is(err, errtype.ErrConflict) {
+			// the conflict is resolved by pushing to the merged branches, so there is nothing to retry
+			b.Status = app.BranchStatusConflict
+			errorMsg := fmt.Sprintf("Can't merge branch id=%d; err=%v", b.ID, err)
+			b.ErrorMsg = &errorMsg
+			if s.updateStatus(ctx, b) {
+				log.Printf("The branch #%d has merge conflicts\n", b.ID)
+			}
+			return nil
+		}
 		b.Status = app.BranchStatusFailed
 		errorMsg := fmt.Sprintf("Can't switch branch id=%d; err=%v", b.ID, err)
 		b.ErrorMsg = &errorMsg
@@ -175,6 +363,7 @@ func (s Branch) BuildJob(ctx context.Context) error {
 			Params: errors.Params{"branch": b.ID},
 		})
 	}
+	commit := s.headCommit(ctx, r, &b)
 	buildRes, err := s.hookSvc.BuildBranch(ctx, pkg.HookBuildBranchReq{
 		Repo: pkg.HookRepo{
 			ID:    r.ID,
@@ -182,14 +371,45 @@ func (s Branch) BuildJob(ctx context.Context) error {
 			Alias: r.Alias,
 		},
 		Branch: pkg.HookBranch{
-			ID:     b.ID,
-			RepoID: b.RepositoryID,
-			Type:   b.Type,
-			Name:   b.Name,
-			Hash:   b.Hash,
+			ID:       b.ID,
+			RepoID:   b.RepositoryID,
+			Type:     b.Type,
+			Name:     b.Name,
+			Hash:     b.Hash,
+			BaseHash: b.BaseHash,
+		},
+		Commit: pkg.HookCommit{
+			Hash:        commit.Hash,
+			Author:      commit.Author,
+			CommittedAt: commit.CommittedAt,
+			Subject:     commit.Subject,
+			Message:     commit.Message,
+		},
+		Dir: s.vcsSvc.BranchDir(r, b),
+		Log: func(text string) {
+			buildLog.Write(ctx, text)
 		},
+		CallbackAddr: s.callbackAddr,
 	})
 	if err != nil {
+		buildLog.Write(ctx, err.Error()+"\n")
+	}
+	buildLog.Close(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			s.abandon(b)
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Branch.BuildJob.BuildBranch",
+				Params: errors.Params{"branch": b.ID},
+			})
+		}
+		if errors.Is(err, errtype.ErrUnavailable) {
+			s.postpone(ctx, b, err)
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Branch.BuildJob.BuildBranch",
+				Params: errors.Params{"branch": b.ID},
+			})
+		}
 		b.Status = app.BranchStatusFailed
 		errorMsg := err.Error()
 		b.ErrorMsg = &errorMsg
@@ -199,26 +419,184 @@ func (s Branch) BuildJob(ctx context.Context) error {
 			Params: errors.Params{"branch": b.ID},
 		})
 	}
-	switch buildRes.Status {
+	if buildRes.Status == pkg.HookStatusAccepted && s.callbackAddr != "" {
+		// the hook handler reports the build to the callback service, so the worker is free for the next one
+		b.JobID = buildRes.JobID
+		err = s.branchRepo.Accept(ctx, b)
+		if errors.Is(err, errtype.ErrNotFound) {
+			// the lease expired and the branch is reclaimed or deleted meanwhile, the report of this job is rejected
+			log.Printf("The branch #%d build lease is lost, the accepted job is abandoned; job=%s\n", b.ID, b.JobID)
+			return nil
+		}
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Branch.BuildJob.Accept",
+				Params: errors.Params{"branch": b.ID, "job": b.JobID},
+			})
+		}
+		log.Printf("The branch #%d build is accepted by hook handler; job=%s\n", b.ID, b.JobID)
+		return nil
+	}
+	return errors.WrapContext(s.finish(ctx, b, buildRes), errors.Context{
+		Path:   "svc.Branch.BuildJob.finish",
+		Params: errors.Params{"branch": b.ID},
+	})
+}
+
+// Report saves the progress or the result of the build that the hook handler accepted.
+// The reports of the unknown or superseded builds are rejected, so the hook handler can stop them.
+func (s Branch) Report(ctx context.Context, r app.BuildReport) error {
+	if r.Progress < 0 || r.Progress > 100 {
+		return fmt.Errorf("%w: progress must be from 0 to 100", errtype.ErrBadInput)
+	}
+	if r.Status == pkg.HookStatusAccepted {
+		return fmt.Errorf("%w: the build is accepted already", errtype.ErrBadInput)
+	}
+	b, err := s.branchRepo.FindByID(ctx, r.BranchID)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.Report.FindByID",
+			Params: errors.Params{"branch": r.BranchID},
+		})
+	}
+	if r.JobID != "" && b.JobID == "" && b.Status == app.BranchStatusBuilding {
+		// the report may come before the build worker saves the accepted job
+		return errors.WrapContext(fmt.Errorf("%w: the build job isn't saved yet", errtype.ErrConflict), errors.Context{
+			Path:   "svc.Branch.Report.checkJob",
+			Params: errors.Params{"branch": b.ID, "job": r.JobID},
+		})
+	}
+	if r.JobID == "" || b.JobID != r.JobID || b.Status != app.BranchStatusBuilding {
+		return errors.WrapContext(fmt.Errorf("%w: the build job isn't running", errtype.ErrNotFound), errors.Context{
+			Path:   "svc.Branch.Report.checkJob",
+			Params: errors.Params{"branch": b.ID, "job": r.JobID},
+		})
+	}
+	if r.Log != "" {
+		buildLog := resumeLog(ctx, s.logSvc, app.LogKindBranch, b.ID)
+		buildLog.Write(ctx, r.Log)
+		buildLog.Close(ctx)
+	}
+	if r.Status == "" {
+		b.Progress = r.Progress
+		b.Stage = r.Stage
+		return errors.WrapContext(s.branchRepo.UpdateProgress(ctx, b), errors.Context{
+			Path:   "svc.Branch.Report.UpdateProgress",
+			Params: errors.Params{"branch": b.ID, "job": b.JobID},
+		})
+	}
+	return errors.WrapContext(s.finish(ctx, b, pkg.HookBuildBranchResp{Status: r.Status, ErrorMsg: r.ErrorMsg}), errors.Context{
+		Path:   "svc.Branch.Report.finish",
+		Params: errors.Params{"branch": b.ID, "job": b.JobID},
+	})
+}
+
+// finish saves the result of the build and rebuilds the deployments with the ready branch.
+// The result of the superseded build is not found.
+func (s Branch) finish(ctx context.Context, b app.Branch, res pkg.HookBuildBranchResp) error {
+	switch res.Status {
 	case app.BranchStatusSkipped, app.BranchStatusReady:
-		b.Status = buildRes.Status
+		b.Status = res.Status
+		b.ErrorMsg = nil // e.g. the message of the postponed attempt
 	default:
 		b.Status = app.BranchStatusFailed
-		b.ErrorMsg = buildRes.ErrorMsg
-		log.Printf("The branch #%d was not built, see details in hook handler; status=%s\n", b.ID, buildRes.Status)
+		b.ErrorMsg = res.ErrorMsg
+		log.Printf("The branch #%d was not built, see details in hook handler; status=%s\n", b.ID, res.Status)
 	}
-	if !s.updateStatus(ctx, b) || b.Status != app.BranchStatusReady {
+	err := s.branchRepo.UpdateStatus(ctx, b)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.finish.UpdateStatus",
+			Params: errors.Params{"branch": b.ID, "job": b.JobID, "status": b.Status},
+		})
+	}
+	if b.Status != app.BranchStatusReady {
 		return nil
 	}
 	log.Printf("The branch #%d is built\n", b.ID)
 	err = s.deploySvc.RebuildWithBranch(ctx, b)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Branch.finish.RebuildWithBranch",
+		Params: errors.Params{"branch": b.ID},
+	})
+}
+
+// headCommit looks up the metadata of the branch head commit and saves it to the branch.
+// The errors are logged only, and the integration branch has no commit of its own, so its metadata is empty.
+func (s Branch) headCommit(ctx context.Context, r app.Repository, b *app.Branch) app.Commit {
+	if b.Type == app.BranchTypeIntegration {
+		return app.Commit{}
+	}
+	commit, err := s.vcsSvc.Commit(ctx, r, b.Hash)
 	if err != nil {
-		return errors.WrapContext(err, errors.Context{
-			Path:   "svc.Branch.BuildJob.RebuildWithBranch",
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.headCommit.Commit",
 			Params: errors.Params{"branch": b.ID},
-		})
+		}))
+		return commit
 	}
-	return nil
+	b.SetCommit(commit)
+	err = s.branchRepo.UpdateCommit(ctx, *b)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.headCommit.UpdateCommit",
+			Params: errors.Params{"branch": b.ID},
+		}))
+	}
+	return commit
+}
+
+// commitLoader returns a function that looks up the commit metadata for the updated branches.
+// The repository is fetched once before the first lookup, so the new commits are available locally.
+// The lookup errors are logged and the empty metadata is returned, the branch is synced anyway.
+func (s Branch) commitLoader(r app.Repository) func(ctx context.Context, hash string) app.Commit {
+	var fetched bool
+	return func(ctx context.Context, hash string) app.Commit {
+		if !fetched {
+			fetched = true
+			err := s.vcsSvc.Fetch(ctx, r)
+			if err != nil {
+				log.Println(errors.WrapContext(err, errors.Context{
+					Path:   "svc.Branch.commitLoader.Fetch",
+					Params: errors.Params{"repository": r.ID},
+				}))
+			}
+		}
+		c, err := s.vcsSvc.Commit(ctx, r, hash)
+		if err != nil {
+			log.Println(errors.WrapContext(err, errors.Context{
+				Path:   "svc.Branch.commitLoader.Commit",
+				Params: errors.Params{"repository": r.ID, "hash": hash},
+			}))
+		}
+		return c
+	}
+}
+
+// abandon enqueues the interrupted branch again, so it is built on the next start.
+func (s Branch) abandon(b app.Branch) {
+	ctx, cancel := context.WithTimeout(context.Background(), AbandonTimeout)
+	defer cancel()
+	b.Status = app.BranchStatusEnqueued
+	b.ErrorMsg = nil
+	if s.updateStatus(ctx, b) {
+		log.Printf("The branch #%d build is interrupted and enqueued again\n", b.ID)
+	}
+}
+
+// postpone enqueues the branch again when the hook handler is unreachable, it is built after the retry delay.
+func (s Branch) postpone(ctx context.Context, b app.Branch, err error) {
+	errorMsg := fmt.Sprintf("The hook handler is unavailable, the build is retried in %s; err=%v", HookRetryDelay, err)
+	b.ErrorMsg = &errorMsg
+	err = s.branchRepo.Postpone(ctx, b, HookRetryDelay)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.Branch.postpone.Postpone",
+			Params: errors.Params{"branch": b.ID},
+		}))
+		return
+	}
+	log.Printf("The branch #%d build is postponed, the hook handler is unavailable\n", b.ID)
 }
 
 func (s Branch) updateStatus(ctx context.Context, b app.Branch) bool {
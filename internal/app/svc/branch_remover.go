@@ -0,0 +1,72 @@
+package svc
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/pkg"
+	"github.com/beldeveloper/go-errors-context"
+	"log"
+)
+
+// NewBranchRemover creates a new instance of the service that removes the branches.
+func NewBranchRemover(
+	vcsSvc app.VcsSvc,
+	hookSvc pkg.HookSvc,
+	logSvc app.LogSvc,
+	branchRepo app.BranchRepo,
+) app.BranchRemover {
+	return BranchRemover{
+		vcsSvc:     vcsSvc,
+		hookSvc:    hookSvc,
+		logSvc:     logSvc,
+		branchRepo: branchRepo,
+	}
+}
+
+// BranchRemover is a service that removes the branches, it is shared by the branch and deployment services.
+type BranchRemover struct {
+	vcsSvc     app.VcsSvc
+	hookSvc    pkg.HookSvc
+	logSvc     app.LogSvc
+	branchRepo app.BranchRepo
+}
+
+// Remove deletes the branches and asks the hook handler and VCS to clean them up.
+func (s BranchRemover) Remove(ctx context.Context, r app.Repository, branches []app.Branch) error {
+	if len(branches) == 0 {
+		return nil
+	}
+	ids := make([]uint64, len(branches))
+	for i, b := range branches {
+		ids[i] = b.ID
+	}
+	err := s.branchRepo.DeleteByIDs(ctx, ids)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.BranchRemover.Remove.DeleteByIDs",
+			Params: errors.Params{"ids": ids},
+		})
+	}
+	err = s.hookSvc.CleanBranches(ctx, pkg.HookRepo{ID: r.ID, Type: r.Type, Alias: r.Alias}, ids)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.BranchRemover.Remove.CleanBranches",
+			Params: errors.Params{"ids": ids},
+		}))
+	}
+	err = s.logSvc.Delete(ctx, app.LogKindBranch, ids)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.BranchRemover.Remove.deleteLogs",
+			Params: errors.Params{"ids": ids},
+		}))
+	}
+	err = s.vcsSvc.CleanBranches(ctx, r, branches)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.BranchRemover.Remove.cleanWorktrees",
+			Params: errors.Params{"ids": ids},
+		}))
+	}
+	return nil
+}
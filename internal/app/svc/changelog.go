@@ -0,0 +1,44 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"regexp"
+)
+
+// ChangelogMaxCommits defines the maximal number of commits in the changelog.
+const ChangelogMaxCommits = 200
+
+// revisionRx matches the full or abbreviated commit hash.
+var revisionRx = regexp.MustCompile("^[0-9a-fA-F]{4,64}$")
+
+// changelog returns the commits of the branch since the specific revision.
+// The recent commits of the branch are returned if the revision is empty.
+func changelog(ctx context.Context, vcsSvc app.VcsSvc, r app.Repository, b app.Branch, from string) (app.Changelog, error) {
+	res := app.Changelog{BranchID: b.ID, From: from, To: b.Hash, Commits: []app.Commit{}}
+	if from != "" && !revisionRx.MatchString(from) {
+		return res, fmt.Errorf("%w: invalid revision: %s", errtype.ErrBadInput, from)
+	}
+	if b.Type == app.BranchTypeIntegration {
+		return res, fmt.Errorf("%w: the integration branch has no history of its own; branch=%d", errtype.ErrBadInput, b.ID)
+	}
+	if r.Status != app.RepositoryStatusReady {
+		return res, fmt.Errorf("%w: the repository is not downloaded; repository=%d", errtype.ErrBadInput, r.ID)
+	}
+	if from == b.Hash {
+		return res, nil
+	}
+	// one extra commit tells that the changelog is truncated
+	commits, err := vcsSvc.Log(ctx, r, from, b.Hash, ChangelogMaxCommits+1)
+	if err != nil {
+		return res, err
+	}
+	if len(commits) > ChangelogMaxCommits {
+		commits = commits[:ChangelogMaxCommits]
+		res.Truncated = true
+	}
+	res.Commits = commits
+	return res, nil
+}
@@ -0,0 +1,103 @@
+package svc
+
+import (
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/pkg/os"
+	"log"
+	"strings"
+)
+
+// gitCredentialHelper answers the git credential requests with the username and token from the environment,
+// so the secrets never appear in the command arguments.
+const gitCredentialHelper = `!f() { test "$1" = get && echo "username=$APP_LEGO_GIT_USERNAME" && echo "password=$APP_LEGO_GIT_TOKEN"; }; f`
+
+// gitEnv returns the environment that makes git use the repository credentials and never prompt for them.
+// The cleanup function removes the temporary key files, it must be called when the command is done.
+func gitEnv(c app.Credentials) ([]string, func(), error) {
+	var secrets remoteSecrets
+	ssh, err := secrets.sshCommand(c)
+	if err != nil {
+		secrets.cleanup()
+		return nil, nil, err
+	}
+	env := []string{"GIT_TERMINAL_PROMPT=0", "GIT_SSH_COMMAND=" + ssh}
+	if c.Token != "" {
+		env = append(env,
+			// the empty helper resets the helpers configured on the host
+			"GIT_CONFIG_COUNT=2",
+			"GIT_CONFIG_KEY_0=credential.helper",
+			"GIT_CONFIG_VALUE_0=",
+			"GIT_CONFIG_KEY_1=credential.helper",
+			"GIT_CONFIG_VALUE_1="+gitCredentialHelper,
+			"APP_LEGO_GIT_USERNAME="+c.TokenUsername(),
+			"APP_LEGO_GIT_TOKEN="+c.Token,
+		)
+	}
+	return env, secrets.cleanup, nil
+}
+
+// hgEnv returns the environment that makes Mercurial use the repository credentials and never prompt for them.
+// Mercurial reads the secrets from the temporary config file instead of the user one.
+// The cleanup function removes the temporary files, it must be called when the command is done.
+func hgEnv(c app.Credentials) ([]string, func(), error) {
+	var secrets remoteSecrets
+	ssh, err := secrets.sshCommand(c)
+	if err != nil {
+		secrets.cleanup()
+		return nil, nil, err
+	}
+	var cfg strings.Builder
+	fmt.Fprintf(&cfg, "[ui]\ninteractive = false\nssh = %s\n", ssh)
+	if c.Token != "" {
+		fmt.Fprintf(&cfg, "[auth]\napp_lego.prefix = *\napp_lego.username = %s\napp_lego.password = %s\n", c.TokenUsername(), c.Token)
+	}
+	hgrc, err := secrets.file("app-lego-hgrc-*", cfg.String())
+	if err != nil {
+		secrets.cleanup()
+		return nil, nil, err
+	}
+	return []string{"HGPLAIN=1", "HGRCPATH=" + hgrc}, secrets.cleanup, nil
+}
+
+// remoteSecrets keeps the credentials in the temporary files for the duration of the VCS command.
+type remoteSecrets struct {
+	files []string
+}
+
+// sshCommand returns the non-interactive ssh command that uses the repository key and known hosts.
+func (s *remoteSecrets) sshCommand(c app.Credentials) (string, error) {
+	ssh := []string{"ssh", "-o", "BatchMode=yes"}
+	if c.SSHKey != "" {
+		key, err := s.file("app-lego-key-*", c.SSHKey)
+		if err != nil {
+			return "", err
+		}
+		ssh = append(ssh, "-i", "'"+key+"'", "-o", "IdentitiesOnly=yes")
+	}
+	if c.KnownHosts != "" {
+		knownHosts, err := s.file("app-lego-known-hosts-*", c.KnownHosts)
+		if err != nil {
+			return "", err
+		}
+		ssh = append(ssh, "-o", "'UserKnownHostsFile="+knownHosts+"'", "-o", "StrictHostKeyChecking=yes")
+	}
+	return strings.Join(ssh, " "), nil
+}
+
+func (s *remoteSecrets) file(pattern, content string) (string, error) {
+	f, err := os.TempFile(pattern, content)
+	if err != nil {
+		return "", err
+	}
+	s.files = append(s.files, f)
+	return f, nil
+}
+
+func (s *remoteSecrets) cleanup() {
+	for _, f := range s.files {
+		if err := os.RemoveFile(f); err != nil {
+			log.Println(err)
+		}
+	}
+}
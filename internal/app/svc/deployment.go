@@ -2,34 +2,46 @@ package svc
 
 import (
 	"context"
+	"fmt"
 	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
 	"github.com/beldeveloper/app-lego/pkg"
 	"github.com/beldeveloper/go-errors-context"
 	"log"
+	"strings"
 	"time"
 )
 
 // NewDeployment creates a new instance of the deployments service.
 func NewDeployment(
+	vcsSvc app.VcsSvc,
 	hookSvc pkg.HookSvc,
+	logSvc app.LogSvc,
 	deployRepo app.DeploymentRepo,
 	branchRepo app.BranchRepo,
 	repRepo app.RepositoryRepo,
+	remover app.BranchRemover,
 ) app.DeploymentSvc {
 	return Deployment{
+		vcsSvc:     vcsSvc,
 		hookSvc:    hookSvc,
+		logSvc:     logSvc,
 		deployRepo: deployRepo,
 		branchRepo: branchRepo,
 		repRepo:    repRepo,
+		remover:    remover,
 	}
 }
 
 // Deployment is a service that manages the deployments.
 type Deployment struct {
+	vcsSvc     app.VcsSvc
 	hookSvc    pkg.HookSvc
+	logSvc     app.LogSvc
 	deployRepo app.DeploymentRepo
 	branchRepo app.BranchRepo
 	repRepo    app.RepositoryRepo
+	remover    app.BranchRemover
 }
 
 // List returns non-closed deployments.
@@ -39,11 +51,12 @@ func (s Deployment) List(ctx context.Context) ([]app.Deployment, error) {
 }
 
 // Add new deployment.
+// The several branches of the same repository are deployed as the integration branch that merges them in order.
 func (s Deployment) Add(ctx context.Context, f app.FormAddDeployment) (app.Deployment, error) {
-	branches, err := s.branchRepo.FindByIDs(ctx, f.Branches)
+	branches, err := s.resolveBranches(ctx, f.Branches)
 	if err != nil {
 		return app.Deployment{}, errors.WrapContext(err, errors.Context{
-			Path:   "svc.Deployment.Add.FindByIDs",
+			Path:   "svc.Deployment.Add.resolveBranches",
 			Params: errors.Params{"branches": f.Branches},
 		})
 	}
@@ -68,6 +81,7 @@ func (s Deployment) Add(ctx context.Context, f app.FormAddDeployment) (app.Deplo
 }
 
 // Rebuild deployment by ID.
+// The integration branches that are replaced and not deployed anywhere else are deleted.
 func (s Deployment) Rebuild(ctx context.Context, f app.FormReDeployment) (app.Deployment, error) {
 	d, err := s.deployRepo.FindByID(ctx, f.ID)
 	if err != nil {
@@ -76,13 +90,14 @@ func (s Deployment) Rebuild(ctx context.Context, f app.FormReDeployment) (app.De
 			Params: errors.Params{"deployment": f.ID},
 		})
 	}
-	branches, err := s.branchRepo.FindByIDs(ctx, f.Branches)
+	branches, err := s.resolveBranches(ctx, f.Branches)
 	if err != nil {
 		return app.Deployment{}, errors.WrapContext(err, errors.Context{
-			Path:   "svc.Deployment.Add.FindByIDs",
+			Path:   "svc.Deployment.Rebuild.resolveBranches",
 			Params: errors.Params{"branches": f.Branches},
 		})
 	}
+	replaced := d.Branches
 	d.Branches = make([]app.DeploymentBranch, len(branches))
 	for i, b := range branches {
 		d.Branches[i] = app.DeploymentBranch{
@@ -99,6 +114,7 @@ func (s Deployment) Rebuild(ctx context.Context, f app.FormReDeployment) (app.De
 		})
 	}
 	log.Printf("The deployment #%d is enqueued for rebuilding\n", d.ID)
+	s.removeIntegrations(ctx, replaced)
 	return d, nil
 }
 
@@ -127,6 +143,7 @@ func (s Deployment) RebuildWithBranch(ctx context.Context, b app.Branch) error {
 }
 
 // Close the deployment.
+// The integration branches of the deployment that are not deployed anywhere else are deleted.
 func (s Deployment) Close(ctx context.Context, id uint64) error {
 	d, err := s.deployRepo.FindByID(ctx, id)
 	if err != nil {
@@ -144,6 +161,106 @@ func (s Deployment) Close(ctx context.Context, id uint64) error {
 		})
 	}
 	log.Printf("The deployment #%d is closed\n", d.ID)
+	s.removeIntegrations(ctx, d.Branches)
+	return nil
+}
+
+// Changelog returns the commits that arrived to the deployment branches since they were deployed,
+// the changelogs are keyed by the repository alias. The integration branches have no changelog.
+func (s Deployment) Changelog(ctx context.Context, id uint64) (map[string]app.Changelog, error) {
+	d, err := s.deployRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Deployment.Changelog.FindByID",
+			Params: errors.Params{"deployment": id},
+		})
+	}
+	ids := make([]uint64, len(d.Branches))
+	for i, db := range d.Branches {
+		ids[i] = db.ID
+	}
+	branches, err := s.branchRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Deployment.Changelog.FindByIDs",
+			Params: errors.Params{"deployment": id, "branches": ids},
+		})
+	}
+	branchMap := make(map[uint64]app.Branch, len(branches))
+	for _, b := range branches {
+		branchMap[b.ID] = b
+	}
+	res := make(map[string]app.Changelog, len(d.Branches))
+	for _, db := range d.Branches {
+		b, exists := branchMap[db.ID]
+		if !exists || b.Type == app.BranchTypeIntegration {
+			continue
+		}
+		r, err := s.repRepo.FindByID(ctx, b.RepositoryID)
+		if err != nil {
+			return nil, errors.WrapContext(err, errors.Context{
+				Path:   "svc.Deployment.Changelog.findRepo",
+				Params: errors.Params{"deployment": id, "repository": b.RepositoryID},
+			})
+		}
+		res[r.Alias], err = changelog(ctx, s.vcsSvc, r, b, db.Hash)
+		if err != nil {
+			return nil, errors.WrapContext(err, errors.Context{
+				Path:   "svc.Deployment.Changelog.changelog",
+				Params: errors.Params{"deployment": id, "branch": b.ID},
+			})
+		}
+	}
+	return res, nil
+}
+
+// CloseWithRepository closes all deployments that are bound to any branch of the repository.
+func (s Deployment) CloseWithRepository(ctx context.Context, r app.Repository) error {
+	branches, err := s.branchRepo.FindByRepository(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Deployment.CloseWithRepository.FindByRepository",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	branchMap := make(map[uint64]bool, len(branches))
+	for _, b := range branches {
+		branchMap[b.ID] = true
+	}
+	deployments, err := s.deployRepo.FindAll(ctx)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{Path: "svc.Deployment.CloseWithRepository.FindAll"})
+	}
+	// the integration branches of the other repositories are removed along with the closed deployments,
+	// the ones of the repository itself are removed along with the repository
+	var closed []app.DeploymentBranch
+	for _, d := range deployments {
+		bound := false
+		for _, db := range d.Branches {
+			if branchMap[db.ID] {
+				bound = true
+				break
+			}
+		}
+		if !bound {
+			continue
+		}
+		d.Status = app.DeploymentStatusClosed
+		_, err = s.deployRepo.Update(ctx, d)
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Deployment.CloseWithRepository.Update",
+				Params: errors.Params{"deployment": d.ID},
+			})
+		}
+		log.Printf("The deployment #%d is closed\n", d.ID)
+		for _, db := range d.Branches {
+			if !branchMap[db.ID] {
+				closed = append(closed, db)
+			}
+		}
+	}
+	s.removeIntegrations(ctx, closed)
 	return nil
 }
 
@@ -173,16 +290,18 @@ func (s Deployment) WatchJob(ctx context.Context) error {
 		h := pkg.HookDeployment{ID: d.ID, Updated: upd, Branches: make(map[string]pkg.HookBranch, len(d.Branches))}
 		for _, db := range d.Branches {
 			branch := branchMap[db.ID]
-			hash := db.Hash
+			// the deployment records the head revisions only, so the merge preview base is known for the updated one
+			hash, baseHash := db.Hash, ""
 			if upd {
-				hash = branch.Hash
+				hash, baseHash = branch.Hash, branch.BaseHash
 			}
 			h.Branches[repoMap[branch.RepositoryID].Alias] = pkg.HookBranch{
-				ID:     branch.ID,
-				RepoID: branch.RepositoryID,
-				Type:   branch.Type,
-				Name:   branch.Name,
-				Hash:   hash,
+				ID:       branch.ID,
+				RepoID:   branch.RepositoryID,
+				Type:     branch.Type,
+				Name:     branch.Name,
+				Hash:     hash,
+				BaseHash: baseHash,
 			}
 		}
 		return h
@@ -221,7 +340,7 @@ func (s Deployment) WatchJob(ctx context.Context) error {
 		}
 	}
 	if !upd {
-		return nil
+		return errtype.ErrIdle
 	}
 	for _, r := range repos {
 		hookReq.Repos = append(hookReq.Repos, pkg.HookRepo{
@@ -234,12 +353,42 @@ func (s Deployment) WatchJob(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	logs := make(map[uint64]app.BuildLog, len(deployMap))
+	for id := range deployMap {
+		logs[id] = startLog(ctx, s.logSvc, app.LogKindDeployment, id)
+		logs[id].Write(ctx, fmt.Sprintf("Deploy the deployment #%d\n", id))
+	}
+	hookReq.Log = func(deploymentID uint64, text string) {
+		if deploymentID != 0 {
+			if l, exists := logs[deploymentID]; exists {
+				l.Write(ctx, text)
+			}
+			return
+		}
+		for _, l := range logs {
+			l.Write(ctx, text)
+		}
+	}
 	deployRes, err := s.hookSvc.Deploy(ctx, hookReq)
+	for _, l := range logs {
+		if err != nil {
+			l.Write(ctx, err.Error()+"\n")
+		}
+		l.Close(ctx)
+	}
 	if err != nil {
+		if ctx.Err() != nil {
+			s.abandon(deployMap)
+			return errors.WrapContext(err, errors.Context{Path: "svc.Deployment.WatchJob.Deploy"})
+		}
+		if errors.Is(err, errtype.ErrUnavailable) {
+			s.requeue(ctx, deployMap, err)
+			return errors.WrapContext(err, errors.Context{Path: "svc.Deployment.WatchJob.Deploy"})
+		}
 		errMsg := err.Error()
-		err = s.massUpdateStatus(ctx, deployMap, app.DeploymentStatusFailed, &errMsg)
-		if err != nil {
-			log.Println(err)
+		updErr := s.massUpdateStatus(ctx, deployMap, app.DeploymentStatusFailed, &errMsg)
+		if updErr != nil {
+			log.Println(updErr)
 		}
 		return errors.WrapContext(err, errors.Context{Path: "svc.Deployment.WatchJob.Deploy"})
 	}
@@ -251,11 +400,12 @@ func (s Deployment) WatchJob(ctx context.Context) error {
 		switch status.Status {
 		case app.DeploymentStatusReady:
 			d.Status = status.Status
+			d.ErrorMsg = nil // e.g. the message of the requeued attempt
 			s.updateHashes(d, branchMap)
 		default:
 			d.Status = app.DeploymentStatusFailed
 			d.ErrorMsg = status.ErrorMsg
-			log.Printf("The deployment #%d was not deployed, see details in hook handler; status=%s\n", d.ID, status)
+			log.Printf("The deployment #%d was not deployed, see details in hook handler; status=%s\n", d.ID, status.Status)
 		}
 		_, err = s.deployRepo.Update(ctx, d)
 		if err != nil {
@@ -268,6 +418,151 @@ func (s Deployment) WatchJob(ctx context.Context) error {
 	return nil
 }
 
+// resolveBranches finds the deployment branches, the unknown ones are ignored.
+// The several branches of the same repository are replaced with the integration branch that merges them
+// in the requested order.
+func (s Deployment) resolveBranches(ctx context.Context, ids []uint64) ([]app.Branch, error) {
+	branches, err := s.branchRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Deployment.resolveBranches.FindByIDs",
+			Params: errors.Params{"branches": ids},
+		})
+	}
+	branchMap := make(map[uint64]app.Branch, len(branches))
+	for _, b := range branches {
+		branchMap[b.ID] = b
+	}
+	repoBranches := make(map[uint64][]app.Branch)
+	repoIDs := make([]uint64, 0, len(branches))
+	for _, id := range ids {
+		b, exists := branchMap[id]
+		if !exists {
+			continue
+		}
+		delete(branchMap, id) // the duplicate is merged once
+		if len(repoBranches[b.RepositoryID]) == 0 {
+			repoIDs = append(repoIDs, b.RepositoryID)
+		}
+		repoBranches[b.RepositoryID] = append(repoBranches[b.RepositoryID], b)
+	}
+	res := make([]app.Branch, 0, len(repoIDs))
+	for _, repoID := range repoIDs {
+		b := repoBranches[repoID][0]
+		if len(repoBranches[repoID]) > 1 {
+			b, err = s.integrationBranch(ctx, repoID, repoBranches[repoID])
+			if err != nil {
+				return nil, errors.WrapContext(err, errors.Context{
+					Path:   "svc.Deployment.resolveBranches.integrationBranch",
+					Params: errors.Params{"repository": repoID},
+				})
+			}
+		}
+		res = append(res, b)
+	}
+	return res, nil
+}
+
+// integrationBranch returns the integration branch of the components, it is created if it doesn't exist yet.
+func (s Deployment) integrationBranch(ctx context.Context, repoID uint64, components []app.Branch) (app.Branch, error) {
+	r, err := s.repRepo.FindByID(ctx, repoID)
+	if err != nil {
+		return app.Branch{}, errors.WrapContext(err, errors.Context{Path: "svc.Deployment.integrationBranch.findRepo"})
+	}
+	if !s.vcsSvc.CanMerge(r) {
+		return app.Branch{}, fmt.Errorf("%w: integration branches are supported by the git repositories with the git binary backend only; repository=%s",
+			errtype.ErrBadInput, r.Alias)
+	}
+	b := app.Branch{
+		RepositoryID: r.ID,
+		Type:         app.BranchTypeIntegration,
+		Status:       app.BranchStatusEnqueued,
+		Components:   make([]app.BranchComponent, len(components)),
+	}
+	names := make([]string, len(components))
+	for i, c := range components {
+		if c.Type == app.BranchTypePreview || c.Type == app.BranchTypeIntegration {
+			return b, fmt.Errorf("%w: %s branch %s can't be merged into the integration branch", errtype.ErrBadInput, c.Type, c.Name)
+		}
+		b.Components[i] = app.BranchComponent{ID: c.ID, Name: c.Name, Hash: c.Hash}
+		names[i] = c.Name
+	}
+	b.Name = strings.Join(names, "+")
+	if len(b.Name) > IntegrationNameMaxLength {
+		return b, fmt.Errorf("%w: too many branches are merged into the integration branch", errtype.ErrBadInput)
+	}
+	b.Hash = integrationHash(b.Components)
+	existing, err := s.branchRepo.FindByRepository(ctx, r)
+	if err != nil {
+		return b, errors.WrapContext(err, errors.Context{Path: "svc.Deployment.integrationBranch.FindByRepository"})
+	}
+	for _, e := range existing {
+		if e.Type == app.BranchTypeIntegration && sameComponents(e.Components, b.Components) {
+			return e, nil
+		}
+	}
+	b, err = s.branchRepo.Add(ctx, b)
+	if err != nil {
+		return b, errors.WrapContext(err, errors.Context{Path: "svc.Deployment.integrationBranch.Add"})
+	}
+	log.Printf("The integration branch #%d is created\n", b.ID)
+	return b, nil
+}
+
+// removeIntegrations deletes the integration branches among the given ones that no open deployment refers to.
+// They are removed by the same branch remover as the branches deleted by the sync, so the hook handler and VCS clean them up.
+// The failure is logged only, the deployment itself is already updated.
+func (s Deployment) removeIntegrations(ctx context.Context, deployed []app.DeploymentBranch) {
+	if len(deployed) == 0 {
+		return
+	}
+	ids := make([]uint64, len(deployed))
+	for i, db := range deployed {
+		ids[i] = db.ID
+	}
+	branches, err := s.branchRepo.FindByIDs(ctx, ids)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.Deployment.removeIntegrations.FindByIDs",
+			Params: errors.Params{"branches": ids},
+		}))
+		return
+	}
+	deployments, err := s.deployRepo.FindAll(ctx)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{Path: "svc.Deployment.removeIntegrations.FindAll"}))
+		return
+	}
+	used := make(map[uint64]bool)
+	for _, d := range deployments {
+		for _, db := range d.Branches {
+			used[db.ID] = true
+		}
+	}
+	unused := make(map[uint64][]app.Branch)
+	for _, b := range branches {
+		if b.Type == app.BranchTypeIntegration && !used[b.ID] {
+			unused[b.RepositoryID] = append(unused[b.RepositoryID], b)
+		}
+	}
+	for repoID, del := range unused {
+		r, err := s.repRepo.FindByID(ctx, repoID)
+		if err == nil {
+			err = s.remover.Remove(ctx, r, del)
+		}
+		if err != nil {
+			log.Println(errors.WrapContext(err, errors.Context{
+				Path:   "svc.Deployment.removeIntegrations.Remove",
+				Params: errors.Params{"repository": repoID},
+			}))
+			continue
+		}
+		for _, b := range del {
+			log.Printf("The integration branch #%d is deleted, it is not deployed anymore\n", b.ID)
+		}
+	}
+}
+
 func (s Deployment) massUpdateStatus(ctx context.Context, deploys map[uint64]app.Deployment, status string, errorMsg *string) error {
 	var err error
 	for _, d := range deploys {
@@ -284,6 +579,34 @@ func (s Deployment) massUpdateStatus(ctx context.Context, deploys map[uint64]app
 	return nil
 }
 
+// abandon enqueues the interrupted deployments again, so they are deployed on the next start.
+func (s Deployment) abandon(deploys map[uint64]app.Deployment) {
+	ctx, cancel := context.WithTimeout(context.Background(), AbandonTimeout)
+	defer cancel()
+	err := s.massUpdateStatus(ctx, deploys, app.DeploymentStatusEnqueued, nil)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	log.Printf("The deployment is interrupted and %d deployment(s) are enqueued again\n", len(deploys))
+}
+
+// requeue enqueues the deployments again when the hook handler is unreachable, they are deployed on the next job run.
+func (s Deployment) requeue(ctx context.Context, deploys map[uint64]app.Deployment, err error) {
+	errorMsg := fmt.Sprintf("The hook handler is unavailable, the deployment is retried later; err=%v", err)
+	for _, d := range deploys {
+		d.ErrorMsg = &errorMsg
+		err := s.deployRepo.Requeue(ctx, d)
+		if err != nil {
+			log.Println(errors.WrapContext(err, errors.Context{
+				Path:   "svc.Deployment.requeue.Requeue",
+				Params: errors.Params{"deployment": d.ID},
+			}))
+		}
+	}
+	log.Printf("The hook handler is unavailable, %d deployment(s) are enqueued again\n", len(deploys))
+}
+
 func (s Deployment) updateHashes(d app.Deployment, branchMap map[uint64]app.Branch) {
 	for i, b := range d.Branches {
 		d.Branches[i].Hash = branchMap[b.ID].Hash
@@ -0,0 +1,117 @@
+package svc
+
+import (
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"regexp"
+	"strings"
+)
+
+// branchMatcher decides whether the branch has to be built according to the repository filters.
+type branchMatcher struct {
+	heads filterRules
+	tags  filterRules
+	pulls filterRules
+}
+
+type filterRules struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func newBranchMatcher(f app.RepositoryFilters) (branchMatcher, error) {
+	heads, err := newFilterRules(f.Heads)
+	if err != nil {
+		return branchMatcher{}, fmt.Errorf("heads: %w", err)
+	}
+	tags, err := newFilterRules(f.Tags)
+	if err != nil {
+		return branchMatcher{}, fmt.Errorf("tags: %w", err)
+	}
+	pulls, err := newFilterRules(f.Pulls)
+	if err != nil {
+		return branchMatcher{}, fmt.Errorf("pulls: %w", err)
+	}
+	return branchMatcher{heads: heads, tags: tags, pulls: pulls}, nil
+}
+
+func (m branchMatcher) match(b app.VcsBranch) bool {
+	switch b.Type {
+	case app.BranchTypeTag:
+		return m.tags.match(b.Name)
+	case app.BranchTypePull:
+		return m.pulls.match(b.Name)
+	}
+	// the merge previews follow their heads
+	return m.heads.match(b.Name)
+}
+
+func newFilterRules(f app.BranchFilter) (filterRules, error) {
+	var res filterRules
+	var err error
+	res.include, err = compilePatterns(f.Include)
+	if err != nil {
+		return res, err
+	}
+	res.exclude, err = compilePatterns(f.Exclude)
+	return res, err
+}
+
+func (r filterRules) match(name string) bool {
+	included := len(r.include) == 0
+	for _, re := range r.include {
+		if re.MatchString(name) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, re := range r.exclude {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	return true
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := compilePattern(p)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid branch pattern %q: %v", errtype.ErrBadInput, p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// compilePattern turns the glob into the regular expression, the pattern enclosed in slashes is a regular expression already.
+func compilePattern(p string) (*regexp.Regexp, error) {
+	if len(p) > 1 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+		return regexp.Compile(p[1 : len(p)-1])
+	}
+	if p == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(p); i++ {
+		switch {
+		case strings.HasPrefix(p[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case p[i] == '*':
+			expr.WriteString("[^/]*")
+		case p[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(p[i : i+1]))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
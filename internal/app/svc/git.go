@@ -2,19 +2,48 @@ package svc
 
 import (
 	"context"
+	"fmt"
 	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
 	"github.com/beldeveloper/app-lego/pkg/os"
 	"github.com/beldeveloper/go-errors-context"
 	"log"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
+const (
+	// gitPullsRefPrefix is a namespace of the local refs that the pull requests are fetched to.
+	// It is separated from the remote heads, so the pull request never clashes with the head of the same name.
+	gitPullsRefPrefix = "refs/pulls/"
+	// gitHeadsRefSpec fetches the remote heads the same way as the default refspec of the clone.
+	gitHeadsRefSpec = "+refs/heads/*:refs/remotes/origin/*"
+	// gitPullsRefSpec fetches the heads of the GitHub pull requests.
+	gitPullsRefSpec = "+refs/pull/*/head:" + gitPullsRefPrefix + "pull/*"
+	// gitMergeRequestsRefSpec fetches the heads of the GitLab merge requests.
+	gitMergeRequestsRefSpec = "+refs/merge-requests/*/head:" + gitPullsRefPrefix + "merge-requests/*"
+	// gitMergeUser and gitMergeEmail define the committer of the merge previews, they are never pushed anywhere.
+	gitMergeUser  = "app-lego"
+	gitMergeEmail = "app-lego@localhost"
+)
+
+// gitPullRefRx matches the head refs of the GitHub pull requests and GitLab merge requests.
+var gitPullRefRx = regexp.MustCompile("^refs/(pull|merge-requests)/[0-9]+/head$")
+
 // NewGit creates a new instance of the git service.
 func NewGit(reposDir app.ReposDir) app.VcsSvc {
+	// the working trees are registered from inside the repository, so the path must not be relative
+	dir, err := filepath.Abs(string(reposDir))
+	if err != nil {
+		dir = string(reposDir)
+	}
 	return Git{
-		reposDir:       string(reposDir),
-		remoteBranchRx: regexp.MustCompile("^([a-f0-9]+)\\s+refs/(heads|tags)/(.*)$"),
+		reposDir:       dir,
+		remoteBranchRx: regexp.MustCompile("^([a-f0-9]+)\\s+(refs/\\S+)$"),
+		locker:         newRepoLocker(),
 	}
 }
 
@@ -22,14 +51,56 @@ func NewGit(reposDir app.ReposDir) app.VcsSvc {
 type Git struct {
 	reposDir       string
 	remoteBranchRx *regexp.Regexp
+	locker         *repoLocker
+}
+
+// Types returns the repository types that are handled by the service.
+func (s Git) Types() []string {
+	return []string{app.RepositoryTypeGit}
+}
+
+// CanMerge reports that the branches are merged by the git binary.
+func (s Git) CanMerge(r app.Repository) bool {
+	return true
+}
+
+// CheckName accepts any name, the remote is checked by the download.
+func (s Git) CheckName(r app.Repository) error {
+	return nil
 }
 
 // DownloadRepository to the directory.
+// The leftovers of the previous attempt are removed first.
 func (s Git) DownloadRepository(ctx context.Context, r app.Repository) error {
-	_, err := os.Exec(ctx, os.Cmd{
+	defer s.locker.lock(r.ID)()
+	err := os.RemoveDir(worktreesPath(s.reposDir, r))
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.DownloadRepository.removeWorktrees",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	repoDir := s.reposDir + "/" + r.Alias
+	err = os.RecreateDir(repoDir)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.DownloadRepository.RecreateDir",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	env, cleanup, err := gitEnv(r.Credentials)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.DownloadRepository.gitEnv",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	defer cleanup()
+	_, err = os.Exec(ctx, os.Cmd{
 		Name: "git",
-		Args: []string{"clone", r.Name, r.Alias},
-		Dir:  s.reposDir,
+		Args: []string{"clone", r.Name, "."},
+		Env:  env,
+		Dir:  repoDir,
 		Log:  true,
 	})
 	return errors.WrapContext(err, errors.Context{
@@ -38,11 +109,82 @@ func (s Git) DownloadRepository(ctx context.Context, r app.Repository) error {
 	})
 }
 
+// UpdateRemote points the repository clone to the new remote URL.
+func (s Git) UpdateRemote(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	_, err := os.Exec(ctx, os.Cmd{
+		Name: "git",
+		Args: []string{"remote", "set-url", "origin", r.Name},
+		Dir:  s.reposDir + "/" + r.Alias,
+		Log:  true,
+	})
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Git.UpdateRemote",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// MoveRepository moves the clone to the directory of the new alias.
+// The branches' working trees are dropped, they are added again on the next build.
+func (s Git) MoveRepository(ctx context.Context, from, to app.Repository) error {
+	defer s.locker.lock(from.ID)()
+	err := os.RemoveDir(worktreesPath(s.reposDir, from))
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.MoveRepository.removeWorktrees",
+			Params: errors.Params{"repository": from.ID},
+		})
+	}
+	err = os.MoveDir(s.reposDir+"/"+from.Alias, s.reposDir+"/"+to.Alias)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.MoveRepository.MoveDir",
+			Params: errors.Params{"repository": from.ID, "from": from.Alias, "to": to.Alias},
+		})
+	}
+	_, err = os.Exec(ctx, os.Cmd{
+		Name: "git",
+		Args: []string{"worktree", "prune"},
+		Dir:  s.reposDir + "/" + to.Alias,
+		Log:  true,
+	})
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Git.MoveRepository.prune",
+		Params: errors.Params{"repository": to.ID},
+	})
+}
+
+// RemoveRepository removes the clone and the branches' working trees.
+func (s Git) RemoveRepository(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	err := os.RemoveDir(worktreesPath(s.reposDir, r))
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.RemoveRepository.removeWorktrees",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	err = os.RemoveDir(s.reposDir + "/" + r.Alias)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Git.RemoveRepository.RemoveDir",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
 // Branches returns a list git branches and tags for the specific repository.
 func (s Git) Branches(ctx context.Context, r app.Repository) ([]app.VcsBranch, error) {
+	env, cleanup, err := gitEnv(r.Credentials)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.Branches.gitEnv",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	defer cleanup()
 	out, err := os.Exec(ctx, os.Cmd{
 		Name: "git",
 		Args: []string{"ls-remote"},
+		Env:  env,
 		Dir:  s.reposDir + "/" + r.Alias,
 	})
 	if err != nil {
@@ -53,96 +195,278 @@ func (s Git) Branches(ctx context.Context, r app.Repository) ([]app.VcsBranch, e
 	}
 	rows := strings.Split(out, "\n")
 	branches := make([]app.VcsBranch, 0, len(rows))
-	var b app.VcsBranch
-	for _, r := range rows {
-		r := strings.TrimSpace(r)
-		matches := s.remoteBranchRx.FindStringSubmatch(r)
-		if len(matches) < 4 {
+	for _, row := range rows {
+		matches := s.remoteBranchRx.FindStringSubmatch(strings.TrimSpace(row))
+		if len(matches) < 3 {
 			continue
 		}
-		b.Hash = matches[1]
-		b.Name = matches[3]
-		switch matches[2] {
-		case "heads":
-			b.Type = app.BranchTypeHead
-		case "tags":
-			b.Type = app.BranchTypeTag
-		default:
+		b, ok := parseGitRef(matches[2], r.PullRequests)
+		if !ok {
 			continue
 		}
+		b.Hash = matches[1]
 		branches = append(branches, b)
 	}
 	return branches, nil
 }
 
-// SwitchBranch fetches git updates, resets the current branch, switches to a new one, and pulls branch updates.
-func (s Git) SwitchBranch(ctx context.Context, r app.Repository, b app.Branch) error {
-	_, err := os.Exec(ctx, os.Cmd{
+// Fetch downloads the git updates of all branches and tags.
+func (s Git) Fetch(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	return errors.WrapContext(s.fetch(ctx, r), errors.Context{
+		Path:   "svc.Git.Fetch",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// Commit returns the metadata of the fetched commit, the annotated tag is resolved to its commit.
+func (s Git) Commit(ctx context.Context, r app.Repository, hash string) (app.Commit, error) {
+	out, err := os.Exec(ctx, os.Cmd{
 		Name: "git",
-		Args: []string{"fetch"},
+		Args: []string{"log", "-1", "--format=%H%x1f%an <%ae>%x1f%cI%x1f%B", hash, "--"},
 		Dir:  s.reposDir + "/" + r.Alias,
-		Log:  true,
 	})
 	if err != nil {
-		return errors.WrapContext(err, errors.Context{
-			Path:   "svc.Git.SwitchBranch.fetch",
-			Params: errors.Params{"repository": r.ID},
+		return app.Commit{}, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.Commit.log",
+			Params: errors.Params{"repository": r.ID, "hash": hash},
 		})
 	}
-	newBranch := b.Name
-	if b.Type == app.BranchTypeTag {
-		newBranch = "tags/" + b.Name
+	c, err := parseCommit(out, time.RFC3339)
+	return c, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Git.Commit.parseCommit",
+		Params: errors.Params{"repository": r.ID, "hash": hash},
+	})
+}
+
+// Log returns the commits that are reachable from the "to" revision, but not from the "from" one, the recent first.
+// The history of the "to" revision is returned if "from" is empty.
+func (s Git) Log(ctx context.Context, r app.Repository, from, to string, limit int) ([]app.Commit, error) {
+	rev := to
+	if from != "" {
+		rev = from + ".." + to
 	}
-	_, err = os.Exec(ctx, os.Cmd{
+	out, err := os.Exec(ctx, os.Cmd{
 		Name: "git",
-		Args: []string{"reset", "--hard"},
+		Args: []string{"log", "--format=%H%x1f%an <%ae>%x1f%cI%x1f%B%x1e", "--max-count=" + strconv.Itoa(limit), rev, "--"},
 		Dir:  s.reposDir + "/" + r.Alias,
-		Log:  true,
 	})
 	if err != nil {
-		log.Println(errors.WrapContext(err, errors.Context{
-			Path:   "svc.Git.SwitchBranch.reset",
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.Log.log",
+			Params: errors.Params{"repository": r.ID, "from": from, "to": to},
+		})
+	}
+	res, err := parseCommits(out, time.RFC3339)
+	return res, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Git.Log.parseCommits",
+		Params: errors.Params{"repository": r.ID, "from": from, "to": to},
+	})
+}
+
+// SwitchBranch fetches git updates and checks the branch out into its own working tree.
+// The merge preview checks the base revision out and merges the head onto it,
+// the integration branch checks the first component out and merges the rest of them in order.
+func (s Git) SwitchBranch(ctx context.Context, r app.Repository, b app.Branch) error {
+	defer s.locker.lock(r.ID)()
+	repoDir := s.reposDir + "/" + r.Alias
+	err := s.fetch(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.SwitchBranch.fetch",
 			Params: errors.Params{"repository": r.ID},
-		}))
+		})
 	}
-	_, err = os.Exec(ctx, os.Cmd{
+	ref := gitLocalRef(b)
+	switch b.Type {
+	case app.BranchTypePreview:
+		ref = b.BaseHash
+	case app.BranchTypeIntegration:
+		if len(b.Components) == 0 {
+			return fmt.Errorf("%w: integration branch has no components; branch=%d", errtype.ErrBadInput, b.ID)
+		}
+		ref = b.Components[0].Hash
+	}
+	dir := s.BranchDir(r, b)
+	exists, err := os.Exists(dir)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.SwitchBranch.Exists",
+			Params: errors.Params{"repository": r.ID, "branch": b.ID, "dir": dir},
+		})
+	}
+	if !exists {
+		_, err = os.Exec(ctx, os.Cmd{
+			Name: "git",
+			Args: []string{"worktree", "prune"},
+			Dir:  repoDir,
+			Log:  true,
+		})
+		if err != nil {
+			log.Println(errors.WrapContext(err, errors.Context{
+				Path:   "svc.Git.SwitchBranch.prune",
+				Params: errors.Params{"repository": r.ID},
+			}))
+		}
+		_, err = os.Exec(ctx, os.Cmd{
+			Name: "git",
+			Args: []string{"worktree", "add", "--force", "--detach", dir, ref},
+			Dir:  repoDir,
+			Log:  true,
+		})
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Git.SwitchBranch.add",
+				Params: errors.Params{"repository": r.ID, "branch": b.ID, "branchName": b.Name},
+			})
+		}
+	} else {
+		_, err = os.Exec(ctx, os.Cmd{
+			Name: "git",
+			Args: []string{"checkout", "--force", "--detach", ref},
+			Dir:  dir,
+			Log:  true,
+		})
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Git.SwitchBranch.checkout",
+				Params: errors.Params{"repository": r.ID, "branch": b.ID, "branchName": b.Name},
+			})
+		}
+	}
+	switch b.Type {
+	case app.BranchTypePreview:
+		return s.merge(ctx, dir, b.Name, b.Hash)
+	case app.BranchTypeIntegration:
+		for _, c := range b.Components[1:] {
+			err = s.merge(ctx, dir, c.Name, c.Hash)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// merge merges the branch revision onto the checked out one.
+// The conflicting merge is aborted and the conflict error lists the conflicting files.
+func (s Git) merge(ctx context.Context, dir, name, hash string) error {
+	_, err := os.Exec(ctx, os.Cmd{
 		Name: "git",
-		Args: []string{"checkout", newBranch},
-		Dir:  s.reposDir + "/" + r.Alias,
-		Log:  true,
+		Args: []string{
+			"-c", "user.name=" + gitMergeUser, "-c", "user.email=" + gitMergeEmail,
+			"merge", "--no-ff", "--no-edit", "-m", "Merge branch '" + name + "'", hash,
+		},
+		Dir: dir,
+		Log: true,
 	})
-	if err != nil {
+	if err == nil {
+		return nil
+	}
+	out, diffErr := os.Exec(ctx, os.Cmd{
+		Name: "git",
+		Args: []string{"diff", "--name-only", "--diff-filter=U"},
+		Dir:  dir,
+	})
+	_, abortErr := os.Exec(ctx, os.Cmd{
+		Name: "git",
+		Args: []string{"merge", "--abort"},
+		Dir:  dir,
+	})
+	if abortErr != nil {
+		log.Println(errors.WrapContext(abortErr, errors.Context{
+			Path:   "svc.Git.merge.abort",
+			Params: errors.Params{"dir": dir},
+		}))
+	}
+	out = strings.TrimSpace(out)
+	if diffErr != nil || out == "" {
 		return errors.WrapContext(err, errors.Context{
-			Path:   "svc.Git.SwitchBranch.checkout",
-			Params: errors.Params{"repository": r.ID, "branchName": b.Name},
+			Path:   "svc.Git.merge.merge",
+			Params: errors.Params{"dir": dir, "branchName": name, "hash": hash},
 		})
 	}
-	if b.Type == app.BranchTypeTag {
+	// the error is not wrapped, so the branch error message lists the files only
+	return fmt.Errorf("%w; branch: %s; files: %s", errtype.ErrConflict, name, strings.ReplaceAll(out, "\n", ", "))
+}
+
+// BranchDir returns the directory of the branch working tree.
+func (s Git) BranchDir(r app.Repository, b app.Branch) string {
+	return branchPath(s.reposDir, r, b)
+}
+
+// CleanBranches removes the working trees of the deleted branches.
+func (s Git) CleanBranches(ctx context.Context, r app.Repository, branches []app.Branch) error {
+	if len(branches) == 0 {
 		return nil
 	}
+	defer s.locker.lock(r.ID)()
+	err := removeWorktrees(s.reposDir, r, branches)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Git.CleanBranches.removeWorktrees",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
 	_, err = os.Exec(ctx, os.Cmd{
 		Name: "git",
-		Args: []string{"reset", "--hard", "origin/" + b.Name},
+		Args: []string{"worktree", "prune"},
 		Dir:  s.reposDir + "/" + r.Alias,
 		Log:  true,
 	})
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Git.CleanBranches.prune",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+func (s Git) fetch(ctx context.Context, r app.Repository) error {
+	env, cleanup, err := gitEnv(r.Credentials)
 	if err != nil {
-		log.Println(errors.WrapContext(err, errors.Context{
-			Path:   "svc.Git.SwitchBranch.reset",
-			Params: errors.Params{"repository": r.ID},
-		}))
+		return err
+	}
+	defer cleanup()
+	args := []string{"fetch", "--prune", "--tags", "--force"}
+	if r.PullRequests {
+		// the explicit refspecs replace the configured one, so the heads are listed as well
+		args = append(args, "origin", gitHeadsRefSpec, gitPullsRefSpec, gitMergeRequestsRefSpec)
 	}
 	_, err = os.Exec(ctx, os.Cmd{
 		Name: "git",
-		Args: []string{"pull"},
+		Args: args,
+		Env:  env,
 		Dir:  s.reposDir + "/" + r.Alias,
 		Log:  true,
 	})
-	if err != nil {
-		return errors.WrapContext(err, errors.Context{
-			Path:   "svc.Git.SwitchBranch.pull",
-			Params: errors.Params{"repository": r.ID, "branch": b.ID},
-		})
+	return err
+}
+
+// parseGitRef converts the remote ref to the branch, it reports false if the ref isn't synced.
+// The pull request refs are synced only if they are enabled, e.g. refs/pull/12/head becomes the branch pull/12.
+func parseGitRef(ref string, pulls bool) (app.VcsBranch, bool) {
+	switch {
+	case strings.HasPrefix(ref, "refs/heads/"):
+		return app.VcsBranch{Type: app.BranchTypeHead, Name: strings.TrimPrefix(ref, "refs/heads/")}, true
+	case strings.HasPrefix(ref, "refs/tags/"):
+		// the peeled annotated tags point to the same commits as the tags themselves
+		if strings.HasSuffix(ref, "^{}") {
+			return app.VcsBranch{}, false
+		}
+		return app.VcsBranch{Type: app.BranchTypeTag, Name: strings.TrimPrefix(ref, "refs/tags/")}, true
+	case pulls && gitPullRefRx.MatchString(ref):
+		name := strings.TrimSuffix(strings.TrimPrefix(ref, "refs/"), "/head")
+		return app.VcsBranch{Type: app.BranchTypePull, Name: name}, true
 	}
-	return nil
+	return app.VcsBranch{}, false
+}
+
+// gitLocalRef returns the local ref that the branch is fetched to.
+func gitLocalRef(b app.Branch) string {
+	switch b.Type {
+	case app.BranchTypeTag:
+		return "refs/tags/" + b.Name
+	case app.BranchTypePull:
+		return gitPullsRefPrefix + b.Name
+	}
+	return "refs/remotes/origin/" + b.Name
 }
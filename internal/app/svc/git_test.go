@@ -0,0 +1,277 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gitFixture is a bare remote with the heads, tags and the pull request head, along with the working clone that pushes to it.
+type gitFixture struct {
+	t       *testing.T
+	remote  string
+	src     string
+	home    string
+	commits int
+	hashes  map[string]string
+}
+
+func newGitFixture(t *testing.T) *gitFixture {
+	t.Helper()
+	dir := t.TempDir()
+	f := &gitFixture{
+		t:      t,
+		remote: filepath.Join(dir, "remote.git"),
+		src:    filepath.Join(dir, "src"),
+		home:   filepath.Join(dir, "home"),
+		hashes: make(map[string]string),
+	}
+	for _, d := range []string{f.src, f.home} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.git(dir, "init", "-q", "--bare", f.remote)
+	f.git(f.remote, "symbolic-ref", "HEAD", "refs/heads/main")
+	f.git(f.src, "init", "-q")
+	f.git(f.src, "checkout", "-q", "-b", "main")
+	f.commit("initial", "v1", "Initial commit")
+	f.git(f.src, "tag", "v1.0")
+	f.git(f.src, "checkout", "-q", "-b", "feature/login")
+	f.commit("login", "login", "Add login\n\nThe login form.")
+	f.git(f.src, "tag", "-a", "v1.1", "-m", "Release 1.1")
+	f.hashes["v1.1"] = f.git(f.src, "rev-parse", "v1.1")
+	f.git(f.src, "checkout", "-q", "-b", "pr")
+	f.commit("pull", "pull", "Fix login")
+	f.git(f.src, "push", "-q", f.remote, "main", "feature/login", "v1.0", "v1.1", "pr:refs/pull/7/head")
+	return f
+}
+
+// commit writes the content to app.txt and commits it, every commit is a minute younger than the previous one.
+func (f *gitFixture) commit(key, content, message string) {
+	f.t.Helper()
+	err := os.WriteFile(filepath.Join(f.src, "app.txt"), []byte(content+"\n"), 0644)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	f.commits++
+	f.git(f.src, "add", "app.txt")
+	f.git(f.src, "commit", "-q", "-m", message)
+	f.hashes[key] = f.git(f.src, "rev-parse", "HEAD")
+}
+
+// committedAt returns the time of the n-th commit of the fixture.
+func (f *gitFixture) committedAt(n int) time.Time {
+	return time.Date(2021, 3, 1, 10, n, 0, 0, time.FixedZone("", 2*60*60))
+}
+
+func (f *gitFixture) git(dir string, args ...string) string {
+	f.t.Helper()
+	date := f.committedAt(f.commits).Format(time.RFC3339)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"HOME="+f.home,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_AUTHOR_NAME=Jane Doe",
+		"GIT_AUTHOR_EMAIL=jane@example.com",
+		"GIT_AUTHOR_DATE="+date,
+		"GIT_COMMITTER_NAME=Jane Doe",
+		"GIT_COMMITTER_EMAIL=jane@example.com",
+		"GIT_COMMITTER_DATE="+date,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		f.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestGitBackends(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	backends := []struct {
+		name     string
+		new      func(app.ReposDir) app.VcsSvc
+		canMerge bool
+	}{
+		{name: "git", new: NewGit, canMerge: true},
+		{name: "go-git", new: NewGoGit},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			s := backend.new(app.ReposDir(t.TempDir()))
+			if m := s.CanMerge(app.Repository{Type: app.RepositoryTypeGit}); m != backend.canMerge {
+				t.Errorf("CanMerge = %v, want %v", m, backend.canMerge)
+			}
+			testGitBackend(t, s)
+		})
+	}
+}
+
+func testGitBackend(t *testing.T, s app.VcsSvc) {
+	ctx := context.Background()
+	f := newGitFixture(t)
+	r := app.Repository{ID: 1, Type: app.RepositoryTypeGit, Alias: "shop", Name: f.remote, PullRequests: true}
+	h := f.hashes
+
+	if err := s.DownloadRepository(ctx, r); err != nil {
+		t.Fatalf("DownloadRepository: %v", err)
+	}
+
+	branches, err := s.Branches(ctx, r)
+	if err != nil {
+		t.Fatalf("Branches: %v", err)
+	}
+	sort.Slice(branches, func(i, j int) bool {
+		return branches[i].Type+branches[i].Name < branches[j].Type+branches[j].Name
+	})
+	wantBranches := []app.VcsBranch{
+		{Type: app.BranchTypeHead, Name: "feature/login", Hash: h["login"]},
+		{Type: app.BranchTypeHead, Name: "main", Hash: h["initial"]},
+		{Type: app.BranchTypePull, Name: "pull/7", Hash: h["pull"]},
+		{Type: app.BranchTypeTag, Name: "v1.0", Hash: h["initial"]},
+		{Type: app.BranchTypeTag, Name: "v1.1", Hash: h["v1.1"]},
+	}
+	if !reflect.DeepEqual(branches, wantBranches) {
+		t.Fatalf("Branches = %+v, want %+v", branches, wantBranches)
+	}
+
+	if err = s.Fetch(ctx, r); err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+
+	login := app.Commit{
+		Hash:        h["login"],
+		Author:      "Jane Doe <jane@example.com>",
+		CommittedAt: f.committedAt(2),
+		Subject:     "Add login",
+		Message:     "Add login\n\nThe login form.",
+	}
+	commits := []struct {
+		name string
+		hash string
+		want app.Commit
+	}{
+		{name: "commit", hash: h["login"], want: login},
+		{name: "annotated tag", hash: h["v1.1"], want: login},
+	}
+	for _, tt := range commits {
+		t.Run("Commit/"+tt.name, func(t *testing.T) {
+			c, err := s.Commit(ctx, r, tt.hash)
+			if err != nil {
+				t.Fatalf("Commit: %v", err)
+			}
+			if !c.CommittedAt.Equal(tt.want.CommittedAt) {
+				t.Errorf("CommittedAt = %s, want %s", c.CommittedAt, tt.want.CommittedAt)
+			}
+			c.CommittedAt = tt.want.CommittedAt
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("Commit = %+v, want %+v", c, tt.want)
+			}
+		})
+	}
+
+	logs := []struct {
+		name     string
+		from, to string
+		limit    int
+		want     []string
+	}{
+		{name: "history", to: h["pull"], limit: 10, want: []string{h["pull"], h["login"], h["initial"]}},
+		{name: "limit", to: h["pull"], limit: 2, want: []string{h["pull"], h["login"]}},
+		{name: "range", from: h["initial"], to: h["pull"], limit: 10, want: []string{h["pull"], h["login"]}},
+		{name: "annotated tag", from: h["initial"], to: h["v1.1"], limit: 10, want: []string{h["login"]}},
+		{name: "empty range", from: h["login"], to: h["login"], limit: 10, want: []string{}},
+	}
+	for _, tt := range logs {
+		t.Run("Log/"+tt.name, func(t *testing.T) {
+			res, err := s.Log(ctx, r, tt.from, tt.to, tt.limit)
+			if err != nil {
+				t.Fatalf("Log: %v", err)
+			}
+			hashes := make([]string, len(res))
+			for i, c := range res {
+				hashes[i] = c.Hash
+			}
+			if !reflect.DeepEqual(hashes, tt.want) {
+				t.Errorf("Log = %v, want %v", hashes, tt.want)
+			}
+		})
+	}
+
+	switches := []struct {
+		branch  app.Branch
+		content string
+	}{
+		{branch: app.Branch{ID: 1, Type: app.BranchTypeHead, Name: "main"}, content: "v1"},
+		{branch: app.Branch{ID: 2, Type: app.BranchTypeHead, Name: "feature/login"}, content: "login"},
+		{branch: app.Branch{ID: 3, Type: app.BranchTypeTag, Name: "v1.0"}, content: "v1"},
+		{branch: app.Branch{ID: 4, Type: app.BranchTypeTag, Name: "v1.1"}, content: "login"},
+		{branch: app.Branch{ID: 5, Type: app.BranchTypePull, Name: "pull/7"}, content: "pull"},
+	}
+	for _, tt := range switches {
+		t.Run(fmt.Sprintf("SwitchBranch/%s/%s", tt.branch.Type, tt.branch.Name), func(t *testing.T) {
+			assertBranchContent(t, s, r, tt.branch, tt.content)
+		})
+	}
+
+	t.Run("Fetch", func(t *testing.T) {
+		f.git(f.src, "checkout", "-q", "main")
+		f.commit("update", "v2", "Update main")
+		f.git(f.src, "push", "-q", f.remote, "main")
+		if err := s.Fetch(ctx, r); err != nil {
+			t.Fatalf("Fetch: %v", err)
+		}
+		c, err := s.Commit(ctx, r, f.hashes["update"])
+		if err != nil {
+			t.Fatalf("Commit: %v", err)
+		}
+		if c.Subject != "Update main" {
+			t.Errorf("Subject = %q, want %q", c.Subject, "Update main")
+		}
+		assertBranchContent(t, s, r, switches[0].branch, "v2")
+	})
+
+	t.Run("CleanBranches", func(t *testing.T) {
+		removed := []app.Branch{switches[0].branch, switches[4].branch}
+		if err := s.CleanBranches(ctx, r, removed); err != nil {
+			t.Fatalf("CleanBranches: %v", err)
+		}
+		for _, tt := range switches {
+			_, err := os.Stat(s.BranchDir(r, tt.branch))
+			kept := tt.branch.ID != removed[0].ID && tt.branch.ID != removed[1].ID
+			if kept && err != nil {
+				t.Errorf("the working tree of %s is removed: %v", tt.branch.Name, err)
+			}
+			if !kept && !os.IsNotExist(err) {
+				t.Errorf("the working tree of %s is kept: %v", tt.branch.Name, err)
+			}
+		}
+		// the removed branch can be checked out again
+		assertBranchContent(t, s, r, switches[0].branch, "v2")
+	})
+}
+
+func assertBranchContent(t *testing.T, s app.VcsSvc, r app.Repository, b app.Branch, content string) {
+	t.Helper()
+	if err := s.SwitchBranch(context.Background(), r, b); err != nil {
+		t.Fatalf("SwitchBranch: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(s.BranchDir(r, b), "app.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != content {
+		t.Errorf("app.txt = %q, want %q", got, content)
+	}
+}
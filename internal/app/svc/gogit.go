@@ -0,0 +1,511 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/app-lego/pkg/os"
+	"github.com/beldeveloper/go-errors-context"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// gitAlternatesFile is the file of the branch working tree repository that points to the objects of the clone.
+const gitAlternatesFile = ".git/objects/info/alternates"
+
+// NewGoGit creates a new instance of the git service that doesn't need the git binary.
+func NewGoGit(reposDir app.ReposDir) app.VcsSvc {
+	// the working trees refer to the clone objects, so the path must not be relative
+	dir, err := filepath.Abs(string(reposDir))
+	if err != nil {
+		dir = string(reposDir)
+	}
+	return GoGit{
+		reposDir: dir,
+		locker:   newRepoLocker(),
+	}
+}
+
+// GoGit is a service that manages git repositories with the pure Go implementation of git.
+// It keeps the same layout as the Git service, but every branch working tree is a separate repository
+// that borrows the objects of the clone.
+type GoGit struct {
+	reposDir string
+	locker   *repoLocker
+}
+
+// Types returns the repository types that are handled by the service.
+func (s GoGit) Types() []string {
+	return []string{app.RepositoryTypeGit}
+}
+
+// CanMerge reports that the branches can't be merged, go-git can't merge the diverged branches.
+func (s GoGit) CanMerge(r app.Repository) bool {
+	return false
+}
+
+// CheckName accepts any name, the remote is checked by the download.
+func (s GoGit) CheckName(r app.Repository) error {
+	return nil
+}
+
+// DownloadRepository to the directory.
+// The leftovers of the previous attempt are removed first.
+func (s GoGit) DownloadRepository(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	err := os.RemoveDir(worktreesPath(s.reposDir, r))
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.DownloadRepository.removeWorktrees",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	repoDir := s.reposDir + "/" + r.Alias
+	err = os.RecreateDir(repoDir)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.DownloadRepository.RecreateDir",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	auth, err := goGitAuth(r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.DownloadRepository.goGitAuth",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	log.Printf("Clone the repository #%d from %s\n", r.ID, r.Name)
+	_, err = git.PlainCloneContext(ctx, repoDir, false, &git.CloneOptions{
+		URL:        r.Name,
+		Auth:       auth,
+		NoCheckout: true,
+	})
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.GoGit.DownloadRepository",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// UpdateRemote points the repository clone to the new remote URL.
+func (s GoGit) UpdateRemote(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	repo, err := git.PlainOpen(s.reposDir + "/" + r.Alias)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.UpdateRemote.PlainOpen",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.UpdateRemote.Config",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	remote, exists := cfg.Remotes[git.DefaultRemoteName]
+	if !exists {
+		remote = &config.RemoteConfig{
+			Name:  git.DefaultRemoteName,
+			Fetch: []config.RefSpec{config.RefSpec(config.DefaultFetchRefSpec)},
+		}
+		cfg.Remotes[git.DefaultRemoteName] = remote
+	}
+	remote.URLs = []string{r.Name}
+	err = repo.SetConfig(cfg)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.GoGit.UpdateRemote.SetConfig",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// MoveRepository moves the clone to the directory of the new alias.
+// The branches' working trees are dropped, they are created again on the next build.
+func (s GoGit) MoveRepository(ctx context.Context, from, to app.Repository) error {
+	defer s.locker.lock(from.ID)()
+	err := os.RemoveDir(worktreesPath(s.reposDir, from))
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.MoveRepository.removeWorktrees",
+			Params: errors.Params{"repository": from.ID},
+		})
+	}
+	err = os.MoveDir(s.reposDir+"/"+from.Alias, s.reposDir+"/"+to.Alias)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.GoGit.MoveRepository.MoveDir",
+		Params: errors.Params{"repository": from.ID, "from": from.Alias, "to": to.Alias},
+	})
+}
+
+// RemoveRepository removes the clone and the branches' working trees.
+func (s GoGit) RemoveRepository(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	err := os.RemoveDir(worktreesPath(s.reposDir, r))
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.RemoveRepository.removeWorktrees",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	err = os.RemoveDir(s.reposDir + "/" + r.Alias)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.GoGit.RemoveRepository.RemoveDir",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// Branches returns a list git branches and tags for the specific repository.
+func (s GoGit) Branches(ctx context.Context, r app.Repository) ([]app.VcsBranch, error) {
+	repo, err := git.PlainOpen(s.reposDir + "/" + r.Alias)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.Branches.PlainOpen",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	remote, err := repo.Remote(git.DefaultRemoteName)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.Branches.Remote",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	auth, err := goGitAuth(r)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.Branches.goGitAuth",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	refs, err := remote.ListContext(ctx, &git.ListOptions{Auth: auth})
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.Branches.ls",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	branches := make([]app.VcsBranch, 0, len(refs))
+	for _, ref := range refs {
+		b, ok := parseGitRef(ref.Name().String(), r.PullRequests)
+		if !ok {
+			continue
+		}
+		b.Hash = ref.Hash().String()
+		branches = append(branches, b)
+	}
+	return branches, nil
+}
+
+// Fetch downloads the git updates of all branches and tags.
+func (s GoGit) Fetch(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	repo, err := git.PlainOpen(s.reposDir + "/" + r.Alias)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.Fetch.PlainOpen",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	return errors.WrapContext(s.fetch(ctx, r, repo), errors.Context{
+		Path:   "svc.GoGit.Fetch",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// Commit returns the metadata of the fetched commit, the annotated tag is resolved to its commit.
+func (s GoGit) Commit(ctx context.Context, r app.Repository, hash string) (app.Commit, error) {
+	repo, err := git.PlainOpen(s.reposDir + "/" + r.Alias)
+	if err != nil {
+		return app.Commit{}, errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.Commit.PlainOpen",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	c, err := s.commit(repo, plumbing.NewHash(hash))
+	if err != nil {
+		return app.Commit{}, errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.Commit.commit",
+			Params: errors.Params{"repository": r.ID, "hash": hash},
+		})
+	}
+	return goGitCommit(c), nil
+}
+
+// Log returns the commits that are reachable from the "to" revision, but not from the "from" one, the recent first.
+// The history of the "to" revision is returned if "from" is empty.
+func (s GoGit) Log(ctx context.Context, r app.Repository, from, to string, limit int) ([]app.Commit, error) {
+	repo, err := git.PlainOpen(s.reposDir + "/" + r.Alias)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.Log.PlainOpen",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	toCommit, err := s.resolve(repo, to)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.Log.resolveTo",
+			Params: errors.Params{"repository": r.ID, "to": to},
+		})
+	}
+	// the ancestors of the "from" revision are marked as seen, so they are never walked
+	exclude := make(map[plumbing.Hash]bool)
+	if from != "" {
+		fromCommit, err := s.resolve(repo, from)
+		if err != nil {
+			return nil, errors.WrapContext(err, errors.Context{
+				Path:   "svc.GoGit.Log.resolveFrom",
+				Params: errors.Params{"repository": r.ID, "from": from},
+			})
+		}
+		err = object.NewCommitPreorderIter(fromCommit, nil, nil).ForEach(func(c *object.Commit) error {
+			exclude[c.Hash] = true
+			return ctx.Err()
+		})
+		if err != nil {
+			return nil, errors.WrapContext(err, errors.Context{
+				Path:   "svc.GoGit.Log.excludeFrom",
+				Params: errors.Params{"repository": r.ID, "from": from},
+			})
+		}
+	}
+	res := make([]app.Commit, 0)
+	err = object.NewCommitIterCTime(toCommit, exclude, nil).ForEach(func(c *object.Commit) error {
+		if len(res) >= limit {
+			return storer.ErrStop
+		}
+		res = append(res, goGitCommit(c))
+		return ctx.Err()
+	})
+	return res, errors.WrapContext(err, errors.Context{
+		Path:   "svc.GoGit.Log.walk",
+		Params: errors.Params{"repository": r.ID, "from": from, "to": to},
+	})
+}
+
+// SwitchBranch fetches git updates and checks the branch out into its own working tree.
+// The merge previews and integration branches are not supported, go-git can't merge the diverged branches.
+func (s GoGit) SwitchBranch(ctx context.Context, r app.Repository, b app.Branch) error {
+	if b.Type == app.BranchTypePreview || b.Type == app.BranchTypeIntegration {
+		return fmt.Errorf("%w: %s branches require the git binary backend", errtype.ErrBadInput, b.Type)
+	}
+	defer s.locker.lock(r.ID)()
+	repoDir := s.reposDir + "/" + r.Alias
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.SwitchBranch.PlainOpen",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	err = s.fetch(ctx, r, repo)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.SwitchBranch.fetch",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	hash, err := s.commitHash(repo, b)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.SwitchBranch.commitHash",
+			Params: errors.Params{"repository": r.ID, "branch": b.ID, "branchName": b.Name},
+		})
+	}
+	dir := s.BranchDir(r, b)
+	wt, err := s.worktree(repoDir, dir)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.GoGit.SwitchBranch.worktree",
+			Params: errors.Params{"repository": r.ID, "branch": b.ID, "dir": dir},
+		})
+	}
+	log.Printf("Check out %s of the repository #%d into %s\n", hash, r.ID, dir)
+	err = wt.Checkout(&git.CheckoutOptions{Hash: hash, Force: true})
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.GoGit.SwitchBranch.checkout",
+		Params: errors.Params{"repository": r.ID, "branch": b.ID, "branchName": b.Name},
+	})
+}
+
+// BranchDir returns the directory of the branch working tree.
+func (s GoGit) BranchDir(r app.Repository, b app.Branch) string {
+	return branchPath(s.reposDir, r, b)
+}
+
+// CleanBranches removes the working trees of the deleted branches.
+func (s GoGit) CleanBranches(ctx context.Context, r app.Repository, branches []app.Branch) error {
+	defer s.locker.lock(r.ID)()
+	err := removeWorktrees(s.reposDir, r, branches)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.GoGit.CleanBranches",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// commitHash returns the fetched commit of the branch.
+func (s GoGit) commitHash(repo *git.Repository, b app.Branch) (plumbing.Hash, error) {
+	ref, err := repo.Reference(plumbing.ReferenceName(gitLocalRef(b)), true)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	c, err := s.commit(repo, ref.Hash())
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return c.Hash, nil
+}
+
+// resolve returns the commit object by the full or abbreviated hash, the annotated tag is resolved to its commit.
+func (s GoGit) resolve(repo *git.Repository, rev string) (*object.Commit, error) {
+	hash, err := repo.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+	return repo.CommitObject(*hash)
+}
+
+// commit returns the commit object by its hash, the annotated tag is resolved to its commit.
+func (s GoGit) commit(repo *git.Repository, hash plumbing.Hash) (*object.Commit, error) {
+	tag, err := repo.TagObject(hash)
+	if err == plumbing.ErrObjectNotFound {
+		return repo.CommitObject(hash)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tag.Commit()
+}
+
+func (s GoGit) fetch(ctx context.Context, r app.Repository, repo *git.Repository) error {
+	auth, err := goGitAuth(r)
+	if err != nil {
+		return err
+	}
+	refSpecs := []config.RefSpec{gitHeadsRefSpec, "+refs/tags/*:refs/tags/*"}
+	if r.PullRequests {
+		refSpecs = append(refSpecs, gitPullsRefSpec, gitMergeRequestsRefSpec)
+	}
+	log.Printf("Fetch the repository #%d\n", r.ID)
+	err = repo.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: refSpecs,
+		Auth:     auth,
+		Force:    true,
+		Prune:    true,
+	})
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+// worktree opens the branch working tree and creates it if it doesn't exist yet.
+// The working tree repository keeps its own index and HEAD, the objects are read from the clone.
+func (s GoGit) worktree(repoDir, dir string) (*git.Worktree, error) {
+	exists, err := os.Exists(dir + "/" + gitAlternatesFile)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		// the directory may be left by the git binary backend or by the interrupted attempt
+		err = os.RemoveDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		_, err = git.PlainInit(dir, false)
+		if err != nil {
+			return nil, err
+		}
+		err = os.WriteFile(dir+"/"+gitAlternatesFile, repoDir+"/.git/objects\n")
+		if err != nil {
+			return nil, err
+		}
+	}
+	storage := filesystem.NewStorageWithOptions(
+		osfs.New(dir+"/.git"),
+		cache.NewObjectLRUDefault(),
+		filesystem.Options{AlternatesFS: osfs.New("/")},
+	)
+	repo, err := git.Open(storage, osfs.New(dir))
+	if err != nil {
+		return nil, err
+	}
+	return repo.Worktree()
+}
+
+// goGitCommit converts the commit object to the commit metadata.
+func goGitCommit(c *object.Commit) app.Commit {
+	message := strings.TrimSpace(c.Message)
+	return app.Commit{
+		Hash:        c.Hash.String(),
+		Author:      c.Author.String(),
+		CommittedAt: c.Committer.When,
+		Subject:     commitSubject(message),
+		Message:     message,
+	}
+}
+
+// goGitAuth returns the authentication method for the repository remote, it is nil if there are no credentials.
+func goGitAuth(r app.Repository) (transport.AuthMethod, error) {
+	c := r.Credentials
+	ep, err := transport.NewEndpoint(r.Name)
+	if err != nil {
+		return nil, err
+	}
+	if ep.Protocol != "ssh" {
+		if c.Token == "" {
+			return nil, nil
+		}
+		return &http.BasicAuth{Username: c.TokenUsername(), Password: c.Token}, nil
+	}
+	if c.SSHKey == "" && c.KnownHosts == "" {
+		return nil, nil
+	}
+	user := ep.User
+	if user == "" {
+		user = "git"
+	}
+	var helper *ssh.HostKeyCallbackHelper
+	var auth transport.AuthMethod
+	if c.SSHKey != "" {
+		keys, err := ssh.NewPublicKeys(user, []byte(c.SSHKey), "")
+		if err != nil {
+			return nil, err
+		}
+		helper, auth = &keys.HostKeyCallbackHelper, keys
+	} else {
+		agent, err := ssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, err
+		}
+		helper, auth = &agent.HostKeyCallbackHelper, agent
+	}
+	if c.KnownHosts != "" {
+		// the known hosts are parsed right away, so the temporary file is not needed afterwards
+		var secrets remoteSecrets
+		defer secrets.cleanup()
+		knownHosts, err := secrets.file("app-lego-known-hosts-*", c.KnownHosts)
+		if err != nil {
+			return nil, err
+		}
+		helper.HostKeyCallback, err = ssh.NewKnownHostsCallback(knownHosts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return auth, nil
+}
@@ -0,0 +1,340 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/pkg/os"
+	"github.com/beldeveloper/go-errors-context"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewHg creates a new instance of the Mercurial service.
+func NewHg(reposDir app.ReposDir) app.VcsSvc {
+	// the shared working copies refer to the repository by its path, so the path must not be relative
+	dir, err := filepath.Abs(string(reposDir))
+	if err != nil {
+		dir = string(reposDir)
+	}
+	return Hg{
+		reposDir: dir,
+		locker:   newRepoLocker(),
+	}
+}
+
+// Hg is a service that manages Mercurial repositories.
+// The named branches and tags are checked out into the working copies that share the repository store.
+type Hg struct {
+	reposDir string
+	locker   *repoLocker
+}
+
+// Types returns the repository types that are handled by the service.
+func (s Hg) Types() []string {
+	return []string{app.RepositoryTypeHg}
+}
+
+// CanMerge reports that the branches can't be merged, the Mercurial branches are built as they are.
+func (s Hg) CanMerge(r app.Repository) bool {
+	return false
+}
+
+// CheckName accepts any name, the remote is checked by the download.
+func (s Hg) CheckName(r app.Repository) error {
+	return nil
+}
+
+// DownloadRepository to the directory.
+// The leftovers of the previous attempt are removed first.
+func (s Hg) DownloadRepository(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	err := os.RemoveDir(worktreesPath(s.reposDir, r))
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.DownloadRepository.removeWorktrees",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	repoDir := s.reposDir + "/" + r.Alias
+	err = os.RecreateDir(repoDir)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.DownloadRepository.RecreateDir",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	env, cleanup, err := hgEnv(r.Credentials)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.DownloadRepository.hgEnv",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	defer cleanup()
+	_, err = os.Exec(ctx, os.Cmd{
+		Name: "hg",
+		Args: []string{"clone", "--noupdate", r.Name, "."},
+		Env:  env,
+		Dir:  repoDir,
+		Log:  true,
+	})
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Hg.DownloadRepository",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// UpdateRemote points the repository to the new default path.
+func (s Hg) UpdateRemote(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	err := os.WriteFile(s.reposDir+"/"+r.Alias+"/.hg/hgrc", fmt.Sprintf("[paths]\ndefault = %s\n", r.Name))
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Hg.UpdateRemote",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// MoveRepository moves the repository to the directory of the new alias.
+// The shared working copies are dropped, they are created again on the next build.
+func (s Hg) MoveRepository(ctx context.Context, from, to app.Repository) error {
+	defer s.locker.lock(from.ID)()
+	err := os.RemoveDir(worktreesPath(s.reposDir, from))
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.MoveRepository.removeWorktrees",
+			Params: errors.Params{"repository": from.ID},
+		})
+	}
+	err = os.MoveDir(s.reposDir+"/"+from.Alias, s.reposDir+"/"+to.Alias)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Hg.MoveRepository.MoveDir",
+		Params: errors.Params{"repository": from.ID, "from": from.Alias, "to": to.Alias},
+	})
+}
+
+// RemoveRepository removes the repository and the shared working copies.
+func (s Hg) RemoveRepository(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	err := os.RemoveDir(worktreesPath(s.reposDir, r))
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.RemoveRepository.removeWorktrees",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	err = os.RemoveDir(s.reposDir + "/" + r.Alias)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Hg.RemoveRepository.RemoveDir",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// Branches pulls the repository and returns its open named branches and tags.
+func (s Hg) Branches(ctx context.Context, r app.Repository) ([]app.VcsBranch, error) {
+	defer s.locker.lock(r.ID)()
+	repoDir := s.reposDir + "/" + r.Alias
+	err := s.pull(ctx, r)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.Branches.pull",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	heads, err := os.Exec(ctx, os.Cmd{
+		Name: "hg",
+		Args: []string{"branches", "--template", "{branch}\t{node}\n"},
+		Env:  []string{"HGPLAIN=1"},
+		Dir:  repoDir,
+	})
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.Branches.branches",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	tags, err := os.Exec(ctx, os.Cmd{
+		Name: "hg",
+		Args: []string{"tags", "--template", "{tag}\t{node}\n"},
+		Env:  []string{"HGPLAIN=1"},
+		Dir:  repoDir,
+	})
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.Branches.tags",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	branches := parseHgRevisions(heads, app.BranchTypeHead)
+	for _, b := range parseHgRevisions(tags, app.BranchTypeTag) {
+		if b.Name != "tip" {
+			branches = append(branches, b)
+		}
+	}
+	return branches, nil
+}
+
+// Fetch pulls the repository.
+func (s Hg) Fetch(ctx context.Context, r app.Repository) error {
+	defer s.locker.lock(r.ID)()
+	return errors.WrapContext(s.pull(ctx, r), errors.Context{
+		Path:   "svc.Hg.Fetch.pull",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// Commit returns the metadata of the pulled changeset.
+func (s Hg) Commit(ctx context.Context, r app.Repository, hash string) (app.Commit, error) {
+	out, err := os.Exec(ctx, os.Cmd{
+		Name: "hg",
+		Args: []string{"log", "--rev", hash, "--template", "{node}\x1f{author}\x1f{date|rfc3339date}\x1f{desc}"},
+		Env:  []string{"HGPLAIN=1"},
+		Dir:  s.reposDir + "/" + r.Alias,
+	})
+	if err != nil {
+		return app.Commit{}, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.Commit.log",
+			Params: errors.Params{"repository": r.ID, "hash": hash},
+		})
+	}
+	c, err := parseCommit(out, time.RFC3339)
+	return c, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Hg.Commit.parseCommit",
+		Params: errors.Params{"repository": r.ID, "hash": hash},
+	})
+}
+
+// Log returns the changesets that are ancestors of the "to" revision, but not of the "from" one, the recent first.
+// The history of the "to" revision is returned if "from" is empty.
+func (s Hg) Log(ctx context.Context, r app.Repository, from, to string, limit int) ([]app.Commit, error) {
+	rev := fmt.Sprintf("reverse(::%s)", hgString(to))
+	if from != "" {
+		rev = fmt.Sprintf("reverse(only(%s, %s))", hgString(to), hgString(from))
+	}
+	out, err := os.Exec(ctx, os.Cmd{
+		Name: "hg",
+		Args: []string{
+			"log", "--rev", rev, "--limit", strconv.Itoa(limit),
+			"--template", "{node}\x1f{author}\x1f{date|rfc3339date}\x1f{desc}\x1e",
+		},
+		Env: []string{"HGPLAIN=1"},
+		Dir: s.reposDir + "/" + r.Alias,
+	})
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.Log.log",
+			Params: errors.Params{"repository": r.ID, "from": from, "to": to},
+		})
+	}
+	res, err := parseCommits(out, time.RFC3339)
+	return res, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Hg.Log.parseCommits",
+		Params: errors.Params{"repository": r.ID, "from": from, "to": to},
+	})
+}
+
+// SwitchBranch pulls the repository and updates the branch working copy to the branch head or tag.
+func (s Hg) SwitchBranch(ctx context.Context, r app.Repository, b app.Branch) error {
+	defer s.locker.lock(r.ID)()
+	repoDir := s.reposDir + "/" + r.Alias
+	err := s.pull(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.SwitchBranch.pull",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	dir := s.BranchDir(r, b)
+	exists, err := os.Exists(dir)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Hg.SwitchBranch.Exists",
+			Params: errors.Params{"repository": r.ID, "branch": b.ID, "dir": dir},
+		})
+	}
+	if !exists {
+		_, err = os.Exec(ctx, os.Cmd{
+			Name: "hg",
+			Args: []string{"--config", "extensions.share=", "share", "--noupdate", repoDir, dir},
+			Env:  []string{"HGPLAIN=1"},
+			Dir:  s.reposDir,
+			Log:  true,
+		})
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Hg.SwitchBranch.share",
+				Params: errors.Params{"repository": r.ID, "branch": b.ID},
+			})
+		}
+	}
+	// the revset avoids treating the numeric branch names as the revision numbers
+	rev := fmt.Sprintf("max(branch(%s))", hgString("literal:"+b.Name))
+	if b.Type == app.BranchTypeTag {
+		rev = fmt.Sprintf("tag(%s)", hgString("literal:"+b.Name))
+	}
+	_, err = os.Exec(ctx, os.Cmd{
+		Name: "hg",
+		Args: []string{"update", "--clean", "--rev", rev},
+		Env:  []string{"HGPLAIN=1"},
+		Dir:  dir,
+		Log:  true,
+	})
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Hg.SwitchBranch.update",
+		Params: errors.Params{"repository": r.ID, "branch": b.ID, "branchName": b.Name},
+	})
+}
+
+// BranchDir returns the directory of the branch working copy.
+func (s Hg) BranchDir(r app.Repository, b app.Branch) string {
+	return branchPath(s.reposDir, r, b)
+}
+
+// CleanBranches removes the working copies of the deleted branches.
+func (s Hg) CleanBranches(ctx context.Context, r app.Repository, branches []app.Branch) error {
+	if len(branches) == 0 {
+		return nil
+	}
+	defer s.locker.lock(r.ID)()
+	return errors.WrapContext(removeWorktrees(s.reposDir, r, branches), errors.Context{
+		Path:   "svc.Hg.CleanBranches.removeWorktrees",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+func (s Hg) pull(ctx context.Context, r app.Repository) error {
+	env, cleanup, err := hgEnv(r.Credentials)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	_, err = os.Exec(ctx, os.Cmd{
+		Name: "hg",
+		Args: []string{"pull"},
+		Env:  env,
+		Dir:  s.reposDir + "/" + r.Alias,
+		Log:  true,
+	})
+	return err
+}
+
+// parseHgRevisions parses the "name<tab>node" lines.
+func parseHgRevisions(out, branchType string) []app.VcsBranch {
+	rows := strings.Split(out, "\n")
+	res := make([]app.VcsBranch, 0, len(rows))
+	for _, row := range rows {
+		parts := strings.Split(strings.TrimSpace(row), "\t")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			continue
+		}
+		res = append(res, app.VcsBranch{Type: branchType, Name: parts[0], Hash: parts[1]})
+	}
+	return res
+}
+
+// hgString quotes the string for the revset.
+func hgString(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
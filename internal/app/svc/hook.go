@@ -2,51 +2,99 @@ package svc
 
 import (
 	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
 	"github.com/beldeveloper/app-lego/pkg"
 	"github.com/beldeveloper/app-lego/rpc/hook"
 	"github.com/beldeveloper/go-errors-context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"time"
 )
 
 // NewHook creates a new instance of the hook client.
-func NewHook(client hook.HookClient) pkg.HookSvc {
-	return Hook{client: client}
+func NewHook(client hook.HookClient, timeouts app.HookTimeouts) pkg.HookSvc {
+	return Hook{client: client, timeouts: timeouts}
 }
 
 // Hook implements a hook client.
+// The calls of the unreachable hook handler fail with errtype.ErrUnavailable, so they can be retried later.
 type Hook struct {
-	client hook.HookClient
+	client   hook.HookClient
+	timeouts app.HookTimeouts
 }
 
 // BuildBranch calls hook handler in order to build a specific branch.
+// The build output is streamed if the request has the log receiver, unless the hook handler doesn't support it.
 func (s Hook) BuildBranch(ctx context.Context, req pkg.HookBuildBranchReq) (pkg.HookBuildBranchResp, error) {
-	var res pkg.HookBuildBranchResp
-	rpcRes, err := s.client.BuildBranch(ctx, &hook.BuildBranchReq{
+	rpcReq := &hook.BuildBranchReq{
 		Repo: &hook.Repo{
 			Id:    req.Repo.ID,
 			Type:  req.Repo.Type,
 			Alias: req.Repo.Alias,
 		},
 		Branch: &hook.Branch{
-			Id:     req.Branch.ID,
-			RepoId: req.Branch.RepoID,
-			Type:   req.Branch.Type,
-			Name:   req.Branch.Name,
-			Hash:   req.Branch.Hash,
+			Id:       req.Branch.ID,
+			RepoId:   req.Branch.RepoID,
+			Type:     req.Branch.Type,
+			Name:     req.Branch.Name,
+			Hash:     req.Branch.Hash,
+			BaseHash: req.Branch.BaseHash,
 		},
-	})
+		Commit: &hook.Commit{
+			Hash:        req.Commit.Hash,
+			Author:      req.Commit.Author,
+			CommittedAt: unixTime(req.Commit.CommittedAt),
+			Subject:     req.Commit.Subject,
+			Message:     req.Commit.Message,
+		},
+		Dir:          req.Dir,
+		CallbackAddr: req.CallbackAddr,
+	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.BuildBranch)
+	defer cancel()
+	if req.Log != nil {
+		rpcRes, err := s.streamBuildBranch(ctx, rpcReq, req.Log)
+		if status.Code(err) != codes.Unimplemented {
+			return buildBranchResp(rpcRes), errors.WrapContext(hookError(err), errors.Context{Path: "svc.Hook.BuildBranch.stream"})
+		}
+	}
+	rpcRes, err := s.client.BuildBranch(ctx, rpcReq)
 	if err != nil {
-		return res, errors.WrapContext(err, errors.Context{Path: "svc.Hook.BuildBranch"})
+		return pkg.HookBuildBranchResp{}, errors.WrapContext(hookError(err), errors.Context{Path: "svc.Hook.BuildBranch"})
 	}
-	res.Status = rpcRes.Status
-	if rpcRes.ErrorMsg != "" {
-		res.ErrorMsg = &rpcRes.ErrorMsg
+	return buildBranchResp(rpcRes), nil
+}
+
+// streamBuildBranch calls hook handler in order to build a specific branch and receives the build output.
+// The gRPC error is returned as it is, so the caller can tell whether the streaming is supported.
+func (s Hook) streamBuildBranch(ctx context.Context, req *hook.BuildBranchReq, log func(string)) (*hook.BuildBranchResp, error) {
+	stream, err := s.client.StreamBuildBranch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the build stream is finished without the result")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Log != "" {
+			log(e.Log)
+		}
+		if e.Result != nil {
+			return e.Result, nil
+		}
 	}
-	return res, nil
 }
 
 // Deploy calls hook handler in order to perform new deployment.
+// The deployment output is streamed if the request has the log receiver, unless the hook handler doesn't support it.
 func (s Hook) Deploy(ctx context.Context, req pkg.HookDeployReq) (pkg.HookDeployResp, error) {
-	var res pkg.HookDeployResp
 	rpcReq := &hook.DeployReq{
 		Repos:       make([]*hook.Repo, len(req.Repos)),
 		Deployments: make([]*hook.Deployment, len(req.Deployments)),
@@ -66,32 +114,119 @@ func (s Hook) Deploy(ctx context.Context, req pkg.HookDeployReq) (pkg.HookDeploy
 		}
 		for k, b := range d.Branches {
 			rpcDep.Branches[k] = &hook.Branch{
-				Id:     b.ID,
-				RepoId: b.RepoID,
-				Type:   b.Type,
-				Name:   b.Name,
-				Hash:   b.Hash,
+				Id:       b.ID,
+				RepoId:   b.RepoID,
+				Type:     b.Type,
+				Name:     b.Name,
+				Hash:     b.Hash,
+				BaseHash: b.BaseHash,
 			}
 		}
 		rpcReq.Deployments[i] = rpcDep
 	}
+	ctx, cancel := withTimeout(ctx, s.timeouts.Deploy)
+	defer cancel()
+	if req.Log != nil {
+		rpcRes, err := s.streamDeploy(ctx, rpcReq, req.Log)
+		if status.Code(err) != codes.Unimplemented {
+			return deployResp(rpcRes), errors.WrapContext(hookError(err), errors.Context{Path: "svc.Hook.Deploy.stream"})
+		}
+	}
 	rpcRes, err := s.client.Deploy(ctx, rpcReq)
 	if err != nil {
-		return res, errors.WrapContext(err, errors.Context{Path: "svc.Hook.Deploy"})
+		return pkg.HookDeployResp{}, errors.WrapContext(hookError(err), errors.Context{Path: "svc.Hook.Deploy"})
+	}
+	return deployResp(rpcRes), nil
+}
+
+// streamDeploy calls hook handler in order to perform new deployment and receives the deployment output.
+// The gRPC error is returned as it is, so the caller can tell whether the streaming is supported.
+func (s Hook) streamDeploy(ctx context.Context, req *hook.DeployReq, log func(uint64, string)) (*hook.DeployResp, error) {
+	stream, err := s.client.StreamDeploy(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		e, err := stream.Recv()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the deployment stream is finished without the result")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.Log != "" {
+			log(e.DeploymentId, e.Log)
+		}
+		if e.Result != nil {
+			return e.Result, nil
+		}
+	}
+}
+
+// CleanBranches calls hook handler in order to clean deleted branches of the repository.
+func (s Hook) CleanBranches(ctx context.Context, repo pkg.HookRepo, ids []uint64) error {
+	ctx, cancel := withTimeout(ctx, s.timeouts.CleanBranches)
+	defer cancel()
+	_, err := s.client.CleanBranches(ctx, &hook.CleanBranchesReq{
+		Ids: ids,
+		Repo: &hook.Repo{
+			Id:    repo.ID,
+			Type:  repo.Type,
+			Alias: repo.Alias,
+		},
+	})
+	return errors.WrapContext(hookError(err), errors.Context{Path: "svc.Hook.CleanBranches"})
+}
+
+// withTimeout limits the call by the timeout, the zero timeout means no limit.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// hookError marks the error of the unreachable hook handler, the rest errors are returned as they are.
+func hookError(err error) error {
+	if status.Code(err) == codes.Unavailable {
+		return fmt.Errorf("%w: %v", errtype.ErrUnavailable, err)
+	}
+	return err
+}
+
+func buildBranchResp(rpcRes *hook.BuildBranchResp) pkg.HookBuildBranchResp {
+	var res pkg.HookBuildBranchResp
+	if rpcRes == nil {
+		return res
+	}
+	res.Status = rpcRes.Status
+	res.JobID = rpcRes.JobId
+	if rpcRes.ErrorMsg != "" {
+		res.ErrorMsg = &rpcRes.ErrorMsg
+	}
+	return res
+}
+
+func deployResp(rpcRes *hook.DeployResp) pkg.HookDeployResp {
+	var res pkg.HookDeployResp
+	if rpcRes == nil {
+		return res
 	}
 	res.Statuses = make(map[uint64]pkg.HookDeployStatus, len(rpcRes.Statuses))
 	for k, v := range rpcRes.Statuses {
-		status := pkg.HookDeployStatus{Status: v.Status}
+		deployStatus := pkg.HookDeployStatus{Status: v.Status}
 		if v.ErrorMsg != "" {
-			status.ErrorMsg = &v.ErrorMsg
+			deployStatus.ErrorMsg = &v.ErrorMsg
 		}
-		res.Statuses[k] = status
+		res.Statuses[k] = deployStatus
 	}
-	return res, nil
+	return res
 }
 
-// CleanBranches calls hook handler in order to clean deleted branches.
-func (s Hook) CleanBranches(ctx context.Context, ids []uint64) error {
-	_, err := s.client.CleanBranches(ctx, &hook.CleanBranchesReq{Ids: ids})
-	return errors.WrapContext(err, errors.Context{Path: "svc.Hook.CleanBranches"})
+// unixTime converts the time to the unix seconds, the zero time is converted to 0.
+func unixTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
 }
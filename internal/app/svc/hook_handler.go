@@ -0,0 +1,159 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/go-errors-context"
+	"google.golang.org/grpc/connectivity"
+	"log"
+	"strings"
+)
+
+// HookHandlerNameMaxLength defines the maximal length of the hook handler name and address.
+const HookHandlerNameMaxLength = 200
+
+// NewHookHandler creates a new instance of the hook handlers service.
+func NewHookHandler(handlerRepo app.HookHandlerRepo, repRepo app.RepositoryRepo, pool *HookPool) app.HookHandlerSvc {
+	return HookHandler{handlerRepo: handlerRepo, repRepo: repRepo, pool: pool}
+}
+
+// HookHandler is a service that manages the hook handler endpoints.
+type HookHandler struct {
+	handlerRepo app.HookHandlerRepo
+	repRepo     app.RepositoryRepo
+	pool        *HookPool
+}
+
+// List all hook handlers.
+func (s HookHandler) List(ctx context.Context) ([]app.HookHandler, error) {
+	res, err := s.handlerRepo.FindAll(ctx)
+	return res, errors.WrapContext(err, errors.Context{Path: "svc.HookHandler.List.FindAll"})
+}
+
+// Add new hook handler.
+func (s HookHandler) Add(ctx context.Context, f app.FormAddHookHandler) (app.HookHandler, error) {
+	h := app.HookHandler{
+		Name:   strings.TrimSpace(f.Name),
+		Addr:   strings.TrimSpace(f.Addr),
+		Deploy: f.Deploy,
+	}
+	err := s.validate(ctx, h)
+	if err != nil {
+		return h, errors.WrapContext(err, errors.Context{Path: "svc.HookHandler.Add.validate"})
+	}
+	h, err = s.handlerRepo.Add(ctx, h)
+	if err != nil {
+		return h, errors.WrapContext(err, errors.Context{Path: "svc.HookHandler.Add.Add"})
+	}
+	log.Printf("The hook handler #%d is added\n", h.ID)
+	return h, nil
+}
+
+// Update modifies the hook handler settings, the new address is dialed on the next call.
+func (s HookHandler) Update(ctx context.Context, f app.FormUpdateHookHandler) (app.HookHandler, error) {
+	h, err := s.handlerRepo.FindByID(ctx, f.ID)
+	if err != nil {
+		return h, errors.WrapContext(err, errors.Context{Path: "svc.HookHandler.Update.FindByID"})
+	}
+	if f.Name != nil {
+		h.Name = strings.TrimSpace(*f.Name)
+	}
+	if f.Addr != nil {
+		h.Addr = strings.TrimSpace(*f.Addr)
+	}
+	if f.Deploy != nil {
+		h.Deploy = *f.Deploy
+	}
+	err = s.validate(ctx, h)
+	if err != nil {
+		return h, errors.WrapContext(err, errors.Context{Path: "svc.HookHandler.Update.validate"})
+	}
+	h, err = s.handlerRepo.Update(ctx, h)
+	if err != nil {
+		return h, errors.WrapContext(err, errors.Context{
+			Path:   "svc.HookHandler.Update.Update",
+			Params: errors.Params{"handler": h.ID},
+		})
+	}
+	log.Printf("The hook handler #%d is updated\n", h.ID)
+	return h, nil
+}
+
+// Delete removes the hook handler, the handler that is assigned to any repository can't be removed.
+func (s HookHandler) Delete(ctx context.Context, id uint64) error {
+	h, err := s.handlerRepo.FindByID(ctx, id)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{Path: "svc.HookHandler.Delete.FindByID"})
+	}
+	repos, err := s.repRepo.FindAll(ctx)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{Path: "svc.HookHandler.Delete.findRepos"})
+	}
+	for _, r := range repos {
+		if r.HookHandlerID != nil && *r.HookHandlerID == h.ID {
+			return fmt.Errorf("%w: hook handler is assigned to repository %s", errtype.ErrBadInput, r.Alias)
+		}
+	}
+	err = s.handlerRepo.Delete(ctx, h.ID)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.HookHandler.Delete.Delete",
+			Params: errors.Params{"handler": h.ID},
+		})
+	}
+	s.pool.Remove(h.ID)
+	log.Printf("The hook handler #%d is deleted\n", h.ID)
+	return nil
+}
+
+// Health returns the connection states of the default hook handler (if it's configured) and the registered ones.
+// The handler that isn't connected yet is dialed, so it reports the connecting state first.
+func (s HookHandler) Health(ctx context.Context) ([]app.HookHealth, error) {
+	handlers, err := s.handlerRepo.FindAll(ctx)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{Path: "svc.HookHandler.Health.FindAll"})
+	}
+	if h, ok := s.pool.DefaultHandler(); ok {
+		handlers = append([]app.HookHandler{h}, handlers...)
+	}
+	res := make([]app.HookHealth, len(handlers))
+	for i, h := range handlers {
+		state, err := s.pool.State(h)
+		if err != nil {
+			log.Println(errors.WrapContext(err, errors.Context{
+				Path:   "svc.HookHandler.Health.State",
+				Params: errors.Params{"handler": h.ID},
+			}))
+		}
+		res[i] = app.HookHealth{
+			ID:      h.ID,
+			Name:    h.Name,
+			Addr:    h.Addr,
+			State:   stateName(state),
+			Healthy: state == connectivity.Ready,
+		}
+	}
+	return res, nil
+}
+
+// validate checks that the hook handler has the address and the unique name.
+func (s HookHandler) validate(ctx context.Context, h app.HookHandler) error {
+	if h.Name == "" || len(h.Name) > HookHandlerNameMaxLength {
+		return fmt.Errorf("%w: hook handler name must be from 1 to %d characters", errtype.ErrBadInput, HookHandlerNameMaxLength)
+	}
+	if h.Addr == "" || len(h.Addr) > HookHandlerNameMaxLength {
+		return fmt.Errorf("%w: hook handler address must be from 1 to %d characters", errtype.ErrBadInput, HookHandlerNameMaxLength)
+	}
+	handlers, err := s.handlerRepo.FindAll(ctx)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{Path: "svc.HookHandler.validate.FindAll"})
+	}
+	for _, other := range handlers {
+		if other.Name == h.Name && other.ID != h.ID {
+			return fmt.Errorf("%w: hook handler name %s is already used", errtype.ErrBadInput, h.Name)
+		}
+	}
+	return nil
+}
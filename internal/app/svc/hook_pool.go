@@ -0,0 +1,138 @@
+package svc
+
+import (
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/app-lego/pkg"
+	"github.com/beldeveloper/app-lego/rpc/hook"
+	"github.com/beldeveloper/go-errors-context"
+	"google.golang.org/grpc"
+	grpcbackoff "google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HookConnectTimeout defines the timeout of the hook handler connection attempt.
+	HookConnectTimeout = 5 * time.Second
+	// HookMaxBackoff defines the maximal delay between the hook handler connection attempts.
+	HookMaxBackoff = 30 * time.Second
+	// HookRetryDelay defines the delay before the call of the unreachable hook handler is made again.
+	HookRetryDelay = 30 * time.Second
+)
+
+// HookDialOptions defines the credentials of the hook handler connections.
+type HookDialOptions []grpc.DialOption
+
+// NewHookPool creates a new instance of the hook handler connections pool.
+// The default hook handler address is empty if the handlers are assigned to the repositories only.
+// The connections are not encrypted if the dial options don't define the transport credentials.
+func NewHookPool(defaultAddr app.HookHandlerAddr, timeouts app.HookTimeouts, opts HookDialOptions) *HookPool {
+	return &HookPool{
+		defaultAddr: string(defaultAddr),
+		timeouts:    timeouts,
+		opts:        opts,
+		conns:       make(map[uint64]hookConn),
+	}
+}
+
+// HookPool keeps the connections to the hook handlers.
+// The connection is established in background on the first use and it reconnects with the backoff,
+// so the unreachable hook handler doesn't block anything.
+type HookPool struct {
+	defaultAddr string
+	timeouts    app.HookTimeouts
+	opts        HookDialOptions
+	mu          sync.Mutex
+	conns       map[uint64]hookConn
+}
+
+type hookConn struct {
+	addr string
+	conn *grpc.ClientConn
+	svc  pkg.HookSvc
+}
+
+// Default returns the client of the default hook handler.
+func (p *HookPool) Default() (pkg.HookSvc, error) {
+	h, ok := p.DefaultHandler()
+	if !ok {
+		return nil, fmt.Errorf("%w: the default hook handler isn't configured", errtype.ErrNotFound)
+	}
+	return p.Client(h)
+}
+
+// DefaultHandler returns the default hook handler, it is false if the handler isn't configured.
+func (p *HookPool) DefaultHandler() (app.HookHandler, bool) {
+	return app.HookHandler{Name: "default", Addr: p.defaultAddr, Deploy: true}, p.defaultAddr != ""
+}
+
+// Client returns the client of the hook handler, the connection is dialed again if the address is changed.
+func (p *HookPool) Client(h app.HookHandler) (pkg.HookSvc, error) {
+	c, err := p.conn(h)
+	if err != nil {
+		return nil, err
+	}
+	return c.svc, nil
+}
+
+// State returns the connection state of the hook handler, the connection is dialed if it isn't yet.
+func (p *HookPool) State(h app.HookHandler) (connectivity.State, error) {
+	c, err := p.conn(h)
+	if err != nil {
+		return connectivity.Shutdown, err
+	}
+	return c.conn.GetState(), nil
+}
+
+// Remove closes the connection of the deleted hook handler, the calls in progress fail.
+func (p *HookPool) Remove(id uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, exists := p.conns[id]
+	if !exists {
+		return
+	}
+	_ = c.conn.Close()
+	delete(p.conns, id)
+}
+
+func (p *HookPool) conn(h app.HookHandler) (hookConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, exists := p.conns[h.ID]
+	if exists && c.addr == h.Addr {
+		return c, nil
+	}
+	if exists {
+		// the calls in progress over the old address fail, the same way as if the handler was restarted
+		_ = c.conn.Close()
+		delete(p.conns, h.ID)
+	}
+	bc := grpcbackoff.DefaultConfig
+	bc.MaxDelay = HookMaxBackoff
+	opts := make([]grpc.DialOption, 0, len(p.opts)+2)
+	opts = append(opts, p.opts...)
+	if len(p.opts) == 0 {
+		opts = append(opts, grpc.WithInsecure())
+	}
+	opts = append(opts, grpc.WithConnectParams(grpc.ConnectParams{Backoff: bc, MinConnectTimeout: HookConnectTimeout}))
+	conn, err := grpc.Dial(h.Addr, opts...)
+	if err != nil {
+		return c, errors.WrapContext(err, errors.Context{
+			Path:   "svc.HookPool.conn.Dial",
+			Params: errors.Params{"handler": h.ID, "addr": h.Addr},
+		})
+	}
+	c = hookConn{addr: h.Addr, conn: conn, svc: NewHook(hook.NewHookClient(conn), p.timeouts)}
+	p.conns[h.ID] = c
+	return c, nil
+}
+
+// stateName converts the connection state to the name that is exposed by the API.
+func stateName(state connectivity.State) string {
+	return strings.ToLower(state.String())
+}
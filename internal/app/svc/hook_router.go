@@ -0,0 +1,122 @@
+package svc
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/pkg"
+	"github.com/beldeveloper/go-errors-context"
+)
+
+// NewHookRouter creates a new instance of the hook client that routes the calls to the hook handlers.
+func NewHookRouter(pool *HookPool, handlerRepo app.HookHandlerRepo, repRepo app.RepositoryRepo) pkg.HookSvc {
+	return HookRouter{pool: pool, handlerRepo: handlerRepo, repRepo: repRepo}
+}
+
+// HookRouter implements a hook client that calls the hook handler assigned to the repository,
+// the repositories without the assigned handler are served by the default one.
+// The deployments are sent to every handler that receives them, or to the default one if there is no such handler.
+type HookRouter struct {
+	pool        *HookPool
+	handlerRepo app.HookHandlerRepo
+	repRepo     app.RepositoryRepo
+}
+
+// BuildBranch calls the hook handler of the branch repository in order to build the branch.
+func (s HookRouter) BuildBranch(ctx context.Context, req pkg.HookBuildBranchReq) (pkg.HookBuildBranchResp, error) {
+	h, err := s.repoHandler(ctx, req.Repo.ID)
+	if err != nil {
+		return pkg.HookBuildBranchResp{}, errors.WrapContext(err, errors.Context{
+			Path:   "svc.HookRouter.BuildBranch.repoHandler",
+			Params: errors.Params{"repository": req.Repo.ID},
+		})
+	}
+	res, err := h.BuildBranch(ctx, req)
+	return res, errors.WrapContext(err, errors.Context{
+		Path:   "svc.HookRouter.BuildBranch",
+		Params: errors.Params{"repository": req.Repo.ID},
+	})
+}
+
+// Deploy calls the hook handlers that receive the deployments one by one.
+// The deployment is ready if every handler reports it ready, otherwise the first other status is returned.
+func (s HookRouter) Deploy(ctx context.Context, req pkg.HookDeployReq) (pkg.HookDeployResp, error) {
+	handlers, err := s.deployHandlers(ctx)
+	if err != nil {
+		return pkg.HookDeployResp{}, errors.WrapContext(err, errors.Context{Path: "svc.HookRouter.Deploy.deployHandlers"})
+	}
+	res := pkg.HookDeployResp{Statuses: make(map[uint64]pkg.HookDeployStatus)}
+	for _, h := range handlers {
+		hRes, err := h.Deploy(ctx, req)
+		if err != nil {
+			return res, errors.WrapContext(err, errors.Context{Path: "svc.HookRouter.Deploy"})
+		}
+		for id, status := range hRes.Statuses {
+			if cur, exists := res.Statuses[id]; exists && cur.Status != app.DeploymentStatusReady {
+				continue
+			}
+			res.Statuses[id] = status
+		}
+	}
+	return res, nil
+}
+
+// CleanBranches calls the hook handler of the repository in order to clean its deleted branches.
+func (s HookRouter) CleanBranches(ctx context.Context, repo pkg.HookRepo, ids []uint64) error {
+	h, err := s.repoHandler(ctx, repo.ID)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.HookRouter.CleanBranches.repoHandler",
+			Params: errors.Params{"repository": repo.ID},
+		})
+	}
+	return errors.WrapContext(h.CleanBranches(ctx, repo, ids), errors.Context{
+		Path:   "svc.HookRouter.CleanBranches",
+		Params: errors.Params{"repository": repo.ID},
+	})
+}
+
+// repoHandler returns the client of the hook handler that is assigned to the repository.
+func (s HookRouter) repoHandler(ctx context.Context, repoID uint64) (pkg.HookSvc, error) {
+	r, err := s.repRepo.FindByID(ctx, repoID)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{Path: "svc.HookRouter.repoHandler.findRepo"})
+	}
+	if r.HookHandlerID == nil {
+		return s.pool.Default()
+	}
+	h, err := s.handlerRepo.FindByID(ctx, *r.HookHandlerID)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.HookRouter.repoHandler.findHandler",
+			Params: errors.Params{"handler": *r.HookHandlerID},
+		})
+	}
+	return s.pool.Client(h)
+}
+
+// deployHandlers returns the clients of the hook handlers that receive the deployments.
+func (s HookRouter) deployHandlers(ctx context.Context) ([]pkg.HookSvc, error) {
+	handlers, err := s.handlerRepo.FindAll(ctx)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{Path: "svc.HookRouter.deployHandlers.FindAll"})
+	}
+	res := make([]pkg.HookSvc, 0, len(handlers))
+	for _, h := range handlers {
+		if !h.Deploy {
+			continue
+		}
+		c, err := s.pool.Client(h)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	if len(res) > 0 {
+		return res, nil
+	}
+	c, err := s.pool.Default()
+	if err != nil {
+		return nil, err
+	}
+	return []pkg.HookSvc{c}, nil
+}
@@ -0,0 +1,47 @@
+package svc
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/beldeveloper/app-lego/internal/app"
+)
+
+// IntegrationNameMaxLength defines the maximal length of the integration branch name that joins the component names.
+const IntegrationNameMaxLength = 200
+
+// integrationHash returns the revision of the integration branch, it changes whenever any component moves.
+func integrationHash(components []app.BranchComponent) string {
+	h := sha1.New()
+	for _, c := range components {
+		h.Write([]byte(c.Hash + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// integrationComponents returns the components of the integration branch with their current revisions.
+// It reports false if any component is deleted.
+func integrationComponents(b app.Branch, hashes map[uint64]string) ([]app.BranchComponent, bool) {
+	res := make([]app.BranchComponent, len(b.Components))
+	for i, c := range b.Components {
+		hash, exists := hashes[c.ID]
+		if !exists {
+			return nil, false
+		}
+		c.Hash = hash
+		res[i] = c
+	}
+	return res, len(res) > 0
+}
+
+// sameComponents checks whether both integration branches merge the same branches in the same order.
+func sameComponents(a, b []app.BranchComponent) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ID != b[i].ID {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,33 @@
+package svc
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"log"
+	"time"
+)
+
+// keepLease extends the lease of the claimed work in background until the returned function is called.
+func keepLease(ctx context.Context, extend func(ctx context.Context) error) func() {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(app.LeaseTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := extend(ctx); err != nil && ctx.Err() == nil {
+					log.Println(err)
+				}
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
@@ -0,0 +1,239 @@
+package svc
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/go-errors-context"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localBranchName is a name of the only branch of the local repository.
+const localBranchName = "local"
+
+// NewLocal creates a new instance of the service for the local directories inside the root directory.
+// The files and directories that match any of the ignore patterns (e.g. the build output) don't affect the fingerprint.
+func NewLocal(root string, ignore []string) app.VcsSvc {
+	return Local{root: root, ignore: ignore}
+}
+
+// Local is a service that treats a plain directory on disk as a repository with the only branch.
+// The directory is used in place, it is never copied or removed; the branch hash is a fingerprint of its files.
+// The repository name is a path inside the root directory, the relative one is resolved against the root.
+type Local struct {
+	root   string
+	ignore []string
+}
+
+// Types returns the repository types that are handled by the service.
+func (s Local) Types() []string {
+	return []string{app.RepositoryTypeLocal}
+}
+
+// CanMerge reports that the branches can't be merged, the directory has the only branch.
+func (s Local) CanMerge(r app.Repository) bool {
+	return false
+}
+
+// CheckName checks that the name is an existing directory inside the root.
+func (s Local) CheckName(r app.Repository) error {
+	dir, err := s.dir(r)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: the directory %s doesn't exist", errtype.ErrBadInput, r.Name)
+	}
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a directory", errtype.ErrBadInput, r.Name)
+	}
+	return nil
+}
+
+// DownloadRepository checks that the directory exists.
+func (s Local) DownloadRepository(ctx context.Context, r app.Repository) error {
+	_, _, err := s.fingerprint(ctx, r)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Local.DownloadRepository",
+		Params: errors.Params{"repository": r.ID, "dir": r.Name},
+	})
+}
+
+// UpdateRemote does nothing, the directory is read by its path.
+func (s Local) UpdateRemote(ctx context.Context, r app.Repository) error {
+	return nil
+}
+
+// MoveRepository does nothing, the directory doesn't depend on the alias.
+func (s Local) MoveRepository(ctx context.Context, from, to app.Repository) error {
+	return nil
+}
+
+// RemoveRepository does nothing, the directory doesn't belong to the application.
+func (s Local) RemoveRepository(ctx context.Context, r app.Repository) error {
+	return nil
+}
+
+// Branches returns the only branch with the current fingerprint of the directory.
+func (s Local) Branches(ctx context.Context, r app.Repository) ([]app.VcsBranch, error) {
+	hash, _, err := s.fingerprint(ctx, r)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Local.Branches.fingerprint",
+			Params: errors.Params{"repository": r.ID, "dir": r.Name},
+		})
+	}
+	return []app.VcsBranch{{Type: app.BranchTypeHead, Name: localBranchName, Hash: hash}}, nil
+}
+
+// Fetch does nothing, the directory is always up-to-date.
+func (s Local) Fetch(ctx context.Context, r app.Repository) error {
+	return nil
+}
+
+// Commit describes the current state of the directory, the commit time is the time of the last file modification.
+func (s Local) Commit(ctx context.Context, r app.Repository, hash string) (app.Commit, error) {
+	hash, modTime, err := s.fingerprint(ctx, r)
+	if err != nil {
+		return app.Commit{}, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Local.Commit.fingerprint",
+			Params: errors.Params{"repository": r.ID, "dir": r.Name},
+		})
+	}
+	return app.Commit{Hash: hash, CommittedAt: modTime, Subject: "Local changes"}, nil
+}
+
+// Log returns no commits, the directory has no history.
+func (s Local) Log(ctx context.Context, r app.Repository, from, to string, limit int) ([]app.Commit, error) {
+	return []app.Commit{}, nil
+}
+
+// SwitchBranch checks that the directory still exists, the branch is built in place.
+func (s Local) SwitchBranch(ctx context.Context, r app.Repository, b app.Branch) error {
+	_, _, err := s.fingerprint(ctx, r)
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Local.SwitchBranch",
+		Params: errors.Params{"repository": r.ID, "dir": r.Name},
+	})
+}
+
+// BranchDir returns the directory itself, it is empty if the directory is outside the root.
+func (s Local) BranchDir(r app.Repository, b app.Branch) string {
+	dir, err := s.dir(r)
+	if err != nil {
+		return ""
+	}
+	return dir
+}
+
+// CleanBranches does nothing, the branch has no files of its own.
+func (s Local) CleanBranches(ctx context.Context, r app.Repository, branches []app.Branch) error {
+	return nil
+}
+
+// fingerprint hashes the paths, sizes, modes and modification times of the directory files (only the paths of the
+// subdirectories) and returns the hash along with the time of the last modification.
+// The VCS metadata directories and the ignored paths are skipped.
+func (s Local) fingerprint(ctx context.Context, r app.Repository) (string, time.Time, error) {
+	root, err := s.dir(r)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	h := sha1.New()
+	var modTime time.Time
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if path == root && !d.IsDir() {
+			return fmt.Errorf("not a directory: %s", root)
+		}
+		if d.IsDir() && (d.Name() == ".git" || d.Name() == ".hg") {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if path != root && s.ignored(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			// the directory times change along with the ignored files in it, so only the name is hashed
+			fmt.Fprintf(h, "%s/\n", rel)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%s\x00%d\n", rel, info.Size(), info.Mode(), info.ModTime().UnixNano())
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+		return nil
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), modTime, nil
+}
+
+// ignored checks whether the path (relative to the directory) matches any of the ignore patterns.
+// The pattern is matched against the base name and the whole slash separated path, e.g. "*.log" or "web/dist".
+func (s Local) ignored(rel string) bool {
+	rel = filepath.ToSlash(rel)
+	name := rel[strings.LastIndex(rel, "/")+1:]
+	for _, p := range s.ignore {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(p, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dir returns the absolute path of the directory, the symbolic links are resolved
+// so that neither the path nor a link in it can lead outside the root.
+func (s Local) dir(r app.Repository) (string, error) {
+	root, err := filepath.Abs(s.root)
+	if err == nil {
+		root, err = filepath.EvalSymlinks(root)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid local root %s: %w", s.root, err)
+	}
+	dir := r.Name
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: the directory %s is outside the local root", errtype.ErrBadInput, r.Name)
+	}
+	return dir, nil
+}
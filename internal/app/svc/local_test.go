@@ -0,0 +1,114 @@
+package svc
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/go-errors-context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalFingerprint(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		changed bool
+	}{
+		{name: "source", file: "src/app.go", changed: true},
+		{name: "new source", file: "src/util.go", changed: true},
+		{name: "ignored directory", file: "dist/app.js", changed: false},
+		{name: "ignored nested directory", file: "web/dist/app.js", changed: false},
+		{name: "ignored name", file: "src/build.log", changed: false},
+		{name: "ignored path", file: "web/cache/index", changed: false},
+		{name: "vcs metadata", file: ".git/index", changed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeLocalFile(t, root, "shop/src/app.go", "package app")
+			writeLocalFile(t, root, "shop/web/index.html", "<html></html>")
+			s := Local{root: root, ignore: []string{"dist", "*.log", "web/cache"}}
+			r := app.Repository{ID: 1, Type: app.RepositoryTypeLocal, Name: "shop"}
+			before, _, err := s.fingerprint(context.Background(), r)
+			if err != nil {
+				t.Fatalf("fingerprint: %v", err)
+			}
+			writeLocalFile(t, root, filepath.Join("shop", tt.file), "changed content")
+			after, _, err := s.fingerprint(context.Background(), r)
+			if err != nil {
+				t.Fatalf("fingerprint: %v", err)
+			}
+			if changed := before != after; changed != tt.changed {
+				t.Errorf("fingerprint changed = %v, want %v", changed, tt.changed)
+			}
+		})
+	}
+}
+
+func TestLocalDir(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "root")
+	writeLocalFile(t, root, "shop/app.go", "package app")
+	writeLocalFile(t, base, "secret/key", "secret")
+	links := map[string]string{
+		"root/inner":   filepath.Join(root, "shop"),
+		"root/outer":   filepath.Join(base, "secret"),
+		"root/shop/up": base,
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(base, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		dir  string
+		want string
+		err  error
+	}{
+		{name: "relative", dir: "shop", want: filepath.Join(root, "shop")},
+		{name: "absolute", dir: filepath.Join(root, "shop"), want: filepath.Join(root, "shop")},
+		{name: "root", dir: ".", want: root},
+		{name: "dot dot inside", dir: "shop/../shop", want: filepath.Join(root, "shop")},
+		{name: "dot dot outside", dir: "../secret", err: errtype.ErrBadInput},
+		{name: "absolute outside", dir: filepath.Join(base, "secret"), err: errtype.ErrBadInput},
+		{name: "symlink inside", dir: "inner", want: filepath.Join(root, "shop")},
+		{name: "symlink outside", dir: "outer", err: errtype.ErrBadInput},
+		{name: "nested symlink outside", dir: "shop/up/secret", err: errtype.ErrBadInput},
+		{name: "missing", dir: "missing", err: os.ErrNotExist},
+	}
+	s := Local{root: root}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := s.dir(app.Repository{ID: 1, Type: app.RepositoryTypeLocal, Name: tt.dir})
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("dir(%q) error = %v, want %v", tt.dir, err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dir(%q): %v", tt.dir, err)
+			}
+			if dir != tt.want {
+				t.Errorf("dir(%q) = %q, want %q", tt.dir, dir, tt.want)
+			}
+		})
+	}
+}
+
+func writeLocalFile(t *testing.T, root, name, content string) {
+	t.Helper()
+	name = filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
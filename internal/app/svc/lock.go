@@ -0,0 +1,26 @@
+package svc
+
+import "sync"
+
+// repoLocker serializes the operations that touch the same repository working copy.
+type repoLocker struct {
+	mu    sync.Mutex
+	locks map[uint64]*sync.Mutex
+}
+
+func newRepoLocker() *repoLocker {
+	return &repoLocker{locks: make(map[uint64]*sync.Mutex)}
+}
+
+// lock waits until the repository is free and returns the function that releases it.
+func (l *repoLocker) lock(id uint64) func() {
+	l.mu.Lock()
+	m, exists := l.locks[id]
+	if !exists {
+		m = &sync.Mutex{}
+		l.locks[id] = m
+	}
+	l.mu.Unlock()
+	m.Lock()
+	return m.Unlock
+}
@@ -0,0 +1,290 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/go-errors-context"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// LogKeepBuilds defines how many latest builds of the branch or deployment keep their logs.
+	LogKeepBuilds = 10
+	// LogPollInterval defines how often the followed log is checked for the new lines.
+	LogPollInterval = time.Second
+	// LogMaxLineLength defines the maximal length of the log line, the longer lines are split.
+	LogMaxLineLength = 16 * 1024
+)
+
+// NewLog creates a new instance of the build logs service.
+func NewLog(logRepo app.LogRepo, branchRepo app.BranchRepo, deployRepo app.DeploymentRepo) app.LogSvc {
+	return Log{logRepo: logRepo, branchRepo: branchRepo, deployRepo: deployRepo}
+}
+
+// Log is a service that keeps the output of the branch builds and deployments.
+type Log struct {
+	logRepo    app.LogRepo
+	branchRepo app.BranchRepo
+	deployRepo app.DeploymentRepo
+}
+
+// Start begins the new build log of the branch or deployment, the logs of the old builds are deleted.
+func (s Log) Start(ctx context.Context, kind string, ownerID uint64) (app.BuildLog, error) {
+	last, err := s.logRepo.LastBuild(ctx, kind, ownerID)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Log.Start.LastBuild",
+			Params: errors.Params{"kind": kind, "owner": ownerID},
+		})
+	}
+	build := last + 1
+	err = s.logRepo.DeleteBefore(ctx, kind, ownerID, build-LogKeepBuilds+1)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.Log.Start.DeleteBefore",
+			Params: errors.Params{"kind": kind, "owner": ownerID},
+		}))
+	}
+	return &buildLog{repo: s.logRepo, kind: kind, ownerID: ownerID, build: build}, nil
+}
+
+// Resume continues the latest build log of the branch or deployment, e.g. with the output reported in background.
+func (s Log) Resume(ctx context.Context, kind string, ownerID uint64) (app.BuildLog, error) {
+	last, err := s.logRepo.LastBuild(ctx, kind, ownerID)
+	if err != nil {
+		return nil, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Log.Resume.LastBuild",
+			Params: errors.Params{"kind": kind, "owner": ownerID},
+		})
+	}
+	if last == 0 {
+		last = 1
+	}
+	return &buildLog{repo: s.logRepo, kind: kind, ownerID: ownerID, build: last}, nil
+}
+
+// Read sends the log lines of the build, the latest build is read by default.
+// The followed log is sent until the build is finished or the context is canceled.
+func (s Log) Read(ctx context.Context, q app.LogQuery, send func(app.LogLine) error) error {
+	if q.Tail < 0 || q.Build < 0 {
+		return fmt.Errorf("%w: tail and build must not be negative", errtype.ErrBadInput)
+	}
+	last, err := s.lastBuild(ctx, q)
+	if err != nil {
+		return err
+	}
+	build := q.Build
+	if build == 0 {
+		build = last
+	}
+	var lines []app.LogLine
+	if q.Tail > 0 {
+		lines, err = s.logRepo.FindTail(ctx, q.Kind, q.OwnerID, build, q.Tail)
+	} else {
+		lines, err = s.logRepo.FindAfter(ctx, q.Kind, q.OwnerID, build, 0)
+	}
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Log.Read.find",
+			Params: errors.Params{"kind": q.Kind, "owner": q.OwnerID, "build": build},
+		})
+	}
+	var lastID uint64
+	sendLines := func(lines []app.LogLine) error {
+		for _, l := range lines {
+			err := send(l)
+			if err != nil {
+				return err
+			}
+			lastID = l.ID
+		}
+		return nil
+	}
+	err = sendLines(lines)
+	if err != nil {
+		return err
+	}
+	for q.Follow {
+		running, err := s.running(ctx, q, build)
+		if err != nil {
+			return err
+		}
+		if running {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(LogPollInterval):
+			}
+		}
+		// the finished build is read once more for the lines that were saved right before the finish
+		lines, err = s.logRepo.FindAfter(ctx, q.Kind, q.OwnerID, build, lastID)
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Log.Read.FindAfter",
+				Params: errors.Params{"kind": q.Kind, "owner": q.OwnerID, "build": build},
+			})
+		}
+		err = sendLines(lines)
+		if err != nil || !running {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the logs of all builds of the deleted branches or deployments.
+func (s Log) Delete(ctx context.Context, kind string, ownerIDs []uint64) error {
+	return errors.WrapContext(s.logRepo.DeleteByOwners(ctx, kind, ownerIDs), errors.Context{
+		Path:   "svc.Log.Delete.DeleteByOwners",
+		Params: errors.Params{"kind": kind, "owners": ownerIDs},
+	})
+}
+
+// lastBuild checks that the owner of the logs exists and returns the number of its latest build.
+func (s Log) lastBuild(ctx context.Context, q app.LogQuery) (int, error) {
+	var err error
+	switch q.Kind {
+	case app.LogKindBranch:
+		_, err = s.branchRepo.FindByID(ctx, q.OwnerID)
+	case app.LogKindDeployment:
+		_, err = s.deployRepo.FindByID(ctx, q.OwnerID)
+	default:
+		err = fmt.Errorf("%w: unknown log kind: %s", errtype.ErrBadInput, q.Kind)
+	}
+	if err != nil {
+		return 0, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Log.lastBuild.findOwner",
+			Params: errors.Params{"kind": q.Kind, "owner": q.OwnerID},
+		})
+	}
+	last, err := s.logRepo.LastBuild(ctx, q.Kind, q.OwnerID)
+	return last, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Log.lastBuild.LastBuild",
+		Params: errors.Params{"kind": q.Kind, "owner": q.OwnerID},
+	})
+}
+
+// running checks whether the build is still writing its log, i.e. it is the latest build of the building owner.
+func (s Log) running(ctx context.Context, q app.LogQuery, build int) (bool, error) {
+	last, err := s.logRepo.LastBuild(ctx, q.Kind, q.OwnerID)
+	if err != nil {
+		return false, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Log.running.LastBuild",
+			Params: errors.Params{"kind": q.Kind, "owner": q.OwnerID},
+		})
+	}
+	if build != last {
+		return false, nil
+	}
+	var building bool
+	switch q.Kind {
+	case app.LogKindBranch:
+		var b app.Branch
+		b, err = s.branchRepo.FindByID(ctx, q.OwnerID)
+		building = b.Status == app.BranchStatusBuilding
+	case app.LogKindDeployment:
+		var d app.Deployment
+		d, err = s.deployRepo.FindByID(ctx, q.OwnerID)
+		building = d.Status == app.DeploymentStatusBuilding
+	}
+	if errors.Is(err, errtype.ErrNotFound) {
+		return false, nil
+	}
+	return building, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Log.running.findOwner",
+		Params: errors.Params{"kind": q.Kind, "owner": q.OwnerID},
+	})
+}
+
+// startLog begins the new build log, the output is discarded if the log can't be started.
+func startLog(ctx context.Context, logSvc app.LogSvc, kind string, ownerID uint64) app.BuildLog {
+	l, err := logSvc.Start(ctx, kind, ownerID)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.startLog.Start",
+			Params: errors.Params{"kind": kind, "owner": ownerID},
+		}))
+		return nopLog{}
+	}
+	return l
+}
+
+// resumeLog continues the latest build log, the output is discarded if the log can't be continued.
+func resumeLog(ctx context.Context, logSvc app.LogSvc, kind string, ownerID uint64) app.BuildLog {
+	l, err := logSvc.Resume(ctx, kind, ownerID)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.resumeLog.Resume",
+			Params: errors.Params{"kind": kind, "owner": ownerID},
+		}))
+		return nopLog{}
+	}
+	return l
+}
+
+// nopLog discards the build output.
+type nopLog struct{}
+
+// Write does nothing.
+func (nopLog) Write(context.Context, string) {}
+
+// Close does nothing.
+func (nopLog) Close(context.Context) {}
+
+// buildLog saves the output of the build line by line.
+type buildLog struct {
+	repo    app.LogRepo
+	kind    string
+	ownerID uint64
+	build   int
+	partial string
+}
+
+// Write saves the complete lines of the chunk, the incomplete last line is saved along with the next chunk.
+func (l *buildLog) Write(ctx context.Context, text string) {
+	text = l.partial + text
+	i := strings.LastIndexByte(text, '\n')
+	if i < 0 && len(text) < LogMaxLineLength {
+		l.partial = text
+		return
+	}
+	l.partial = ""
+	if i >= 0 {
+		l.partial = text[i+1:]
+		text = text[:i]
+	}
+	l.save(ctx, strings.Split(text, "\n"))
+}
+
+// Close saves the incomplete last line.
+func (l *buildLog) Close(ctx context.Context) {
+	if l.partial == "" {
+		return
+	}
+	l.save(ctx, []string{l.partial})
+	l.partial = ""
+}
+
+// save splits the long lines and saves them, the errors are logged only, so they don't break the build.
+func (l *buildLog) save(ctx context.Context, lines []string) {
+	res := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimSuffix(line, "\r")
+		for len(line) > LogMaxLineLength {
+			res = append(res, line[:LogMaxLineLength])
+			line = line[LogMaxLineLength:]
+		}
+		res = append(res, line)
+	}
+	err := l.repo.Add(ctx, l.kind, l.ownerID, l.build, res)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.buildLog.save.Add",
+			Params: errors.Params{"kind": l.kind, "owner": l.ownerID, "build": l.build},
+		}))
+	}
+}
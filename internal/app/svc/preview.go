@@ -0,0 +1,35 @@
+package svc
+
+import (
+	"github.com/beldeveloper/app-lego/internal/app"
+	"log"
+)
+
+// mergePreviews returns the merge preview for every head of the repository except the base branch itself.
+// There are no previews if the base branch doesn't exist, e.g. it is deleted or misspelled.
+func mergePreviews(r app.Repository, branches []app.VcsBranch) []app.VcsBranch {
+	var base *app.VcsBranch
+	for i, b := range branches {
+		if b.Type == app.BranchTypeHead && b.Name == r.BaseBranch {
+			base = &branches[i]
+			break
+		}
+	}
+	if base == nil {
+		log.Printf("The base branch %s of the repository #%d is not found, the merge previews are not synced\n", r.BaseBranch, r.ID)
+		return nil
+	}
+	previews := make([]app.VcsBranch, 0, len(branches))
+	for _, b := range branches {
+		if b.Type != app.BranchTypeHead || b.Name == base.Name {
+			continue
+		}
+		previews = append(previews, app.VcsBranch{
+			Type:     app.BranchTypePreview,
+			Name:     b.Name,
+			Hash:     b.Hash,
+			BaseHash: base.Hash,
+		})
+	}
+	return previews
+}
@@ -7,28 +7,47 @@ import (
 	"github.com/beldeveloper/app-lego/internal/app/errtype"
 	"github.com/beldeveloper/go-errors-context"
 	"log"
+	"reflect"
+	"regexp"
 	"strings"
 	"time"
 )
 
+const (
+	// DownloadMaxAttempts defines how many times the repository download is attempted before the repository fails.
+	DownloadMaxAttempts = 5
+	// DownloadRetryDelay defines the delay before the first retry, it doubles for every next attempt.
+	DownloadRetryDelay = 30 * time.Second
+	// SyncInterval defines how often every ready repository is synced.
+	SyncInterval = 30 * time.Second
+)
+
 // NewRepository creates a new instance of the VCS repository service.
 func NewRepository(
 	vcsSvc app.VcsSvc,
 	branchSvc app.BranchSvc,
+	deploySvc app.DeploymentSvc,
 	repo app.RepositoryRepo,
+	handlerRepo app.HookHandlerRepo,
 ) app.RepositorySvc {
 	return Repository{
-		vcsSvc:    vcsSvc,
-		branchSvc: branchSvc,
-		repo:      repo,
+		vcsSvc:      vcsSvc,
+		branchSvc:   branchSvc,
+		deploySvc:   deploySvc,
+		repo:        repo,
+		handlerRepo: handlerRepo,
+		aliasRx:     regexp.MustCompile("^[a-zA-Z0-9_-][a-zA-Z0-9._-]*$"),
 	}
 }
 
 // Repository is a service that manages the VCS repositories.
 type Repository struct {
-	vcsSvc    app.VcsSvc
-	branchSvc app.BranchSvc
-	repo      app.RepositoryRepo
+	vcsSvc      app.VcsSvc
+	branchSvc   app.BranchSvc
+	deploySvc   app.DeploymentSvc
+	repo        app.RepositoryRepo
+	handlerRepo app.HookHandlerRepo
+	aliasRx     *regexp.Regexp
 }
 
 // List all repositories.
@@ -39,16 +58,22 @@ func (s Repository) List(ctx context.Context) ([]app.Repository, error) {
 
 // Add new repository.
 func (s Repository) Add(ctx context.Context, f app.FormAddRepository) (app.Repository, error) {
-	f, err := s.validateAddForm(f)
+	f, err := s.validateAddForm(ctx, f)
 	if err != nil {
 		return app.Repository{}, errors.WrapContext(err, errors.Context{Path: "svc.Repository.Add.validateAddForm"})
 	}
 	r, err := s.repo.Add(ctx, app.Repository{
-		Type:      f.Type,
-		Alias:     f.Alias,
-		Name:      f.Name,
-		Status:    app.RepositoryStatusPending,
-		UpdatedAt: time.Now().Add(-time.Hour), // this way it will have a high priority for branches sync
+		Type:          f.Type,
+		Alias:         f.Alias,
+		Name:          f.Name,
+		Filters:       f.Filters,
+		PullRequests:  f.PullRequests,
+		BaseBranch:    f.BaseBranch,
+		MergePreview:  f.MergePreview,
+		Credentials:   f.Credentials,
+		HookHandlerID: f.HookHandlerID,
+		Status:        app.RepositoryStatusPending,
+		UpdatedAt:     time.Now().Add(-time.Hour), // this way it will have a high priority for branches sync
 	})
 	if err != nil {
 		return r, errors.WrapContext(err, errors.Context{Path: "svc.Repository.Add.Add"})
@@ -57,32 +82,206 @@ func (s Repository) Add(ctx context.Context, f app.FormAddRepository) (app.Repos
 	return r, nil
 }
 
-// DownloadJob looks for recently added repositories and downloads them.
-func (s Repository) DownloadJob(ctx context.Context) error {
-	r, err := s.repo.FindPending(ctx)
+// Update modifies the repository settings.
+// The clone is moved if the alias is changed, unless any branch is building, and the ready branches are rebuilt then.
+// The clone is pointed to the new remote if the name is changed.
+// The failed repository is enqueued for downloading again if the remote or credentials are changed.
+// The sync of the ready repository is requested if the remote, credentials or the options that define its branches
+// (filters, pull requests, base branch and merge previews) are changed.
+func (s Repository) Update(ctx context.Context, f app.FormUpdateRepository) (app.Repository, error) {
+	old, err := s.repo.FindByID(ctx, f.ID)
 	if err != nil {
-		if !errors.Is(err, errtype.ErrNotFound) {
-			return errors.WrapContext(err, errors.Context{Path: "svc.Repository.DownloadJob.FindPending"})
+		return old, errors.WrapContext(err, errors.Context{Path: "svc.Repository.Update.FindByID"})
+	}
+	r, err := s.validateUpdateForm(ctx, old, f)
+	if err != nil {
+		return r, errors.WrapContext(err, errors.Context{Path: "svc.Repository.Update.validateUpdateForm"})
+	}
+	moved := r.Alias != old.Alias && old.Status == app.RepositoryStatusReady
+	if moved {
+		err = s.branchSvc.CheckIdle(ctx, old)
+		if err != nil {
+			return r, errors.WrapContext(err, errors.Context{
+				Path:   "svc.Repository.Update.CheckIdle",
+				Params: errors.Params{"repository": r.ID},
+			})
+		}
+		err = s.vcsSvc.MoveRepository(ctx, old, r)
+		if err != nil {
+			return r, errors.WrapContext(err, errors.Context{
+				Path:   "svc.Repository.Update.MoveRepository",
+				Params: errors.Params{"repository": r.ID},
+			})
 		}
-		return nil
 	}
-	r.Status = app.RepositoryStatusDownloading
-	r, err = s.repo.Update(ctx, r)
+	r, err = s.repo.UpdateSettings(ctx, r)
+	if err != nil {
+		if moved {
+			// the clone is moved back, so it matches the alias that is still saved
+			s.moveBack(old, r)
+		}
+		return r, errors.WrapContext(err, errors.Context{
+			Path:   "svc.Repository.Update.UpdateSettings",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	log.Printf("The repository #%d is updated\n", r.ID)
+	if moved {
+		// the working trees are dropped by the move, so the builds are redone in the new directory
+		err = s.branchSvc.Requeue(ctx, r)
+		if err != nil {
+			return r, errors.WrapContext(err, errors.Context{
+				Path:   "svc.Repository.Update.Requeue",
+				Params: errors.Params{"repository": r.ID},
+			})
+		}
+	}
+	remoteChanged := r.Name != old.Name || f.Credentials != nil
+	branchesChanged := r.PullRequests != old.PullRequests || r.BaseBranch != old.BaseBranch ||
+		r.MergePreview != old.MergePreview || !reflect.DeepEqual(r.Filters, old.Filters)
+	if !remoteChanged && !branchesChanged {
+		return r, nil
+	}
+	switch r.Status {
+	case app.RepositoryStatusReady:
+		if r.Name != old.Name {
+			err = s.vcsSvc.UpdateRemote(ctx, r)
+			if err != nil {
+				return r, errors.WrapContext(err, errors.Context{
+					Path:   "svc.Repository.Update.UpdateRemote",
+					Params: errors.Params{"repository": r.ID},
+				})
+			}
+		}
+		err = s.repo.RequestSync(ctx, r)
+	case app.RepositoryStatusFailed:
+		if !remoteChanged {
+			break
+		}
+		// the download is likely to succeed with the new remote or credentials
+		r.Status = app.RepositoryStatusPending
+		r.Attempts = 0
+		r.RetryAt = nil
+		r, err = s.repo.Update(ctx, r)
+	}
+	return r, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Repository.Update.postUpdate",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// Delete removes the repository along with its branches, clone and deployments, unless any branch is building.
+func (s Repository) Delete(ctx context.Context, id uint64) error {
+	r, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{Path: "svc.Repository.Delete.FindByID"})
+	}
+	if r.Status == app.RepositoryStatusDownloading {
+		return fmt.Errorf("%w: repository is downloading, try again later", errtype.ErrBadInput)
+	}
+	err = s.branchSvc.CheckIdle(ctx, r)
 	if err != nil {
 		return errors.WrapContext(err, errors.Context{
-			Path:   "svc.Repository.DownloadJob.preUpdate",
-			Params: errors.Params{"repository": r.ID, "status": r.Status},
+			Path:   "svc.Repository.Delete.CheckIdle",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	err = s.deploySvc.CloseWithRepository(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Repository.Delete.CloseWithRepository",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	err = s.branchSvc.Delete(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Repository.Delete.deleteBranches",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	err = s.vcsSvc.RemoveRepository(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Repository.Delete.RemoveRepository",
+			Params: errors.Params{"repository": r.ID},
 		})
 	}
+	err = s.repo.Delete(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Repository.Delete.Delete",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	log.Printf("The repository #%d is deleted\n", r.ID)
+	return nil
+}
+
+// Download enqueues the failed repository for downloading again.
+func (s Repository) Download(ctx context.Context, id uint64) error {
+	r, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{Path: "svc.Repository.Download.FindByID"})
+	}
+	if r.Status != app.RepositoryStatusFailed && r.Status != app.RepositoryStatusPending {
+		return fmt.Errorf("%w: repository is %s", errtype.ErrBadInput, r.Status)
+	}
+	r.Status = app.RepositoryStatusPending
+	r.Attempts = 0
+	r.RetryAt = nil
+	_, err = s.repo.Update(ctx, r)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{
+			Path:   "svc.Repository.Download.Update",
+			Params: errors.Params{"repository": r.ID},
+		})
+	}
+	log.Printf("The repository #%d is enqueued for downloading\n", r.ID)
+	return nil
+}
+
+// Recover enqueues the repositories that were left downloading after the crash.
+func (s Repository) Recover(ctx context.Context) error {
+	n, err := s.repo.ReleaseDownloading(ctx)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{Path: "svc.Repository.Recover.ReleaseDownloading"})
+	}
+	if n > 0 {
+		log.Printf("%d interrupted repository download(s) are enqueued again\n", n)
+	}
+	return nil
+}
+
+// DownloadJob claims recently added repository and downloads it.
+// The failed download is retried with the growing delay up to DownloadMaxAttempts times.
+func (s Repository) DownloadJob(ctx context.Context) error {
+	r, err := s.repo.ClaimPending(ctx)
+	if err != nil {
+		if !errors.Is(err, errtype.ErrNotFound) {
+			return errors.WrapContext(err, errors.Context{Path: "svc.Repository.DownloadJob.ClaimPending"})
+		}
+		return errtype.ErrIdle
+	}
+	defer keepLease(ctx, func(ctx context.Context) error {
+		return s.repo.ExtendLease(ctx, r)
+	})()
 	err = s.vcsSvc.DownloadRepository(ctx, r)
-	r.Status = app.RepositoryStatusReady
 	if err != nil {
-		r.Status = app.RepositoryStatusFailed
+		if ctx.Err() != nil {
+			s.abandon(r)
+		} else {
+			s.retryLater(ctx, r, err)
+		}
 		return errors.WrapContext(err, errors.Context{
 			Path:   "svc.Repository.DownloadJob.DownloadRepository",
 			Params: errors.Params{"repository": r.ID},
 		})
 	}
+	r.Status = app.RepositoryStatusReady
+	r.ErrorMsg = nil
+	r.Attempts = 0
+	r.RetryAt = nil
 	r, err = s.repo.Update(ctx, r)
 	if err != nil {
 		return errors.WrapContext(err, errors.Context{
@@ -94,44 +293,302 @@ func (s Repository) DownloadJob(ctx context.Context) error {
 	return nil
 }
 
-// SyncJob pulls the updates for the repositories.
+// SyncJob pulls the updates for the ready repository whose sync is requested or that is synced longer ago than others.
+// The failed sync is returned, so the job backs off, but the repository waits for its next turn anyway,
+// so it doesn't keep the others from syncing.
 func (s Repository) SyncJob(ctx context.Context) error {
-	r, err := s.repo.FindOutdated(ctx)
+	r, err := s.repo.ClaimOutdated(ctx, SyncInterval)
 	if err != nil {
 		if !errors.Is(err, errtype.ErrNotFound) {
-			return errors.WrapContext(err, errors.Context{Path: "svc.Repository.SyncJob.FindOutdated"})
+			return errors.WrapContext(err, errors.Context{Path: "svc.Repository.SyncJob.ClaimOutdated"})
 		}
-		return nil
+		return errtype.ErrIdle
 	}
-	err = s.branchSvc.Sync(ctx, r)
+	return errors.WrapContext(s.sync(ctx, r), errors.Context{Path: "svc.Repository.SyncJob.sync"})
+}
+
+// Push requests the sync of the repository that is mentioned in the VCS hosting webhook.
+// The webhook doesn't wait for the sync, so the VCS hosting doesn't time out the delivery.
+func (s Repository) Push(ctx context.Context, p app.WebhookPush) error {
+	repos, err := s.repo.FindAll(ctx)
 	if err != nil {
-		return errors.WrapContext(err, errors.Context{
-			Path:   "svc.Repository.SyncJob.Sync",
-			Params: errors.Params{"repository": r.ID},
+		return errors.WrapContext(err, errors.Context{Path: "svc.Repository.Push.FindAll"})
+	}
+	keys := make(map[string]bool, len(p.URLs))
+	for _, u := range p.URLs {
+		keys[remoteKey(u)] = true
+	}
+	found := false
+	for _, r := range repos {
+		if !keys[remoteKey(r.Name)] {
+			continue
+		}
+		found = true
+		if r.Status != app.RepositoryStatusReady {
+			continue
+		}
+		err = s.repo.RequestSync(ctx, r)
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Repository.Push.RequestSync",
+				Params: errors.Params{"provider": p.Provider, "ref": p.Ref},
+			})
+		}
+		log.Printf("The repository #%d sync is requested by the %s webhook for %s\n", r.ID, p.Provider, p.Ref)
+	}
+	if !found {
+		return errors.WrapContext(errtype.ErrNotFound, errors.Context{
+			Path:   "svc.Repository.Push",
+			Params: errors.Params{"provider": p.Provider, "urls": p.URLs},
 		})
 	}
+	return nil
+}
+
+func (s Repository) sync(ctx context.Context, r app.Repository) error {
+	syncErr := s.branchSvc.Sync(ctx, r)
 	r.UpdatedAt = time.Now()
-	r, err = s.repo.Update(ctx, r)
-	if err != nil {
-		return errors.WrapContext(err, errors.Context{
-			Path:   "svc.Repository.SyncJob.Update",
+	_, err := s.repo.Update(ctx, r)
+	if syncErr != nil {
+		return errors.WrapContext(syncErr, errors.Context{
+			Path:   "svc.Repository.sync.Sync",
 			Params: errors.Params{"repository": r.ID},
 		})
 	}
-	return nil
+	return errors.WrapContext(err, errors.Context{
+		Path:   "svc.Repository.sync.Update",
+		Params: errors.Params{"repository": r.ID},
+	})
+}
+
+// moveBack returns the clone to the old alias, it is done with the own timeout,
+// so the clone follows the saved alias even if the request is cancelled.
+func (s Repository) moveBack(old, r app.Repository) {
+	ctx, cancel := context.WithTimeout(context.Background(), AbandonTimeout)
+	defer cancel()
+	err := s.vcsSvc.MoveRepository(ctx, r, old)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.Repository.moveBack.MoveRepository",
+			Params: errors.Params{"repository": r.ID, "alias": old.Alias},
+		}))
+	}
+}
+
+// abandon returns the interrupted repository to the pending status, so it is downloaded on the next start.
+func (s Repository) abandon(r app.Repository) {
+	ctx, cancel := context.WithTimeout(context.Background(), AbandonTimeout)
+	defer cancel()
+	r.Status = app.RepositoryStatusPending
+	_, err := s.repo.Update(ctx, r)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.Repository.abandon.Update",
+			Params: errors.Params{"repository": r.ID},
+		}))
+		return
+	}
+	log.Printf("The repository #%d downloading is interrupted and enqueued again\n", r.ID)
 }
 
-func (s Repository) validateAddForm(f app.FormAddRepository) (app.FormAddRepository, error) {
-	if f.Type != app.RepositoryTypeGit {
-		return f, fmt.Errorf("%w: repository type is invalid; allowed values: %s", errtype.ErrBadInput, app.RepositoryTypeGit)
+// remoteKey reduces the remote URL to the host and path, so the HTTPS and SSH remotes of the same repository are equal.
+func remoteKey(remote string) string {
+	k := strings.ToLower(strings.TrimSpace(remote))
+	if i := strings.Index(k, "://"); i >= 0 {
+		k = k[i+3:]
+	} else if i := strings.Index(k, ":"); i >= 0 {
+		k = k[:i] + "/" + k[i+1:] // scp-like syntax: git@host:owner/repo
+	}
+	if i := strings.Index(k, "@"); i >= 0 && i < strings.Index(k+"/", "/") {
+		k = k[i+1:]
+	}
+	host, path := k, ""
+	if i := strings.Index(k, "/"); i >= 0 {
+		host, path = k[:i], k[i:]
+	}
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return host + strings.TrimSuffix(strings.TrimSuffix(path, "/"), ".git")
+}
+
+// retryLater saves the download error and schedules the next attempt.
+// The repository fails when it runs out of attempts.
+func (s Repository) retryLater(ctx context.Context, r app.Repository, downloadErr error) {
+	errorMsg := downloadErr.Error()
+	r.ErrorMsg = &errorMsg
+	r.Attempts++
+	r.Status = app.RepositoryStatusFailed
+	r.RetryAt = nil
+	if r.Attempts < DownloadMaxAttempts {
+		retryAt := time.Now().Add(backoff(DownloadRetryDelay, r.Attempts))
+		r.Status = app.RepositoryStatusPending
+		r.RetryAt = &retryAt
+	}
+	_, err := s.repo.Update(ctx, r)
+	if err != nil {
+		log.Println(errors.WrapContext(err, errors.Context{
+			Path:   "svc.Repository.retryLater.Update",
+			Params: errors.Params{"repository": r.ID, "status": r.Status},
+		}))
+		return
+	}
+	if r.RetryAt != nil {
+		log.Printf("The repository #%d download attempt %d failed, retry at %s\n", r.ID, r.Attempts, r.RetryAt.Format(time.RFC3339))
+		return
+	}
+	log.Printf("The repository #%d download failed after %d attempts\n", r.ID, r.Attempts)
+}
+
+func (s Repository) validateAddForm(ctx context.Context, f app.FormAddRepository) (app.FormAddRepository, error) {
+	types := s.vcsSvc.Types()
+	supported := false
+	for _, t := range types {
+		supported = supported || f.Type == t
+	}
+	if !supported {
+		return f, fmt.Errorf("%w: repository type is invalid; allowed values: %s", errtype.ErrBadInput, strings.Join(types, ", "))
 	}
 	f.Alias = strings.TrimSpace(f.Alias)
 	f.Name = strings.TrimSpace(f.Name)
-	if f.Alias == "" {
-		return f, fmt.Errorf("%w: repository alias must not be empty", errtype.ErrBadInput)
+	f.BaseBranch = strings.TrimSpace(f.BaseBranch)
+	var err error
+	f.Credentials, err = s.validateCredentials(f.Credentials)
+	if err != nil {
+		return f, err
+	}
+	f.HookHandlerID, err = s.validateHookHandler(ctx, f.HookHandlerID)
+	if err != nil {
+		return f, err
+	}
+	r := app.Repository{
+		Type:         f.Type,
+		Alias:        f.Alias,
+		Name:         f.Name,
+		Filters:      f.Filters,
+		PullRequests: f.PullRequests,
+		BaseBranch:   f.BaseBranch,
+		MergePreview: f.MergePreview,
+	}
+	err = s.validateAlias(ctx, r)
+	if err != nil {
+		return f, err
 	}
-	if f.Name == "" {
-		return f, fmt.Errorf("%w: repository name must not be empty", errtype.ErrBadInput)
+	return f, s.validate(r)
+}
+
+func (s Repository) validateUpdateForm(ctx context.Context, r app.Repository, f app.FormUpdateRepository) (app.Repository, error) {
+	if (f.Alias != nil || f.Name != nil) && r.Status == app.RepositoryStatusDownloading {
+		return r, fmt.Errorf("%w: repository is downloading, try again later", errtype.ErrBadInput)
+	}
+	if f.Alias != nil && strings.TrimSpace(*f.Alias) != r.Alias {
+		r.Alias = strings.TrimSpace(*f.Alias)
+		err := s.validateAlias(ctx, r)
+		if err != nil {
+			return r, err
+		}
+	}
+	if f.Name != nil {
+		r.Name = strings.TrimSpace(*f.Name)
+	}
+	if f.Filters != nil {
+		r.Filters = *f.Filters
+	}
+	if f.PullRequests != nil {
+		r.PullRequests = *f.PullRequests
+	}
+	if f.BaseBranch != nil {
+		r.BaseBranch = strings.TrimSpace(*f.BaseBranch)
+	}
+	if f.MergePreview != nil {
+		r.MergePreview = *f.MergePreview
+	}
+	if f.Credentials != nil {
+		c, err := s.validateCredentials(*f.Credentials)
+		if err != nil {
+			return r, err
+		}
+		r.Credentials = c
+		r.HasCredentials = !c.Empty()
+	}
+	if f.HookHandlerID != nil {
+		id, err := s.validateHookHandler(ctx, f.HookHandlerID)
+		if err != nil {
+			return r, err
+		}
+		r.HookHandlerID = id
+	}
+	return r, s.validate(r)
+}
+
+// validateHookHandler checks that the assigned hook handler exists, 0 is turned into nil that means the default one.
+func (s Repository) validateHookHandler(ctx context.Context, id *uint64) (*uint64, error) {
+	if id == nil || *id == 0 {
+		return nil, nil
+	}
+	_, err := s.handlerRepo.FindByID(ctx, *id)
+	if errors.Is(err, errtype.ErrNotFound) {
+		return nil, fmt.Errorf("%w: hook handler %d is not found", errtype.ErrBadInput, *id)
+	}
+	return id, errors.WrapContext(err, errors.Context{
+		Path:   "svc.Repository.validateHookHandler.FindByID",
+		Params: errors.Params{"handler": *id},
+	})
+}
+
+func (s Repository) validateCredentials(c app.Credentials) (app.Credentials, error) {
+	c.Username = strings.TrimSpace(c.Username)
+	c.Token = strings.TrimSpace(c.Token)
+	c.SSHKey = strings.TrimSpace(c.SSHKey)
+	c.KnownHosts = strings.TrimSpace(c.KnownHosts)
+	if c.SSHKey != "" {
+		c.SSHKey += "\n" // ssh rejects the key without the trailing line break
+	}
+	if c.KnownHosts != "" {
+		c.KnownHosts += "\n"
+	}
+	if c.Username != "" && c.Token == "" {
+		return c, fmt.Errorf("%w: repository credentials must contain a token along with the username", errtype.ErrBadInput)
+	}
+	return c, nil
+}
+
+// validateAlias checks that the alias is a unique directory name.
+func (s Repository) validateAlias(ctx context.Context, r app.Repository) error {
+	if r.Alias == "" {
+		return fmt.Errorf("%w: repository alias must not be empty", errtype.ErrBadInput)
+	}
+	if !s.aliasRx.MatchString(r.Alias) {
+		return fmt.Errorf("%w: repository alias may contain only letters, digits, '.', '_' and '-'", errtype.ErrBadInput)
+	}
+	repos, err := s.repo.FindAll(ctx)
+	if err != nil {
+		return errors.WrapContext(err, errors.Context{Path: "svc.Repository.validateAlias.FindAll"})
+	}
+	for _, other := range repos {
+		if other.Alias == r.Alias && other.ID != r.ID {
+			return fmt.Errorf("%w: repository alias %s is already used", errtype.ErrBadInput, r.Alias)
+		}
+	}
+	return nil
+}
+
+func (s Repository) validate(r app.Repository) error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: repository name must not be empty", errtype.ErrBadInput)
+	}
+	if r.PullRequests && r.Type != app.RepositoryTypeGit {
+		return fmt.Errorf("%w: pull requests are supported by the git repositories only", errtype.ErrBadInput)
+	}
+	if r.MergePreview && !s.vcsSvc.CanMerge(r) {
+		return fmt.Errorf("%w: merge previews are supported by the git repositories with the git binary backend only", errtype.ErrBadInput)
+	}
+	if r.MergePreview && r.BaseBranch == "" {
+		return fmt.Errorf("%w: merge previews require the base branch", errtype.ErrBadInput)
+	}
+	_, err := newBranchMatcher(r.Filters)
+	if err != nil {
+		return err
 	}
-	return f, nil
+	return s.vcsSvc.CheckName(r)
 }
@@ -0,0 +1,239 @@
+package svc
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
+	"github.com/beldeveloper/app-lego/pkg"
+	"github.com/beldeveloper/app-lego/pkg/os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// worktreesDir is a directory inside the repositories' directory that keeps the branches' working trees.
+const worktreesDir = pkg.HookWorktreesDir
+
+// NewVcs creates a new instance of the VCS service that dispatches the calls to the backends by the repository type.
+func NewVcs(backends ...app.VcsSvc) app.VcsSvc {
+	s := Vcs{backends: make(map[string]app.VcsSvc)}
+	for _, b := range backends {
+		for _, t := range b.Types() {
+			s.backends[t] = b
+		}
+	}
+	return s
+}
+
+// Vcs is a registry of the VCS backends keyed by the repository type.
+type Vcs struct {
+	backends map[string]app.VcsSvc
+}
+
+// Types returns the repository types that have a backend.
+func (s Vcs) Types() []string {
+	res := make([]string, 0, len(s.backends))
+	for t := range s.backends {
+		res = append(res, t)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// CanMerge reports whether the backend of the repository builds the merge previews and integration branches.
+func (s Vcs) CanMerge(r app.Repository) bool {
+	b, err := s.backend(r)
+	if err != nil {
+		return false
+	}
+	return b.CanMerge(r)
+}
+
+// CheckName checks that the repository name is acceptable for the backend of the repository.
+func (s Vcs) CheckName(r app.Repository) error {
+	b, err := s.backend(r)
+	if err != nil {
+		return err
+	}
+	return b.CheckName(r)
+}
+
+// DownloadRepository to the directory.
+func (s Vcs) DownloadRepository(ctx context.Context, r app.Repository) error {
+	b, err := s.backend(r)
+	if err != nil {
+		return err
+	}
+	return b.DownloadRepository(ctx, r)
+}
+
+// UpdateRemote points the repository to the new remote.
+func (s Vcs) UpdateRemote(ctx context.Context, r app.Repository) error {
+	b, err := s.backend(r)
+	if err != nil {
+		return err
+	}
+	return b.UpdateRemote(ctx, r)
+}
+
+// MoveRepository moves the repository to the directory of the new alias.
+func (s Vcs) MoveRepository(ctx context.Context, from, to app.Repository) error {
+	b, err := s.backend(from)
+	if err != nil {
+		return err
+	}
+	return b.MoveRepository(ctx, from, to)
+}
+
+// RemoveRepository removes the repository files.
+func (s Vcs) RemoveRepository(ctx context.Context, r app.Repository) error {
+	b, err := s.backend(r)
+	if err != nil {
+		return err
+	}
+	return b.RemoveRepository(ctx, r)
+}
+
+// Branches returns a list of the repository branches.
+func (s Vcs) Branches(ctx context.Context, r app.Repository) ([]app.VcsBranch, error) {
+	b, err := s.backend(r)
+	if err != nil {
+		return nil, err
+	}
+	return b.Branches(ctx, r)
+}
+
+// Fetch downloads the repository updates.
+func (s Vcs) Fetch(ctx context.Context, r app.Repository) error {
+	b, err := s.backend(r)
+	if err != nil {
+		return err
+	}
+	return b.Fetch(ctx, r)
+}
+
+// Commit returns the metadata of the downloaded commit.
+func (s Vcs) Commit(ctx context.Context, r app.Repository, hash string) (app.Commit, error) {
+	b, err := s.backend(r)
+	if err != nil {
+		return app.Commit{}, err
+	}
+	return b.Commit(ctx, r, hash)
+}
+
+// Log returns the commits that are reachable from the "to" revision, but not from the "from" one.
+func (s Vcs) Log(ctx context.Context, r app.Repository, from, to string, limit int) ([]app.Commit, error) {
+	b, err := s.backend(r)
+	if err != nil {
+		return nil, err
+	}
+	return b.Log(ctx, r, from, to, limit)
+}
+
+// SwitchBranch prepares the branch files for building.
+func (s Vcs) SwitchBranch(ctx context.Context, r app.Repository, br app.Branch) error {
+	b, err := s.backend(r)
+	if err != nil {
+		return err
+	}
+	return b.SwitchBranch(ctx, r, br)
+}
+
+// BranchDir returns the directory of the branch files, it is empty for the unsupported repository.
+func (s Vcs) BranchDir(r app.Repository, br app.Branch) string {
+	b, err := s.backend(r)
+	if err != nil {
+		return ""
+	}
+	return b.BranchDir(r, br)
+}
+
+// CleanBranches removes the files of the deleted branches.
+func (s Vcs) CleanBranches(ctx context.Context, r app.Repository, branches []app.Branch) error {
+	b, err := s.backend(r)
+	if err != nil {
+		return err
+	}
+	return b.CleanBranches(ctx, r, branches)
+}
+
+func (s Vcs) backend(r app.Repository) (app.VcsSvc, error) {
+	b, exists := s.backends[r.Type]
+	if !exists {
+		return nil, fmt.Errorf("%w: unsupported repository type: %s; repository=%d", errtype.ErrBadInput, r.Type, r.ID)
+	}
+	return b, nil
+}
+
+// worktreesPath returns the directory that keeps the working trees of all repository branches.
+func worktreesPath(reposDir string, r app.Repository) string {
+	return fmt.Sprintf("%s/%s/%s", reposDir, worktreesDir, r.Alias)
+}
+
+// branchPath returns the directory of the branch working tree.
+func branchPath(reposDir string, r app.Repository, b app.Branch) string {
+	return pkg.HookBranchDir(reposDir, r.Alias, b.ID)
+}
+
+// removeWorktrees removes the working trees of the specific branches.
+func removeWorktrees(reposDir string, r app.Repository, branches []app.Branch) error {
+	for _, b := range branches {
+		dir := branchPath(reposDir, r, b)
+		exists, err := os.Exists(dir)
+		if err != nil || !exists {
+			continue
+		}
+		err = os.RemoveDir(dir)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseCommits parses the commits separated by the record separator character, see parseCommit.
+func parseCommits(out, dateLayout string) ([]app.Commit, error) {
+	records := strings.Split(out, "\x1e")
+	res := make([]app.Commit, 0, len(records))
+	for _, rec := range records {
+		if strings.TrimSpace(rec) == "" {
+			continue
+		}
+		c, err := parseCommit(rec, dateLayout)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, nil
+}
+
+// parseCommit parses the "hash<US>author<US>date<US>message" output of the VCS log command,
+// where <US> is the unit separator character.
+func parseCommit(out, dateLayout string) (app.Commit, error) {
+	parts := strings.SplitN(out, "\x1f", 4)
+	if len(parts) != 4 {
+		return app.Commit{}, fmt.Errorf("unexpected commit format: %q", out)
+	}
+	committedAt, err := time.Parse(dateLayout, strings.TrimSpace(parts[2]))
+	if err != nil {
+		return app.Commit{}, fmt.Errorf("invalid commit date: %w", err)
+	}
+	message := strings.TrimSpace(parts[3])
+	return app.Commit{
+		Hash:        strings.TrimSpace(parts[0]),
+		Author:      parts[1],
+		CommittedAt: committedAt,
+		Subject:     commitSubject(message),
+		Message:     message,
+	}, nil
+}
+
+// commitSubject returns the first line of the commit message.
+func commitSubject(message string) string {
+	if i := strings.IndexByte(message, '\n'); i >= 0 {
+		return strings.TrimSpace(message[:i])
+	}
+	return message
+}
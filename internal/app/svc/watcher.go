@@ -2,39 +2,204 @@ package svc
 
 import (
 	"context"
+	"fmt"
 	"github.com/beldeveloper/app-lego/internal/app"
+	"github.com/beldeveloper/app-lego/internal/app/errtype"
 	"github.com/beldeveloper/go-errors-context"
 	"log"
+	"math/rand"
+	"runtime/debug"
+	"sync"
 	"time"
 )
 
-// WatchJobDelay defines the delay between jobs.
-const WatchJobDelay = time.Second
+const (
+	// WatchJobDelay defines the delay between the job runs if the job doesn't define its own interval.
+	WatchJobDelay = time.Second
+	// WatchMaxBackoff defines the maximal delay between the job runs after consecutive errors.
+	WatchMaxBackoff = 5 * time.Minute
+	// AbandonTimeout defines the timeout for saving the state of the interrupted job.
+	AbandonTimeout = 5 * time.Second
+	// ListenRetryDelay defines the delay before subscribing to the events again after the failure.
+	ListenRetryDelay = 5 * time.Second
+)
 
 // NewWatcher creates a new instance of the watcher service.
-func NewWatcher(jobs []app.WatcherJob) Watcher {
-	return Watcher{jobs: jobs}
+func NewWatcher(
+	jobs []app.WatcherJob,
+	locker app.JobLocker,
+	listener app.EventListener,
+	shutdownTimeout time.Duration,
+) Watcher {
+	return Watcher{jobs: jobs, locker: locker, listener: listener, shutdownTimeout: shutdownTimeout}
 }
 
-// Watcher is a service that runs the sequences of jobs in a loop.
+// Watcher is a service that runs every job in its own loop.
+// The job runs right away when one of its events occurs, otherwise it runs on its interval.
 type Watcher struct {
-	jobs []app.WatcherJob
+	jobs            []app.WatcherJob
+	locker          app.JobLocker
+	listener        app.EventListener
+	shutdownTimeout time.Duration
 }
 
-// Watch runs the watcher.
-func (s Watcher) Watch() {
-	ctx := context.Background()
-	var err error
-	for {
-		for _, j := range s.jobs {
-			time.Sleep(WatchJobDelay)
-			err = j.Do(ctx)
+// Watch runs the watcher until the context is canceled.
+// After that it waits for the running jobs up to the shutdown timeout and then interrupts them.
+func (s Watcher) Watch(ctx context.Context) {
+	// the jobs use their own context, so the running job is not interrupted right away on shutdown
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	wakeMap := make(map[string][]chan struct{})
+	for _, j := range s.jobs {
+		workers := j.Workers
+		if workers < 1 {
+			workers = 1
+		}
+		if j.Prepare != nil {
+			err := j.Prepare(runCtx)
 			if err != nil {
 				log.Println(errors.WrapContext(err, errors.Context{
-					Path:   "svc.Watcher.Watch",
+					Path:   "svc.Watcher.Watch.Prepare",
 					Params: errors.Params{"job": j.Name},
 				}))
 			}
 		}
+		wake := make(chan struct{}, workers)
+		for _, e := range j.Events {
+			wakeMap[e] = append(wakeMap[e], wake)
+		}
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(j app.WatcherJob) {
+				defer wg.Done()
+				s.run(ctx, runCtx, j, wake)
+			}(j)
+		}
+	}
+	if len(wakeMap) > 0 {
+		go s.listen(ctx, wakeMap)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+	log.Printf("Waiting up to %s for the running jobs...\n", s.shutdownTimeout)
+	select {
+	case <-done:
+	case <-time.After(s.shutdownTimeout):
+		log.Print("Interrupting the running jobs...\n")
+		cancel()
+		<-done
+	}
+}
+
+// listen wakes the jobs up when their events occur.
+func (s Watcher) listen(ctx context.Context, wakeMap map[string][]chan struct{}) {
+	events := make([]string, 0, len(wakeMap))
+	for e := range wakeMap {
+		events = append(events, e)
+	}
+	for {
+		err := s.listener.Listen(ctx, events, func(event string) {
+			for _, wake := range wakeMap[event] {
+				select {
+				case wake <- struct{}{}:
+				default:
+				}
+			}
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println(errors.WrapContext(err, errors.Context{Path: "svc.Watcher.listen.Listen"}))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ListenRetryDelay):
+		}
+	}
+}
+
+func (s Watcher) run(ctx context.Context, runCtx context.Context, j app.WatcherJob, wake <-chan struct{}) {
+	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	interval := j.Interval
+	if interval <= 0 {
+		interval = WatchJobDelay
+	}
+	var errCount int
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		err := s.do(runCtx, j)
+		delay := interval
+		switch {
+		case err == nil:
+			errCount = 0
+			if j.Eager {
+				delay = 0
+			}
+		case errors.Is(err, errtype.ErrIdle):
+			errCount = 0
+		default:
+			errCount++
+			delay = backoff(interval, errCount)
+			log.Println(errors.WrapContext(err, errors.Context{
+				Path:   "svc.Watcher.run",
+				Params: errors.Params{"job": j.Name, "errors": errCount, "delay": delay.String()},
+			}))
+		}
+		if delay > 0 && j.Jitter > 0 {
+			delay += time.Duration(rnd.Int63n(int64(j.Jitter)))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-time.After(delay):
+		}
+	}
+}
+
+// do runs the job and turns its panic into an error, so the job loop keeps working.
+// The singleton job is skipped if another application instance holds its lock.
+func (s Watcher) do(ctx context.Context, j app.WatcherJob) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	if j.Singleton {
+		unlock, ok, err := s.locker.TryLock(ctx, j.Name)
+		if err != nil {
+			return errors.WrapContext(err, errors.Context{
+				Path:   "svc.Watcher.do.TryLock",
+				Params: errors.Params{"job": j.Name},
+			})
+		}
+		if !ok {
+			return errtype.ErrIdle
+		}
+		defer unlock()
+	}
+	return j.Do(ctx)
+}
+
+// backoff doubles the interval for every consecutive error.
+func backoff(interval time.Duration, errCount int) time.Duration {
+	delay := interval
+	for i := 1; i < errCount && delay < WatchMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > WatchMaxBackoff {
+		delay = WatchMaxBackoff
 	}
+	return delay
 }
@@ -1,20 +1,54 @@
 package app
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // ReposDir is a data type for storing the repositories' directory, used for DI.
 type ReposDir string
 
 // VcsBranch is a model that represents a repository branch in VCS.
 type VcsBranch struct {
-	Type string
-	Name string
-	Hash string
+	Type     string
+	Name     string
+	Hash     string
+	BaseHash string
+}
+
+// Commit is a model that represents the metadata of the VCS commit.
+type Commit struct {
+	Hash        string    `json:"hash"`
+	Author      string    `json:"author"`
+	CommittedAt time.Time `json:"committedAt"`
+	Subject     string    `json:"subject"`
+	Message     string    `json:"message"`
+}
+
+// Changelog is a model that represents the commits of the branch between two revisions, the recent first.
+// The From revision is empty if the changelog contains the recent commits of the branch history.
+type Changelog struct {
+	BranchID  uint64   `json:"branchId"`
+	From      string   `json:"from"`
+	To        string   `json:"to"`
+	Commits   []Commit `json:"commits"`
+	Truncated bool     `json:"truncated"`
 }
 
 // VcsSvc describes the version control service.
 type VcsSvc interface {
+	Types() []string
+	CanMerge(r Repository) bool
+	CheckName(r Repository) error
 	DownloadRepository(ctx context.Context, r Repository) error
+	UpdateRemote(ctx context.Context, r Repository) error
+	MoveRepository(ctx context.Context, from, to Repository) error
+	RemoveRepository(ctx context.Context, r Repository) error
 	Branches(ctx context.Context, r Repository) ([]VcsBranch, error)
+	Fetch(ctx context.Context, r Repository) error
+	Commit(ctx context.Context, r Repository, hash string) (Commit, error)
+	Log(ctx context.Context, r Repository, from, to string, limit int) ([]Commit, error)
 	SwitchBranch(ctx context.Context, r Repository, b Branch) error
+	BranchDir(r Repository, b Branch) string
+	CleanBranches(ctx context.Context, r Repository, branches []Branch) error
 }
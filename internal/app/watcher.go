@@ -1,9 +1,53 @@
 package app
 
-import "context"
+import (
+	"context"
+	"time"
+)
+
+// LeaseTTL defines how long the claimed work belongs to the application instance unless the lease is extended.
+const LeaseTTL = time.Minute
+
+const (
+	// EventRepositoryPending defines the event that is emitted when the repository is awaiting downloading.
+	EventRepositoryPending = "app_lego_repository_pending"
+	// EventRepositorySync defines the event that is emitted when the repository branches should be synced right away.
+	EventRepositorySync = "app_lego_repository_sync"
+	// EventBranchEnqueued defines the event that is emitted when the branch is enqueued for building.
+	EventBranchEnqueued = "app_lego_branch_enqueued"
+	// EventDeploymentEnqueued defines the event that is emitted when the deployment is enqueued for building.
+	EventDeploymentEnqueued = "app_lego_deployment_enqueued"
+)
+
+// WorkerID is a data type for storing the identifier of the application instance, used for DI.
+type WorkerID string
 
 // WatcherJob is a job that is run frequently by the watcher service.
 type WatcherJob struct {
 	Name string
 	Do   func(ctx context.Context) error
+	// Prepare runs once before the job loop is started, e.g. it recovers the work interrupted by a crash.
+	Prepare func(ctx context.Context) error
+	// Workers defines how many instances of the job are run in parallel.
+	Workers int
+	// Interval defines the delay between the job runs.
+	Interval time.Duration
+	// Jitter defines the maximal random delay that is added to the interval.
+	Jitter time.Duration
+	// Eager makes the job run again right away if the previous run has done some work.
+	Eager bool
+	// Singleton makes the job run by only one application instance at a time.
+	Singleton bool
+	// Events lists the events that make the job run right away.
+	Events []string
+}
+
+// JobLocker describes the lock that is shared between the application instances.
+type JobLocker interface {
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+}
+
+// EventListener describes the subscription to the events that are shared between the application instances.
+type EventListener interface {
+	Listen(ctx context.Context, events []string, fn func(event string)) error
 }
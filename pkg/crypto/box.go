@@ -0,0 +1,66 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNoKey is returned when the box has no key to encrypt or decrypt the data with.
+var ErrNoKey = errors.New("secret key is not set")
+
+// NewBox creates a new instance of the box.
+// The key is an arbitrary passphrase, it is turned into the 256-bit AES key with SHA-256.
+// The box with an empty key refuses to encrypt or decrypt anything.
+func NewBox(key string) Box {
+	if key == "" {
+		return Box{}
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		panic(err) // the key length is always valid
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err) // the standard nonce size is always valid
+	}
+	return Box{aead: aead}
+}
+
+// Box encrypts and decrypts the secrets using AES-GCM.
+type Box struct {
+	aead cipher.AEAD
+}
+
+// Seal encrypts the data, the random nonce is prepended to the result.
+func (b Box) Seal(data []byte) ([]byte, error) {
+	if b.aead == nil {
+		return nil, ErrNoKey
+	}
+	nonce := make([]byte, b.aead.NonceSize(), b.aead.NonceSize()+len(data)+b.aead.Overhead())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, fmt.Errorf("seal -> cannot generate nonce: %w", err)
+	}
+	return b.aead.Seal(nonce, nonce, data, nil), nil
+}
+
+// Open decrypts the data that was encrypted by Seal.
+func (b Box) Open(data []byte) ([]byte, error) {
+	if b.aead == nil {
+		return nil, ErrNoKey
+	}
+	if len(data) < b.aead.NonceSize() {
+		return nil, fmt.Errorf("open -> data is too short")
+	}
+	res, err := b.aead.Open(nil, data[:b.aead.NonceSize()], data[b.aead.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("open -> cannot decrypt: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,179 @@
+package grpcauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"os"
+)
+
+const (
+	// MetadataKey is the metadata key that carries the shared secret.
+	MetadataKey = "authorization"
+	// bearerPrefix precedes the shared secret in the metadata value.
+	bearerPrefix = "Bearer "
+)
+
+// TLSConfig defines the files of the TLS connection.
+// The client trusts only the certificate authority from the CA file if it is set, otherwise the system ones.
+// The server requires the client certificate signed by the certificate authority from the CA file if it is set.
+type TLSConfig struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	ServerName string
+}
+
+// Empty checks if nothing is configured, so the connection is not encrypted.
+func (c TLSConfig) Empty() bool {
+	return c == TLSConfig{}
+}
+
+// ClientTLS creates the transport credentials of the client.
+// The certificate and the key are sent to the server if they are set.
+func ClientTLS(c TLSConfig) (credentials.TransportCredentials, error) {
+	cfg := &tls.Config{ServerName: c.ServerName, MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pool, err := certPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("clientTLS -> cannot load the key pair: %w; cert=%s", err, c.CertFile)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+// ServerTLS creates the transport credentials of the server.
+func ServerTLS(c TLSConfig) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("serverTLS -> cannot load the key pair: %w; cert=%s", err, c.CertFile)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if c.CAFile != "" {
+		pool, err := certPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(cfg), nil
+}
+
+func certPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("certPool -> cannot read: %w; file=%s", err, caFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("certPool -> no certificates found; file=%s", caFile)
+	}
+	return pool, nil
+}
+
+// NewSecret creates the per-RPC credentials that send the shared secret with every call.
+// If the secret requires the transport security, it is never sent over the unencrypted connection.
+func NewSecret(secret string, requireTLS bool) credentials.PerRPCCredentials {
+	return Secret{secret: secret, requireTLS: requireTLS}
+}
+
+// Secret is the per-RPC credentials of the shared secret.
+type Secret struct {
+	secret     string
+	requireTLS bool
+}
+
+// GetRequestMetadata returns the metadata that carries the secret.
+func (s Secret) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{MetadataKey: bearerPrefix + s.secret}, nil
+}
+
+// RequireTransportSecurity checks if the secret can be sent over the encrypted connection only.
+func (s Secret) RequireTransportSecurity() bool {
+	return s.requireTLS
+}
+
+// Verifier checks the identity of the caller on the server side.
+// The shared secret is checked if it is set.
+// The common name or one of the DNS names of the client certificate must be in the list if it is not empty,
+// the certificate itself is verified by the TLS credentials of the server.
+type Verifier struct {
+	Secret string
+	Names  []string
+}
+
+// Verify returns the Unauthenticated or PermissionDenied status error if the caller is not trusted.
+func (v Verifier) Verify(ctx context.Context) error {
+	if v.Secret != "" && !v.secretMatches(ctx) {
+		return status.Error(codes.Unauthenticated, "invalid secret")
+	}
+	if len(v.Names) == 0 {
+		return nil
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "client certificate is required")
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return status.Error(codes.Unauthenticated, "client certificate is required")
+	}
+	cert := info.State.VerifiedChains[0][0]
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, allowed := range v.Names {
+		for _, n := range names {
+			if n == allowed {
+				return nil
+			}
+		}
+	}
+	return status.Errorf(codes.PermissionDenied, "client %q is not allowed", cert.Subject.CommonName)
+}
+
+func (v Verifier) secretMatches(ctx context.Context) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	expected := []byte(bearerPrefix + v.Secret)
+	for _, val := range md.Get(MetadataKey) {
+		if subtle.ConstantTimeCompare([]byte(val), expected) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// UnaryServerInterceptor rejects the unary calls of the untrusted callers.
+func (v Verifier) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+		if err := v.Verify(ctx); err != nil {
+			return nil, err
+		}
+		return h(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects the streaming calls of the untrusted callers.
+func (v Verifier) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+		if err := v.Verify(ss.Context()); err != nil {
+			return err
+		}
+		return h(srv, ss)
+	}
+}
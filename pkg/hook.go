@@ -1,6 +1,25 @@
 package pkg
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// HookWorktreesDir is a directory inside the repositories' directory that keeps the branches' working trees.
+const HookWorktreesDir = ".worktrees"
+
+const (
+	// HookStatusAccepted defines the build status that means the hook handler goes on building in background
+	// and reports the progress and the result to the callback service.
+	HookStatusAccepted = "accepted"
+	// HookStatusReady defines the status of the built branch or the finished deployment.
+	HookStatusReady = "ready"
+	// HookStatusSkipped defines the build status that means the branch shouldn't be built.
+	HookStatusSkipped = "skipped"
+	// HookStatusFailed defines the status of the failed build or deployment, any unknown status means the same.
+	HookStatusFailed = "failed"
+)
 
 // HookRepo contains repository data for passing into hook handler.
 type HookRepo struct {
@@ -16,6 +35,17 @@ type HookBranch struct {
 	Type   string
 	Name   string
 	Hash   string
+	// BaseHash is a revision of the base branch that the merge preview is merged onto.
+	BaseHash string
+}
+
+// HookCommit contains the metadata of the branch head commit for passing into hook handler.
+type HookCommit struct {
+	Hash        string
+	Author      string
+	CommittedAt time.Time
+	Subject     string
+	Message     string
 }
 
 // HookDeployment contains deployment data for passing into hook handler.
@@ -29,18 +59,41 @@ type HookDeployment struct {
 type HookBuildBranchReq struct {
 	Repo   HookRepo
 	Branch HookBranch
+	Commit HookCommit
+	Dir    string
+	// Log receives the chunks of the build output if the hook handler streams it, it may be nil.
+	Log func(text string)
+	// CallbackAddr is the address of the callback service, the hook handler may accept the build if it is set.
+	CallbackAddr string
 }
 
 // HookBuildBranchResp contains response data from the hook handler.
 type HookBuildBranchResp struct {
 	Status   string
 	ErrorMsg *string
+	// JobID identifies the accepted build in the reports.
+	JobID string
+}
+
+// HookBuildReport contains the progress of the accepted build for passing into the callback service.
+// The last report carries the result.
+type HookBuildReport struct {
+	BranchID uint64
+	JobID    string
+	// Progress is the percent from 0 to 100.
+	Progress int
+	Stage    string
+	Log      string
+	Result   *HookBuildBranchResp
 }
 
 // HookDeployReq contains request data for calling deploy in the hook handler.
 type HookDeployReq struct {
 	Repos       []HookRepo
 	Deployments []HookDeployment
+	// Log receives the chunks of the deployment output if the hook handler streams it, it may be nil.
+	// The deployment ID is 0 if the output belongs to all deployments.
+	Log func(deploymentID uint64, text string)
 }
 
 // HookDeployResp contains response data from the hook handler.
@@ -58,5 +111,12 @@ type HookDeployStatus struct {
 type HookSvc interface {
 	BuildBranch(ctx context.Context, req HookBuildBranchReq) (HookBuildBranchResp, error)
 	Deploy(ctx context.Context, req HookDeployReq) (HookDeployResp, error)
-	CleanBranches(ctx context.Context, ids []uint64) error
+	CleanBranches(ctx context.Context, repo HookRepo, ids []uint64) error
+}
+
+// HookBranchDir returns the directory of the branch working tree inside the repositories' directory,
+// so the hook handler that shares the directory finds the branches of the deployments.
+// The branches of the local repositories have no working trees, BuildBranch passes their directory.
+func HookBranchDir(reposDir, alias string, branchID uint64) string {
+	return fmt.Sprintf("%s/%s/%s/%d", reposDir, HookWorktreesDir, alias, branchID)
 }
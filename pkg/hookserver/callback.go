@@ -0,0 +1,49 @@
+package hookserver
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/pkg"
+	"github.com/beldeveloper/app-lego/rpc/hook"
+	"google.golang.org/grpc"
+)
+
+// DialCallback creates the client of the app-lego callback service that receives the reports of the accepted builds.
+// The address is passed in pkg.HookBuildBranchReq.CallbackAddr, the options define the credentials,
+// e.g. grpc.WithInsecure() or grpc.WithTransportCredentials, and grpc.WithPerRPCCredentials for the shared secret.
+func DialCallback(addr string, opts ...grpc.DialOption) (*Callback, error) {
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("hookserver.DialCallback -> %w; addr=%s", err, addr)
+	}
+	return &Callback{conn: conn, client: hook.NewCallbackClient(conn)}, nil
+}
+
+// Callback reports the accepted builds to app-lego.
+type Callback struct {
+	conn   *grpc.ClientConn
+	client hook.CallbackClient
+}
+
+// Report sends the progress of the accepted build, the report with the result finishes the build.
+// The Aborted error means that app-lego hasn't saved the acceptance yet and the report should be sent again,
+// the NotFound error means that the build is superseded and it may be stopped.
+func (c *Callback) Report(ctx context.Context, r pkg.HookBuildReport) error {
+	req := &hook.BuildReport{
+		BranchId: r.BranchID,
+		JobId:    r.JobID,
+		Progress: int32(r.Progress),
+		Stage:    r.Stage,
+		Log:      r.Log,
+	}
+	if r.Result != nil {
+		req.Result = buildBranchResp(*r.Result)
+	}
+	_, err := c.client.ReportBuild(ctx, req)
+	return err
+}
+
+// Close closes the connection.
+func (c *Callback) Close() error {
+	return c.conn.Close()
+}
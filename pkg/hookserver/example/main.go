@@ -0,0 +1,130 @@
+// The example hook handler runs the shell commands from the environment variables:
+// HOOK_BUILD_CMD builds the branch in its directory, the branch isn't built if it is empty;
+// HOOK_DEPLOY_CMD performs every updated deployment, the deployment is described by APP_LEGO_DEPLOYMENT in JSON;
+// HOOK_CLEAN_CMD cleans the deleted branches, their IDs are listed in APP_LEGO_BRANCH_IDS.
+// The commands receive the request data in the APP_LEGO_* variables and their output is streamed to app-lego.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/beldeveloper/app-lego/pkg"
+	"github.com/beldeveloper/app-lego/pkg/grpcauth"
+	"github.com/beldeveloper/app-lego/pkg/hookserver"
+	appos "github.com/beldeveloper/app-lego/pkg/os"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+func main() {
+	addr := os.Getenv("HOOK_ADDR")
+	if addr == "" {
+		addr = ":9000"
+	}
+	srv, err := hookserver.New(shellHook{
+		build:  os.Getenv("HOOK_BUILD_CMD"),
+		deploy: os.Getenv("HOOK_DEPLOY_CMD"),
+		clean:  os.Getenv("HOOK_CLEAN_CMD"),
+	}, hookserver.Config{
+		Addr: addr,
+		TLS: grpcauth.TLSConfig{
+			CAFile:   os.Getenv("HOOK_TLS_CA"),
+			CertFile: os.Getenv("HOOK_TLS_CRT"),
+			KeyFile:  os.Getenv("HOOK_TLS_KEY"),
+		},
+		Verifier: grpcauth.Verifier{Secret: os.Getenv("HOOK_SECRET")},
+	})
+	if err != nil {
+		log.Fatalf("main: %v\n", err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = srv.Run(ctx)
+	if err != nil {
+		log.Fatalf("main: %v\n", err)
+	}
+}
+
+type shellHook struct {
+	build  string
+	deploy string
+	clean  string
+}
+
+// BuildBranch runs the build command in the branch directory.
+func (h shellHook) BuildBranch(ctx context.Context, req pkg.HookBuildBranchReq) (pkg.HookBuildBranchResp, error) {
+	if h.build == "" {
+		return pkg.HookBuildBranchResp{Status: pkg.HookStatusSkipped}, nil
+	}
+	err := run(ctx, h.build, req.Dir, hookserver.LogWriter(req.Log), append(repoEnv(req.Repo),
+		"APP_LEGO_BRANCH_ID="+fmt.Sprint(req.Branch.ID),
+		"APP_LEGO_BRANCH_TYPE="+req.Branch.Type,
+		"APP_LEGO_BRANCH_NAME="+req.Branch.Name,
+		"APP_LEGO_BRANCH_HASH="+req.Branch.Hash,
+		"APP_LEGO_BRANCH_BASE_HASH="+req.Branch.BaseHash,
+		"APP_LEGO_COMMIT_AUTHOR="+req.Commit.Author,
+		"APP_LEGO_COMMIT_SUBJECT="+req.Commit.Subject,
+	))
+	if err != nil {
+		msg := err.Error()
+		return pkg.HookBuildBranchResp{Status: pkg.HookStatusFailed, ErrorMsg: &msg}, nil
+	}
+	return pkg.HookBuildBranchResp{Status: pkg.HookStatusReady}, nil
+}
+
+// Deploy runs the deploy command for every updated deployment, the rest ones stay ready.
+func (h shellHook) Deploy(ctx context.Context, req pkg.HookDeployReq) (pkg.HookDeployResp, error) {
+	res := pkg.HookDeployResp{Statuses: make(map[uint64]pkg.HookDeployStatus, len(req.Deployments))}
+	for _, d := range req.Deployments {
+		if !d.Updated || h.deploy == "" {
+			res.Statuses[d.ID] = pkg.HookDeployStatus{Status: pkg.HookStatusReady}
+			continue
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			return res, err
+		}
+		id := d.ID
+		err = run(ctx, h.deploy, "", hookserver.LogWriter(func(text string) {
+			req.Log(id, text)
+		}), []string{"APP_LEGO_DEPLOYMENT_ID=" + fmt.Sprint(d.ID), "APP_LEGO_DEPLOYMENT=" + string(data)})
+		if err != nil {
+			msg := err.Error()
+			res.Statuses[d.ID] = pkg.HookDeployStatus{Status: pkg.HookStatusFailed, ErrorMsg: &msg}
+			continue
+		}
+		res.Statuses[d.ID] = pkg.HookDeployStatus{Status: pkg.HookStatusReady}
+	}
+	return res, nil
+}
+
+// CleanBranches runs the clean command for the deleted branches.
+func (h shellHook) CleanBranches(ctx context.Context, repo pkg.HookRepo, ids []uint64) error {
+	if h.clean == "" {
+		return nil
+	}
+	idList := make([]string, len(ids))
+	for i, id := range ids {
+		idList[i] = fmt.Sprint(id)
+	}
+	return run(ctx, h.clean, "", nil, append(repoEnv(repo), "APP_LEGO_BRANCH_IDS="+strings.Join(idList, " ")))
+}
+
+// run runs the script with the shell, the output may be nil.
+func run(ctx context.Context, script, dir string, output io.Writer, env []string) error {
+	_, err := appos.Exec(ctx, appos.Cmd{Name: "sh", Args: []string{"-c", script}, Dir: dir, Env: env, Log: true, Output: output})
+	return err
+}
+
+func repoEnv(r pkg.HookRepo) []string {
+	return []string{
+		"APP_LEGO_REPO_ID=" + fmt.Sprint(r.ID),
+		"APP_LEGO_REPO_TYPE=" + r.Type,
+		"APP_LEGO_REPO_ALIAS=" + r.Alias,
+	}
+}
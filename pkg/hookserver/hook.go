@@ -0,0 +1,200 @@
+package hookserver
+
+import (
+	"context"
+	"github.com/beldeveloper/app-lego/pkg"
+	"github.com/beldeveloper/app-lego/rpc/hook"
+	"io"
+	"sync"
+	"time"
+)
+
+// NewHookServer creates the gRPC hook service that converts the calls for the handler,
+// it is useful if the handler is registered on its own gRPC server.
+func NewHookServer(h pkg.HookSvc) hook.HookServer {
+	return hookServer{h: h}
+}
+
+type hookServer struct {
+	hook.UnimplementedHookServer
+	h pkg.HookSvc
+}
+
+// BuildBranch builds the branch, the build output is dropped.
+func (s hookServer) BuildBranch(ctx context.Context, req *hook.BuildBranchReq) (*hook.BuildBranchResp, error) {
+	res, err := s.h.BuildBranch(ctx, buildBranchReq(req, func(string) {}))
+	if err != nil {
+		return nil, err
+	}
+	return buildBranchResp(res), nil
+}
+
+// StreamBuildBranch builds the branch and streams the build output.
+func (s hookServer) StreamBuildBranch(req *hook.BuildBranchReq, stream hook.Hook_StreamBuildBranchServer) error {
+	var sender streamSender
+	res, err := s.h.BuildBranch(stream.Context(), buildBranchReq(req, func(text string) {
+		sender.send(func() error {
+			return stream.Send(&hook.BuildBranchEvent{Log: text})
+		})
+	}))
+	sender.close()
+	if err != nil {
+		return err
+	}
+	return stream.Send(&hook.BuildBranchEvent{Result: buildBranchResp(res)})
+}
+
+// Deploy performs the deployments, the deployment output is dropped.
+func (s hookServer) Deploy(ctx context.Context, req *hook.DeployReq) (*hook.DeployResp, error) {
+	res, err := s.h.Deploy(ctx, deployReq(req, func(uint64, string) {}))
+	if err != nil {
+		return nil, err
+	}
+	return deployResp(res), nil
+}
+
+// StreamDeploy performs the deployments and streams the deployment output.
+func (s hookServer) StreamDeploy(req *hook.DeployReq, stream hook.Hook_StreamDeployServer) error {
+	var sender streamSender
+	res, err := s.h.Deploy(stream.Context(), deployReq(req, func(deploymentID uint64, text string) {
+		sender.send(func() error {
+			return stream.Send(&hook.DeployEvent{DeploymentId: deploymentID, Log: text})
+		})
+	}))
+	sender.close()
+	if err != nil {
+		return err
+	}
+	return stream.Send(&hook.DeployEvent{Result: deployResp(res)})
+}
+
+// CleanBranches cleans the deleted branches of the repository.
+func (s hookServer) CleanBranches(ctx context.Context, req *hook.CleanBranchesReq) (*hook.EmptyMsg, error) {
+	err := s.h.CleanBranches(ctx, hookRepo(req.Repo), req.Ids)
+	if err != nil {
+		return nil, err
+	}
+	return &hook.EmptyMsg{}, nil
+}
+
+// LogWriter turns the log receiver of the request into the writer, e.g. for the command output.
+func LogWriter(log func(text string)) io.Writer {
+	return logWriter(log)
+}
+
+type logWriter func(string)
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w(string(p))
+	return len(p), nil
+}
+
+// streamSender serializes the log events, because the handler may write the output from several goroutines.
+// The output that is written after the handler returns is dropped.
+type streamSender struct {
+	mu     sync.Mutex
+	closed bool
+	failed bool
+}
+
+func (s *streamSender) send(f func() error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed || s.failed {
+		return
+	}
+	// the stream is broken if app-lego is gone, the handler finds it out by its context
+	s.failed = f() != nil
+}
+
+func (s *streamSender) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+}
+
+func buildBranchReq(req *hook.BuildBranchReq, log func(string)) pkg.HookBuildBranchReq {
+	res := pkg.HookBuildBranchReq{
+		Repo:         hookRepo(req.Repo),
+		Branch:       hookBranch(req.Branch),
+		Dir:          req.Dir,
+		Log:          log,
+		CallbackAddr: req.CallbackAddr,
+	}
+	if c := req.Commit; c != nil {
+		res.Commit = pkg.HookCommit{
+			Hash:    c.Hash,
+			Author:  c.Author,
+			Subject: c.Subject,
+			Message: c.Message,
+		}
+		if c.CommittedAt != 0 {
+			res.Commit.CommittedAt = time.Unix(c.CommittedAt, 0).UTC()
+		}
+	}
+	return res
+}
+
+func buildBranchResp(res pkg.HookBuildBranchResp) *hook.BuildBranchResp {
+	rpcRes := &hook.BuildBranchResp{Status: res.Status, JobId: res.JobID}
+	if res.ErrorMsg != nil {
+		rpcRes.ErrorMsg = *res.ErrorMsg
+	}
+	return rpcRes
+}
+
+func deployReq(req *hook.DeployReq, log func(uint64, string)) pkg.HookDeployReq {
+	res := pkg.HookDeployReq{
+		Repos:       make([]pkg.HookRepo, len(req.Repos)),
+		Deployments: make([]pkg.HookDeployment, len(req.Deployments)),
+		Log:         log,
+	}
+	for i, r := range req.Repos {
+		res.Repos[i] = hookRepo(r)
+	}
+	for i, d := range req.Deployments {
+		dep := pkg.HookDeployment{
+			ID:       d.Id,
+			Updated:  d.Updated,
+			Branches: make(map[string]pkg.HookBranch, len(d.Branches)),
+		}
+		for k, b := range d.Branches {
+			dep.Branches[k] = hookBranch(b)
+		}
+		res.Deployments[i] = dep
+	}
+	return res
+}
+
+func deployResp(res pkg.HookDeployResp) *hook.DeployResp {
+	rpcRes := &hook.DeployResp{Statuses: make(map[uint64]*hook.DeployStatus, len(res.Statuses))}
+	for id, s := range res.Statuses {
+		rpcStatus := &hook.DeployStatus{Status: s.Status}
+		if s.ErrorMsg != nil {
+			rpcStatus.ErrorMsg = *s.ErrorMsg
+		}
+		rpcRes.Statuses[id] = rpcStatus
+	}
+	return rpcRes
+}
+
+func hookRepo(r *hook.Repo) pkg.HookRepo {
+	if r == nil {
+		return pkg.HookRepo{}
+	}
+	return pkg.HookRepo{ID: r.Id, Type: r.Type, Alias: r.Alias}
+}
+
+func hookBranch(b *hook.Branch) pkg.HookBranch {
+	if b == nil {
+		return pkg.HookBranch{}
+	}
+	return pkg.HookBranch{
+		ID:       b.Id,
+		RepoID:   b.RepoId,
+		Type:     b.Type,
+		Name:     b.Name,
+		Hash:     b.Hash,
+		BaseHash: b.BaseHash,
+	}
+}
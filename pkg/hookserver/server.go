@@ -0,0 +1,151 @@
+package hookserver
+
+import (
+	"context"
+	"fmt"
+	"github.com/beldeveloper/app-lego/pkg"
+	"github.com/beldeveloper/app-lego/pkg/grpcauth"
+	"github.com/beldeveloper/app-lego/rpc/hook"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"log"
+	"net"
+	"runtime/debug"
+	"time"
+)
+
+// DefaultShutdownTimeout defines the time that the running calls are waited for on shutdown by default.
+const DefaultShutdownTimeout = 30 * time.Second
+
+// Config defines the settings of the hook handler server.
+type Config struct {
+	// Addr is the listening address, e.g. ":9000".
+	Addr string
+	// TLS defines the server certificate, the connections are not encrypted if it is empty.
+	// The client certificate of app-lego is required if the CA file is set.
+	TLS grpcauth.TLSConfig
+	// Verifier checks the shared secret and the client certificate names of app-lego.
+	Verifier grpcauth.Verifier
+	// ShutdownTimeout limits the time that the running calls are waited for on shutdown,
+	// DefaultShutdownTimeout is used if it is zero.
+	ShutdownTimeout time.Duration
+}
+
+// New creates a new instance of the server that serves the handler.
+// The handler receives the log receivers that are never nil, the output is dropped if app-lego doesn't stream it.
+func New(h pkg.HookSvc, cfg Config) (*Server, error) {
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(logUnary, cfg.Verifier.UnaryServerInterceptor(), recoverUnary),
+		grpc.ChainStreamInterceptor(logStream, cfg.Verifier.StreamServerInterceptor(), recoverStream),
+	}
+	if !cfg.TLS.Empty() {
+		creds, err := grpcauth.ServerTLS(cfg.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("hookserver.New -> %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	srv := grpc.NewServer(opts...)
+	hook.RegisterHookServer(srv, NewHookServer(h))
+	return &Server{cfg: cfg, srv: srv}, nil
+}
+
+// Server serves the hook handler over gRPC.
+type Server struct {
+	cfg Config
+	srv *grpc.Server
+}
+
+// Run listens the address and serves the calls until the context is canceled.
+// After that it waits for the running calls up to the shutdown timeout and then interrupts them.
+func (s *Server) Run(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("hookserver.Run -> cannot listen: %w; addr=%s", err, s.cfg.Addr)
+	}
+	return s.Serve(ctx, lis)
+}
+
+// Serve serves the calls from the listener until the context is canceled.
+func (s *Server) Serve(ctx context.Context, lis net.Listener) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- s.srv.Serve(lis)
+	}()
+	log.Printf("Listening %s for hook calls...\n", lis.Addr())
+	select {
+	case err := <-served:
+		if err != nil {
+			return fmt.Errorf("hookserver.Serve -> %w; addr=%s", err, lis.Addr())
+		}
+		return nil
+	case <-ctx.Done():
+	}
+	log.Printf("Waiting up to %s for the running hook calls...\n", s.cfg.ShutdownTimeout)
+	stopped := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(s.cfg.ShutdownTimeout):
+		log.Print("Interrupting the running hook calls...\n")
+		s.srv.Stop()
+		<-stopped
+	}
+	return nil
+}
+
+// logUnary logs every finished unary call.
+func logUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	res, err := h(ctx, req)
+	logCall(info.FullMethod, start, err)
+	return res, err
+}
+
+// logStream logs every finished streaming call.
+func logStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+	start := time.Now()
+	err := h(srv, ss)
+	logCall(info.FullMethod, start, err)
+	return err
+}
+
+func logCall(method string, start time.Time, err error) {
+	if err != nil {
+		log.Printf("The hook call %s failed in %s: %v\n", method, time.Since(start), err)
+		return
+	}
+	log.Printf("The hook call %s is finished in %s\n", method, time.Since(start))
+}
+
+// recoverUnary turns the panic of the handler into the Internal error, so the server keeps working.
+func recoverUnary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, h grpc.UnaryHandler) (res interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(r)
+		}
+	}()
+	return h(ctx, req)
+}
+
+// recoverStream turns the panic of the handler into the Internal error, so the server keeps working.
+func recoverStream(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, h grpc.StreamHandler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicError(r)
+		}
+	}()
+	return h(srv, ss)
+}
+
+func panicError(r interface{}) error {
+	log.Printf("hookserver: panic: %v\n%s", r, debug.Stack())
+	return status.Error(codes.Internal, "internal error")
+}
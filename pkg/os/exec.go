@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
@@ -17,6 +18,10 @@ type Cmd struct {
 	Env  []string
 	Dir  string
 	Log  bool
+	// Output receives the stdout and stderr of the command as they are written, it may be nil.
+	Output io.Writer
+	// Isolated runs the command with the Env only, the environment of the current process isn't inherited.
+	Isolated bool
 }
 
 // Exec a system command and get the system output.
@@ -24,6 +29,10 @@ func Exec(ctx context.Context, cmd Cmd) (string, error) {
 	osCmd := exec.CommandContext(ctx, cmd.Name, cmd.Args...)
 	osCmd.Dir = cmd.Dir
 	osCmd.Env = append(os.Environ(), cmd.Env...)
+	if cmd.Isolated {
+		// the non-nil empty list, the nil one makes the command inherit the environment
+		osCmd.Env = append([]string{}, cmd.Env...)
+	}
 	if cmd.Log {
 		log.Printf(
 			"Exec cmd: [%s] %s %s\n",
@@ -36,6 +45,10 @@ func Exec(ctx context.Context, cmd Cmd) (string, error) {
 	var stderr bytes.Buffer
 	osCmd.Stdout = &out
 	osCmd.Stderr = &stderr
+	if cmd.Output != nil {
+		osCmd.Stdout = io.MultiWriter(&out, cmd.Output)
+		osCmd.Stderr = io.MultiWriter(&stderr, cmd.Output)
+	}
 	err := osCmd.Run()
 	if err != nil {
 		return "", fmt.Errorf("%w; output: %s", err, strings.TrimSpace(stderr.String()))
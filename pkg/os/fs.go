@@ -34,6 +34,57 @@ func RemoveDir(path string) error {
 	return nil
 }
 
+// MoveDir moves the directory to the new path.
+func MoveDir(from, to string) error {
+	from, err := filterPath(from)
+	if err != nil {
+		return err
+	}
+	to, err = filterPath(to)
+	if err != nil {
+		return err
+	}
+	log.Printf("Move directory %s to %s\n", from, to)
+	err = os.Rename(from, to)
+	if err != nil {
+		return fmt.Errorf("moveDir -> cannot move: %w; dir=%s; to=%s", err, from, to)
+	}
+	return nil
+}
+
+// TempFile creates a new temporary file that is readable by the owner only.
+func TempFile(pattern, content string) (string, error) {
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("tempFile -> cannot create: %w", err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(content)
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("tempFile -> cannot write: %w; file=%s", err, f.Name())
+	}
+	return f.Name(), nil
+}
+
+// WriteFile replaces the content of the file.
+func WriteFile(path, content string) error {
+	err := os.WriteFile(path, []byte(content), 0644)
+	if err != nil {
+		return fmt.Errorf("writeFile -> cannot write: %w; file=%s", err, path)
+	}
+	return nil
+}
+
+// RemoveFile removes the file.
+func RemoveFile(path string) error {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removeFile -> cannot remove: %w; file=%s", err, path)
+	}
+	return nil
+}
+
 // RecreateDir removes the directory and creates it again.
 func RecreateDir(path string) error {
 	path, err := filterPath(path)
@@ -90,11 +90,12 @@ type Branch struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Id     uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
-	RepoId uint64 `protobuf:"varint,2,opt,name=repoId,proto3" json:"repoId,omitempty"`
-	Type   string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
-	Name   string `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
-	Hash   string `protobuf:"bytes,5,opt,name=hash,proto3" json:"hash,omitempty"`
+	Id       uint64 `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	RepoId   uint64 `protobuf:"varint,2,opt,name=repoId,proto3" json:"repoId,omitempty"`
+	Type     string `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Name     string `protobuf:"bytes,4,opt,name=name,proto3" json:"name,omitempty"`
+	Hash     string `protobuf:"bytes,5,opt,name=hash,proto3" json:"hash,omitempty"`
+	BaseHash string `protobuf:"bytes,6,opt,name=baseHash,proto3" json:"baseHash,omitempty"` // the base branch revision of the merge preview, empty for the rest types
 }
 
 func (x *Branch) Reset() {
@@ -164,6 +165,92 @@ func (x *Branch) GetHash() string {
 	return ""
 }
 
+func (x *Branch) GetBaseHash() string {
+	if x != nil {
+		return x.BaseHash
+	}
+	return ""
+}
+
+type Commit struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Hash        string `protobuf:"bytes,1,opt,name=hash,proto3" json:"hash,omitempty"`
+	Author      string `protobuf:"bytes,2,opt,name=author,proto3" json:"author,omitempty"`
+	CommittedAt int64  `protobuf:"varint,3,opt,name=committedAt,proto3" json:"committedAt,omitempty"` // unix time in seconds, 0 if unknown
+	Subject     string `protobuf:"bytes,4,opt,name=subject,proto3" json:"subject,omitempty"`
+	Message     string `protobuf:"bytes,5,opt,name=message,proto3" json:"message,omitempty"`
+}
+
+func (x *Commit) Reset() {
+	*x = Commit{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_hook_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Commit) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Commit) ProtoMessage() {}
+
+func (x *Commit) ProtoReflect() protoreflect.Message {
+	mi := &file_hook_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Commit.ProtoReflect.Descriptor instead.
+func (*Commit) Descriptor() ([]byte, []int) {
+	return file_hook_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Commit) GetHash() string {
+	if x != nil {
+		return x.Hash
+	}
+	return ""
+}
+
+func (x *Commit) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *Commit) GetCommittedAt() int64 {
+	if x != nil {
+		return x.CommittedAt
+	}
+	return 0
+}
+
+func (x *Commit) GetSubject() string {
+	if x != nil {
+		return x.Subject
+	}
+	return ""
+}
+
+func (x *Commit) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
 type Deployment struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -177,7 +264,7 @@ type Deployment struct {
 func (x *Deployment) Reset() {
 	*x = Deployment{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_hook_proto_msgTypes[2]
+		mi := &file_hook_proto_msgTypes[3]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -190,7 +277,7 @@ func (x *Deployment) String() string {
 func (*Deployment) ProtoMessage() {}
 
 func (x *Deployment) ProtoReflect() protoreflect.Message {
-	mi := &file_hook_proto_msgTypes[2]
+	mi := &file_hook_proto_msgTypes[3]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -203,7 +290,7 @@ func (x *Deployment) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Deployment.ProtoReflect.Descriptor instead.
 func (*Deployment) Descriptor() ([]byte, []int) {
-	return file_hook_proto_rawDescGZIP(), []int{2}
+	return file_hook_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *Deployment) GetId() uint64 {
@@ -234,12 +321,17 @@ type BuildBranchReq struct {
 
 	Repo   *Repo   `protobuf:"bytes,1,opt,name=repo,proto3" json:"repo,omitempty"`
 	Branch *Branch `protobuf:"bytes,2,opt,name=branch,proto3" json:"branch,omitempty"`
+	Dir    string  `protobuf:"bytes,3,opt,name=dir,proto3" json:"dir,omitempty"`
+	Commit *Commit `protobuf:"bytes,4,opt,name=commit,proto3" json:"commit,omitempty"`
+	// callbackAddr is set if app-lego hosts the Callback service at this address,
+	// the handler may accept the build then and report its progress and result there.
+	CallbackAddr string `protobuf:"bytes,5,opt,name=callbackAddr,proto3" json:"callbackAddr,omitempty"`
 }
 
 func (x *BuildBranchReq) Reset() {
 	*x = BuildBranchReq{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_hook_proto_msgTypes[3]
+		mi := &file_hook_proto_msgTypes[4]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -252,7 +344,7 @@ func (x *BuildBranchReq) String() string {
 func (*BuildBranchReq) ProtoMessage() {}
 
 func (x *BuildBranchReq) ProtoReflect() protoreflect.Message {
-	mi := &file_hook_proto_msgTypes[3]
+	mi := &file_hook_proto_msgTypes[4]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -265,7 +357,7 @@ func (x *BuildBranchReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BuildBranchReq.ProtoReflect.Descriptor instead.
 func (*BuildBranchReq) Descriptor() ([]byte, []int) {
-	return file_hook_proto_rawDescGZIP(), []int{3}
+	return file_hook_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *BuildBranchReq) GetRepo() *Repo {
@@ -282,19 +374,41 @@ func (x *BuildBranchReq) GetBranch() *Branch {
 	return nil
 }
 
+func (x *BuildBranchReq) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+func (x *BuildBranchReq) GetCommit() *Commit {
+	if x != nil {
+		return x.Commit
+	}
+	return nil
+}
+
+func (x *BuildBranchReq) GetCallbackAddr() string {
+	if x != nil {
+		return x.CallbackAddr
+	}
+	return ""
+}
+
 type BuildBranchResp struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Status   string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"`
+	Status   string `protobuf:"bytes,1,opt,name=status,proto3" json:"status,omitempty"` // "accepted" means the build goes on in background and is reported to the Callback service
 	ErrorMsg string `protobuf:"bytes,2,opt,name=errorMsg,proto3" json:"errorMsg,omitempty"`
+	JobId    string `protobuf:"bytes,3,opt,name=jobId,proto3" json:"jobId,omitempty"` // the identifier of the accepted build
 }
 
 func (x *BuildBranchResp) Reset() {
 	*x = BuildBranchResp{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_hook_proto_msgTypes[4]
+		mi := &file_hook_proto_msgTypes[5]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -307,7 +421,7 @@ func (x *BuildBranchResp) String() string {
 func (*BuildBranchResp) ProtoMessage() {}
 
 func (x *BuildBranchResp) ProtoReflect() protoreflect.Message {
-	mi := &file_hook_proto_msgTypes[4]
+	mi := &file_hook_proto_msgTypes[5]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -320,7 +434,7 @@ func (x *BuildBranchResp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use BuildBranchResp.ProtoReflect.Descriptor instead.
 func (*BuildBranchResp) Descriptor() ([]byte, []int) {
-	return file_hook_proto_rawDescGZIP(), []int{4}
+	return file_hook_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *BuildBranchResp) GetStatus() string {
@@ -337,6 +451,69 @@ func (x *BuildBranchResp) GetErrorMsg() string {
 	return ""
 }
 
+func (x *BuildBranchResp) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+// BuildBranchEvent is streamed during the branch build, the last event carries the result.
+type BuildBranchEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Log    string           `protobuf:"bytes,1,opt,name=log,proto3" json:"log,omitempty"` // a chunk of the build output, the lines are separated by \n
+	Result *BuildBranchResp `protobuf:"bytes,2,opt,name=result,proto3" json:"result,omitempty"`
+}
+
+func (x *BuildBranchEvent) Reset() {
+	*x = BuildBranchEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_hook_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BuildBranchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildBranchEvent) ProtoMessage() {}
+
+func (x *BuildBranchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_hook_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildBranchEvent.ProtoReflect.Descriptor instead.
+func (*BuildBranchEvent) Descriptor() ([]byte, []int) {
+	return file_hook_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *BuildBranchEvent) GetLog() string {
+	if x != nil {
+		return x.Log
+	}
+	return ""
+}
+
+func (x *BuildBranchEvent) GetResult() *BuildBranchResp {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
 type DeployReq struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -349,7 +526,7 @@ type DeployReq struct {
 func (x *DeployReq) Reset() {
 	*x = DeployReq{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_hook_proto_msgTypes[5]
+		mi := &file_hook_proto_msgTypes[7]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -362,7 +539,7 @@ func (x *DeployReq) String() string {
 func (*DeployReq) ProtoMessage() {}
 
 func (x *DeployReq) ProtoReflect() protoreflect.Message {
-	mi := &file_hook_proto_msgTypes[5]
+	mi := &file_hook_proto_msgTypes[7]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -375,7 +552,7 @@ func (x *DeployReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeployReq.ProtoReflect.Descriptor instead.
 func (*DeployReq) Descriptor() ([]byte, []int) {
-	return file_hook_proto_rawDescGZIP(), []int{5}
+	return file_hook_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *DeployReq) GetRepos() []*Repo {
@@ -403,7 +580,7 @@ type DeployResp struct {
 func (x *DeployResp) Reset() {
 	*x = DeployResp{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_hook_proto_msgTypes[6]
+		mi := &file_hook_proto_msgTypes[8]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -416,7 +593,7 @@ func (x *DeployResp) String() string {
 func (*DeployResp) ProtoMessage() {}
 
 func (x *DeployResp) ProtoReflect() protoreflect.Message {
-	mi := &file_hook_proto_msgTypes[6]
+	mi := &file_hook_proto_msgTypes[8]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -429,7 +606,7 @@ func (x *DeployResp) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeployResp.ProtoReflect.Descriptor instead.
 func (*DeployResp) Descriptor() ([]byte, []int) {
-	return file_hook_proto_rawDescGZIP(), []int{6}
+	return file_hook_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *DeployResp) GetStatuses() map[uint64]*DeployStatus {
@@ -451,7 +628,7 @@ type DeployStatus struct {
 func (x *DeployStatus) Reset() {
 	*x = DeployStatus{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_hook_proto_msgTypes[7]
+		mi := &file_hook_proto_msgTypes[9]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -464,7 +641,7 @@ func (x *DeployStatus) String() string {
 func (*DeployStatus) ProtoMessage() {}
 
 func (x *DeployStatus) ProtoReflect() protoreflect.Message {
-	mi := &file_hook_proto_msgTypes[7]
+	mi := &file_hook_proto_msgTypes[9]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -477,7 +654,7 @@ func (x *DeployStatus) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DeployStatus.ProtoReflect.Descriptor instead.
 func (*DeployStatus) Descriptor() ([]byte, []int) {
-	return file_hook_proto_rawDescGZIP(), []int{7}
+	return file_hook_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *DeployStatus) GetStatus() string {
@@ -494,18 +671,83 @@ func (x *DeployStatus) GetErrorMsg() string {
 	return ""
 }
 
+// DeployEvent is streamed during the deployment, the last event carries the result.
+type DeployEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	DeploymentId uint64      `protobuf:"varint,1,opt,name=deploymentId,proto3" json:"deploymentId,omitempty"` // the deployment that the log belongs to, 0 if it belongs to all of them
+	Log          string      `protobuf:"bytes,2,opt,name=log,proto3" json:"log,omitempty"`                    // a chunk of the deployment output, the lines are separated by \n
+	Result       *DeployResp `protobuf:"bytes,3,opt,name=result,proto3" json:"result,omitempty"`
+}
+
+func (x *DeployEvent) Reset() {
+	*x = DeployEvent{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_hook_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DeployEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeployEvent) ProtoMessage() {}
+
+func (x *DeployEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_hook_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeployEvent.ProtoReflect.Descriptor instead.
+func (*DeployEvent) Descriptor() ([]byte, []int) {
+	return file_hook_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *DeployEvent) GetDeploymentId() uint64 {
+	if x != nil {
+		return x.DeploymentId
+	}
+	return 0
+}
+
+func (x *DeployEvent) GetLog() string {
+	if x != nil {
+		return x.Log
+	}
+	return ""
+}
+
+func (x *DeployEvent) GetResult() *DeployResp {
+	if x != nil {
+		return x.Result
+	}
+	return nil
+}
+
 type CleanBranchesReq struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Ids []uint64 `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	Ids  []uint64 `protobuf:"varint,1,rep,packed,name=ids,proto3" json:"ids,omitempty"`
+	Repo *Repo    `protobuf:"bytes,2,opt,name=repo,proto3" json:"repo,omitempty"` // the repository that the branches belonged to
 }
 
 func (x *CleanBranchesReq) Reset() {
 	*x = CleanBranchesReq{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_hook_proto_msgTypes[8]
+		mi := &file_hook_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -518,7 +760,7 @@ func (x *CleanBranchesReq) String() string {
 func (*CleanBranchesReq) ProtoMessage() {}
 
 func (x *CleanBranchesReq) ProtoReflect() protoreflect.Message {
-	mi := &file_hook_proto_msgTypes[8]
+	mi := &file_hook_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -531,7 +773,7 @@ func (x *CleanBranchesReq) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use CleanBranchesReq.ProtoReflect.Descriptor instead.
 func (*CleanBranchesReq) Descriptor() ([]byte, []int) {
-	return file_hook_proto_rawDescGZIP(), []int{8}
+	return file_hook_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *CleanBranchesReq) GetIds() []uint64 {
@@ -541,6 +783,13 @@ func (x *CleanBranchesReq) GetIds() []uint64 {
 	return nil
 }
 
+func (x *CleanBranchesReq) GetRepo() *Repo {
+	if x != nil {
+		return x.Repo
+	}
+	return nil
+}
+
 type EmptyMsg struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -550,7 +799,7 @@ type EmptyMsg struct {
 func (x *EmptyMsg) Reset() {
 	*x = EmptyMsg{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_hook_proto_msgTypes[9]
+		mi := &file_hook_proto_msgTypes[12]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -563,7 +812,7 @@ func (x *EmptyMsg) String() string {
 func (*EmptyMsg) ProtoMessage() {}
 
 func (x *EmptyMsg) ProtoReflect() protoreflect.Message {
-	mi := &file_hook_proto_msgTypes[9]
+	mi := &file_hook_proto_msgTypes[12]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -576,7 +825,95 @@ func (x *EmptyMsg) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use EmptyMsg.ProtoReflect.Descriptor instead.
 func (*EmptyMsg) Descriptor() ([]byte, []int) {
-	return file_hook_proto_rawDescGZIP(), []int{9}
+	return file_hook_proto_rawDescGZIP(), []int{12}
+}
+
+// BuildReport is sent by the hook handler for the accepted build, the last report carries the result.
+type BuildReport struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	BranchId uint64           `protobuf:"varint,1,opt,name=branchId,proto3" json:"branchId,omitempty"`
+	JobId    string           `protobuf:"bytes,2,opt,name=jobId,proto3" json:"jobId,omitempty"`
+	Progress int32            `protobuf:"varint,3,opt,name=progress,proto3" json:"progress,omitempty"` // percent from 0 to 100
+	Stage    string           `protobuf:"bytes,4,opt,name=stage,proto3" json:"stage,omitempty"`
+	Log      string           `protobuf:"bytes,5,opt,name=log,proto3" json:"log,omitempty"` // a chunk of the build output, the lines are separated by \n
+	Result   *BuildBranchResp `protobuf:"bytes,6,opt,name=result,proto3" json:"result,omitempty"`
+}
+
+func (x *BuildReport) Reset() {
+	*x = BuildReport{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_hook_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BuildReport) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BuildReport) ProtoMessage() {}
+
+func (x *BuildReport) ProtoReflect() protoreflect.Message {
+	mi := &file_hook_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BuildReport.ProtoReflect.Descriptor instead.
+func (*BuildReport) Descriptor() ([]byte, []int) {
+	return file_hook_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *BuildReport) GetBranchId() uint64 {
+	if x != nil {
+		return x.BranchId
+	}
+	return 0
+}
+
+func (x *BuildReport) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *BuildReport) GetProgress() int32 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *BuildReport) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *BuildReport) GetLog() string {
+	if x != nil {
+		return x.Log
+	}
+	return ""
+}
+
+func (x *BuildReport) GetResult() *BuildBranchResp {
+	if x != nil {
+		return x.Result
+	}
+	return nil
 }
 
 var File_hook_proto protoreflect.FileDescriptor
@@ -587,71 +924,126 @@ var file_hook_proto_rawDesc = []byte{
 	0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79,
 	0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x14,
 	0x0a, 0x05, 0x61, 0x6c, 0x69, 0x61, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x61,
-	0x6c, 0x69, 0x61, 0x73, 0x22, 0x6c, 0x0a, 0x06, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x12, 0x0e,
-	0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16,
-	0x0a, 0x06, 0x72, 0x65, 0x70, 0x6f, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x06,
-	0x72, 0x65, 0x70, 0x6f, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61,
-	0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x12,
-	0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x61,
-	0x73, 0x68, 0x22, 0xbd, 0x01, 0x0a, 0x0a, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e,
-	0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69,
-	0x64, 0x12, 0x3a, 0x0a, 0x08, 0x62, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x18, 0x02, 0x20,
-	0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f,
-	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x2e, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x45, 0x6e,
-	0x74, 0x72, 0x79, 0x52, 0x08, 0x62, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x12, 0x18, 0x0a,
-	0x07, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07,
-	0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x1a, 0x49, 0x0a, 0x0d, 0x42, 0x72, 0x61, 0x6e, 0x63,
-	0x68, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x22, 0x0a, 0x05, 0x76, 0x61,
-	0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x68, 0x6f, 0x6f, 0x6b,
-	0x2e, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
-	0x38, 0x01, 0x22, 0x56, 0x0a, 0x0e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63,
-	0x68, 0x52, 0x65, 0x71, 0x12, 0x1e, 0x0a, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x18, 0x01, 0x20, 0x01,
-	0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x52, 0x65, 0x70, 0x6f, 0x52, 0x04,
-	0x72, 0x65, 0x70, 0x6f, 0x12, 0x24, 0x0a, 0x06, 0x62, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x18, 0x02,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42, 0x72, 0x61, 0x6e,
-	0x63, 0x68, 0x52, 0x06, 0x62, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x22, 0x45, 0x0a, 0x0f, 0x42, 0x75,
-	0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x12, 0x16, 0x0a,
-	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73,
-	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x4d, 0x73,
-	0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x4d, 0x73,
-	0x67, 0x22, 0x61, 0x0a, 0x09, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x52, 0x65, 0x71, 0x12, 0x20,
-	0x0a, 0x05, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x0a, 0x2e,
-	0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x52, 0x65, 0x70, 0x6f, 0x52, 0x05, 0x72, 0x65, 0x70, 0x6f, 0x73,
-	0x12, 0x32, 0x0a, 0x0b, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18,
-	0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70,
-	0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x0b, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d,
-	0x65, 0x6e, 0x74, 0x73, 0x22, 0x99, 0x01, 0x0a, 0x0a, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x52,
-	0x65, 0x73, 0x70, 0x12, 0x3a, 0x0a, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65, 0x73, 0x18,
-	0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70,
-	0x6c, 0x6f, 0x79, 0x52, 0x65, 0x73, 0x70, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65, 0x73,
-	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65, 0x73, 0x1a,
-	0x4f, 0x0a, 0x0d, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79,
-	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x03, 0x6b,
-	0x65, 0x79, 0x12, 0x28, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x0b, 0x32, 0x12, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x53,
-	0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01,
-	0x22, 0x42, 0x0a, 0x0c, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
-	0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x72, 0x72, 0x6f,
-	0x72, 0x4d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x72, 0x72, 0x6f,
-	0x72, 0x4d, 0x73, 0x67, 0x22, 0x24, 0x0a, 0x10, 0x43, 0x6c, 0x65, 0x61, 0x6e, 0x42, 0x72, 0x61,
-	0x6e, 0x63, 0x68, 0x65, 0x73, 0x52, 0x65, 0x71, 0x12, 0x10, 0x0a, 0x03, 0x69, 0x64, 0x73, 0x18,
-	0x01, 0x20, 0x03, 0x28, 0x04, 0x52, 0x03, 0x69, 0x64, 0x73, 0x22, 0x0a, 0x0a, 0x08, 0x45, 0x6d,
-	0x70, 0x74, 0x79, 0x4d, 0x73, 0x67, 0x32, 0xae, 0x01, 0x0a, 0x04, 0x48, 0x6f, 0x6f, 0x6b, 0x12,
-	0x3c, 0x0a, 0x0b, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x12, 0x14,
-	0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63,
-	0x68, 0x52, 0x65, 0x71, 0x1a, 0x15, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42, 0x75, 0x69, 0x6c,
-	0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x22, 0x00, 0x12, 0x2d, 0x0a,
-	0x06, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x12, 0x0f, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44,
-	0x65, 0x70, 0x6c, 0x6f, 0x79, 0x52, 0x65, 0x71, 0x1a, 0x10, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e,
-	0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x52, 0x65, 0x73, 0x70, 0x22, 0x00, 0x12, 0x39, 0x0a, 0x0d,
-	0x43, 0x6c, 0x65, 0x61, 0x6e, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x12, 0x16, 0x2e,
-	0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x43, 0x6c, 0x65, 0x61, 0x6e, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68,
-	0x65, 0x73, 0x52, 0x65, 0x71, 0x1a, 0x0e, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x45, 0x6d, 0x70,
-	0x74, 0x79, 0x4d, 0x73, 0x67, 0x22, 0x00, 0x42, 0x08, 0x5a, 0x06, 0x2e, 0x3b, 0x68, 0x6f, 0x6f,
-	0x6b, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6c, 0x69, 0x61, 0x73, 0x22, 0x88, 0x01, 0x0a, 0x06, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x12,
+	0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12,
+	0x16, 0x0a, 0x06, 0x72, 0x65, 0x70, 0x6f, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x06, 0x72, 0x65, 0x70, 0x6f, 0x49, 0x64, 0x12, 0x12, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18,
+	0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e,
+	0x61, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12,
+	0x12, 0x0a, 0x04, 0x68, 0x61, 0x73, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68,
+	0x61, 0x73, 0x68, 0x12, 0x1a, 0x0a, 0x08, 0x62, 0x61, 0x73, 0x65, 0x48, 0x61, 0x73, 0x68, 0x18,
+	0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x62, 0x61, 0x73, 0x65, 0x48, 0x61, 0x73, 0x68, 0x22,
+	0x8a, 0x01, 0x0a, 0x06, 0x43, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x68, 0x61,
+	0x73, 0x68, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x68, 0x61, 0x73, 0x68, 0x12, 0x16,
+	0x0a, 0x06, 0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06,
+	0x61, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x12, 0x20, 0x0a, 0x0b, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74,
+	0x74, 0x65, 0x64, 0x41, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x63, 0x6f, 0x6d,
+	0x6d, 0x69, 0x74, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x73, 0x75, 0x62, 0x6a,
+	0x65, 0x63, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x73, 0x75, 0x62, 0x6a, 0x65,
+	0x63, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x07, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0xbd, 0x01, 0x0a,
+	0x0a, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x12, 0x0e, 0x0a, 0x02, 0x69,
+	0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x02, 0x69, 0x64, 0x12, 0x3a, 0x0a, 0x08, 0x62,
+	0x72, 0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e,
+	0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x2e,
+	0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x62,
+	0x72, 0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x12, 0x18, 0x0a, 0x07, 0x75, 0x70, 0x64, 0x61, 0x74,
+	0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x07, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65,
+	0x64, 0x1a, 0x49, 0x0a, 0x0d, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x22, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42, 0x72, 0x61, 0x6e, 0x63,
+	0x68, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xb2, 0x01, 0x0a,
+	0x0e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x52, 0x65, 0x71, 0x12,
+	0x1e, 0x0a, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e,
+	0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x52, 0x65, 0x70, 0x6f, 0x52, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x12,
+	0x24, 0x0a, 0x06, 0x62, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x0c, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x52, 0x06, 0x62,
+	0x72, 0x61, 0x6e, 0x63, 0x68, 0x12, 0x10, 0x0a, 0x03, 0x64, 0x69, 0x72, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x64, 0x69, 0x72, 0x12, 0x24, 0x0a, 0x06, 0x63, 0x6f, 0x6d, 0x6d, 0x69,
+	0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0c, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x43,
+	0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x52, 0x06, 0x63, 0x6f, 0x6d, 0x6d, 0x69, 0x74, 0x12, 0x22, 0x0a,
+	0x0c, 0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x41, 0x64, 0x64, 0x72, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0c, 0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x41, 0x64, 0x64,
+	0x72, 0x22, 0x5b, 0x0a, 0x0f, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68,
+	0x52, 0x65, 0x73, 0x70, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x0a, 0x08,
+	0x65, 0x72, 0x72, 0x6f, 0x72, 0x4d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x65, 0x72, 0x72, 0x6f, 0x72, 0x4d, 0x73, 0x67, 0x12, 0x14, 0x0a, 0x05, 0x6a, 0x6f, 0x62, 0x49,
+	0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6a, 0x6f, 0x62, 0x49, 0x64, 0x22, 0x53,
+	0x0a, 0x10, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x45, 0x76, 0x65,
+	0x6e, 0x74, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x67, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6c, 0x6f, 0x67, 0x12, 0x2d, 0x0a, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42, 0x75, 0x69, 0x6c,
+	0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x52, 0x06, 0x72, 0x65, 0x73,
+	0x75, 0x6c, 0x74, 0x22, 0x61, 0x0a, 0x09, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x52, 0x65, 0x71,
+	0x12, 0x20, 0x0a, 0x05, 0x72, 0x65, 0x70, 0x6f, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x0a, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x52, 0x65, 0x70, 0x6f, 0x52, 0x05, 0x72, 0x65, 0x70,
+	0x6f, 0x73, 0x12, 0x32, 0x0a, 0x0b, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74,
+	0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44,
+	0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x52, 0x0b, 0x64, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x22, 0x99, 0x01, 0x0a, 0x0a, 0x44, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x52, 0x65, 0x73, 0x70, 0x12, 0x3a, 0x0a, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65,
+	0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44,
+	0x65, 0x70, 0x6c, 0x6f, 0x79, 0x52, 0x65, 0x73, 0x70, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73,
+	0x65, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65,
+	0x73, 0x1a, 0x4f, 0x0a, 0x0d, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x65, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x28, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f,
+	0x79, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02,
+	0x38, 0x01, 0x22, 0x42, 0x0a, 0x0c, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x4d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x65, 0x72,
+	0x72, 0x6f, 0x72, 0x4d, 0x73, 0x67, 0x22, 0x6d, 0x0a, 0x0b, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79,
+	0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x22, 0x0a, 0x0c, 0x64, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x6d,
+	0x65, 0x6e, 0x74, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0c, 0x64, 0x65, 0x70,
+	0x6c, 0x6f, 0x79, 0x6d, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x10, 0x0a, 0x03, 0x6c, 0x6f, 0x67,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6c, 0x6f, 0x67, 0x12, 0x28, 0x0a, 0x06, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x10, 0x2e, 0x68, 0x6f,
+	0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x52, 0x65, 0x73, 0x70, 0x52, 0x06, 0x72,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x22, 0x44, 0x0a, 0x10, 0x43, 0x6c, 0x65, 0x61, 0x6e, 0x42, 0x72,
+	0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x52, 0x65, 0x71, 0x12, 0x10, 0x0a, 0x03, 0x69, 0x64, 0x73,
+	0x18, 0x01, 0x20, 0x03, 0x28, 0x04, 0x52, 0x03, 0x69, 0x64, 0x73, 0x12, 0x1e, 0x0a, 0x04, 0x72,
+	0x65, 0x70, 0x6f, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x0a, 0x2e, 0x68, 0x6f, 0x6f, 0x6b,
+	0x2e, 0x52, 0x65, 0x70, 0x6f, 0x52, 0x04, 0x72, 0x65, 0x70, 0x6f, 0x22, 0x0a, 0x0a, 0x08, 0x45,
+	0x6d, 0x70, 0x74, 0x79, 0x4d, 0x73, 0x67, 0x22, 0xb2, 0x01, 0x0a, 0x0b, 0x42, 0x75, 0x69, 0x6c,
+	0x64, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x12, 0x1a, 0x0a, 0x08, 0x62, 0x72, 0x61, 0x6e, 0x63,
+	0x68, 0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04, 0x52, 0x08, 0x62, 0x72, 0x61, 0x6e, 0x63,
+	0x68, 0x49, 0x64, 0x12, 0x14, 0x0a, 0x05, 0x6a, 0x6f, 0x62, 0x49, 0x64, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x6a, 0x6f, 0x62, 0x49, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f,
+	0x67, 0x72, 0x65, 0x73, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x70, 0x72, 0x6f,
+	0x67, 0x72, 0x65, 0x73, 0x73, 0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x67, 0x65, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x74, 0x61, 0x67, 0x65, 0x12, 0x10, 0x0a, 0x03, 0x6c,
+	0x6f, 0x67, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6c, 0x6f, 0x67, 0x12, 0x2d, 0x0a,
+	0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e,
+	0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68,
+	0x52, 0x65, 0x73, 0x70, 0x52, 0x06, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x32, 0xad, 0x02, 0x0a,
+	0x04, 0x48, 0x6f, 0x6f, 0x6b, 0x12, 0x3c, 0x0a, 0x0b, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72,
+	0x61, 0x6e, 0x63, 0x68, 0x12, 0x14, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42, 0x75, 0x69, 0x6c,
+	0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x52, 0x65, 0x71, 0x1a, 0x15, 0x2e, 0x68, 0x6f, 0x6f,
+	0x6b, 0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x52, 0x65, 0x73,
+	0x70, 0x22, 0x00, 0x12, 0x2d, 0x0a, 0x06, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x12, 0x0f, 0x2e,
+	0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x52, 0x65, 0x71, 0x1a, 0x10,
+	0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70, 0x6c, 0x6f, 0x79, 0x52, 0x65, 0x73, 0x70,
+	0x22, 0x00, 0x12, 0x39, 0x0a, 0x0d, 0x43, 0x6c, 0x65, 0x61, 0x6e, 0x42, 0x72, 0x61, 0x6e, 0x63,
+	0x68, 0x65, 0x73, 0x12, 0x16, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x43, 0x6c, 0x65, 0x61, 0x6e,
+	0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x65, 0x73, 0x52, 0x65, 0x71, 0x1a, 0x0e, 0x2e, 0x68, 0x6f,
+	0x6f, 0x6b, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x4d, 0x73, 0x67, 0x22, 0x00, 0x12, 0x45, 0x0a,
+	0x11, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e,
+	0x63, 0x68, 0x12, 0x14, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x42,
+	0x72, 0x61, 0x6e, 0x63, 0x68, 0x52, 0x65, 0x71, 0x1a, 0x16, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e,
+	0x42, 0x75, 0x69, 0x6c, 0x64, 0x42, 0x72, 0x61, 0x6e, 0x63, 0x68, 0x45, 0x76, 0x65, 0x6e, 0x74,
+	0x22, 0x00, 0x30, 0x01, 0x12, 0x36, 0x0a, 0x0c, 0x53, 0x74, 0x72, 0x65, 0x61, 0x6d, 0x44, 0x65,
+	0x70, 0x6c, 0x6f, 0x79, 0x12, 0x0f, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70, 0x6c,
+	0x6f, 0x79, 0x52, 0x65, 0x71, 0x1a, 0x11, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x44, 0x65, 0x70,
+	0x6c, 0x6f, 0x79, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x22, 0x00, 0x30, 0x01, 0x32, 0x3e, 0x0a, 0x08,
+	0x43, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x12, 0x32, 0x0a, 0x0b, 0x52, 0x65, 0x70, 0x6f,
+	0x72, 0x74, 0x42, 0x75, 0x69, 0x6c, 0x64, 0x12, 0x11, 0x2e, 0x68, 0x6f, 0x6f, 0x6b, 0x2e, 0x42,
+	0x75, 0x69, 0x6c, 0x64, 0x52, 0x65, 0x70, 0x6f, 0x72, 0x74, 0x1a, 0x0e, 0x2e, 0x68, 0x6f, 0x6f,
+	0x6b, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x4d, 0x73, 0x67, 0x22, 0x00, 0x42, 0x08, 0x5a, 0x06,
+	0x2e, 0x3b, 0x68, 0x6f, 0x6f, 0x6b, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -666,41 +1058,56 @@ func file_hook_proto_rawDescGZIP() []byte {
 	return file_hook_proto_rawDescData
 }
 
-var file_hook_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_hook_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
 var file_hook_proto_goTypes = []interface{}{
 	(*Repo)(nil),             // 0: hook.Repo
 	(*Branch)(nil),           // 1: hook.Branch
-	(*Deployment)(nil),       // 2: hook.Deployment
-	(*BuildBranchReq)(nil),   // 3: hook.BuildBranchReq
-	(*BuildBranchResp)(nil),  // 4: hook.BuildBranchResp
-	(*DeployReq)(nil),        // 5: hook.DeployReq
-	(*DeployResp)(nil),       // 6: hook.DeployResp
-	(*DeployStatus)(nil),     // 7: hook.DeployStatus
-	(*CleanBranchesReq)(nil), // 8: hook.CleanBranchesReq
-	(*EmptyMsg)(nil),         // 9: hook.EmptyMsg
-	nil,                      // 10: hook.Deployment.BranchesEntry
-	nil,                      // 11: hook.DeployResp.StatusesEntry
+	(*Commit)(nil),           // 2: hook.Commit
+	(*Deployment)(nil),       // 3: hook.Deployment
+	(*BuildBranchReq)(nil),   // 4: hook.BuildBranchReq
+	(*BuildBranchResp)(nil),  // 5: hook.BuildBranchResp
+	(*BuildBranchEvent)(nil), // 6: hook.BuildBranchEvent
+	(*DeployReq)(nil),        // 7: hook.DeployReq
+	(*DeployResp)(nil),       // 8: hook.DeployResp
+	(*DeployStatus)(nil),     // 9: hook.DeployStatus
+	(*DeployEvent)(nil),      // 10: hook.DeployEvent
+	(*CleanBranchesReq)(nil), // 11: hook.CleanBranchesReq
+	(*EmptyMsg)(nil),         // 12: hook.EmptyMsg
+	(*BuildReport)(nil),      // 13: hook.BuildReport
+	nil,                      // 14: hook.Deployment.BranchesEntry
+	nil,                      // 15: hook.DeployResp.StatusesEntry
 }
 var file_hook_proto_depIdxs = []int32{
-	10, // 0: hook.Deployment.branches:type_name -> hook.Deployment.BranchesEntry
+	14, // 0: hook.Deployment.branches:type_name -> hook.Deployment.BranchesEntry
 	0,  // 1: hook.BuildBranchReq.repo:type_name -> hook.Repo
 	1,  // 2: hook.BuildBranchReq.branch:type_name -> hook.Branch
-	0,  // 3: hook.DeployReq.repos:type_name -> hook.Repo
-	2,  // 4: hook.DeployReq.deployments:type_name -> hook.Deployment
-	11, // 5: hook.DeployResp.statuses:type_name -> hook.DeployResp.StatusesEntry
-	1,  // 6: hook.Deployment.BranchesEntry.value:type_name -> hook.Branch
-	7,  // 7: hook.DeployResp.StatusesEntry.value:type_name -> hook.DeployStatus
-	3,  // 8: hook.Hook.BuildBranch:input_type -> hook.BuildBranchReq
-	5,  // 9: hook.Hook.Deploy:input_type -> hook.DeployReq
-	8,  // 10: hook.Hook.CleanBranches:input_type -> hook.CleanBranchesReq
-	4,  // 11: hook.Hook.BuildBranch:output_type -> hook.BuildBranchResp
-	6,  // 12: hook.Hook.Deploy:output_type -> hook.DeployResp
-	9,  // 13: hook.Hook.CleanBranches:output_type -> hook.EmptyMsg
-	11, // [11:14] is the sub-list for method output_type
-	8,  // [8:11] is the sub-list for method input_type
-	8,  // [8:8] is the sub-list for extension type_name
-	8,  // [8:8] is the sub-list for extension extendee
-	0,  // [0:8] is the sub-list for field type_name
+	2,  // 3: hook.BuildBranchReq.commit:type_name -> hook.Commit
+	5,  // 4: hook.BuildBranchEvent.result:type_name -> hook.BuildBranchResp
+	0,  // 5: hook.DeployReq.repos:type_name -> hook.Repo
+	3,  // 6: hook.DeployReq.deployments:type_name -> hook.Deployment
+	15, // 7: hook.DeployResp.statuses:type_name -> hook.DeployResp.StatusesEntry
+	8,  // 8: hook.DeployEvent.result:type_name -> hook.DeployResp
+	0,  // 9: hook.CleanBranchesReq.repo:type_name -> hook.Repo
+	5,  // 10: hook.BuildReport.result:type_name -> hook.BuildBranchResp
+	1,  // 11: hook.Deployment.BranchesEntry.value:type_name -> hook.Branch
+	9,  // 12: hook.DeployResp.StatusesEntry.value:type_name -> hook.DeployStatus
+	4,  // 13: hook.Hook.BuildBranch:input_type -> hook.BuildBranchReq
+	7,  // 14: hook.Hook.Deploy:input_type -> hook.DeployReq
+	11, // 15: hook.Hook.CleanBranches:input_type -> hook.CleanBranchesReq
+	4,  // 16: hook.Hook.StreamBuildBranch:input_type -> hook.BuildBranchReq
+	7,  // 17: hook.Hook.StreamDeploy:input_type -> hook.DeployReq
+	13, // 18: hook.Callback.ReportBuild:input_type -> hook.BuildReport
+	5,  // 19: hook.Hook.BuildBranch:output_type -> hook.BuildBranchResp
+	8,  // 20: hook.Hook.Deploy:output_type -> hook.DeployResp
+	12, // 21: hook.Hook.CleanBranches:output_type -> hook.EmptyMsg
+	6,  // 22: hook.Hook.StreamBuildBranch:output_type -> hook.BuildBranchEvent
+	10, // 23: hook.Hook.StreamDeploy:output_type -> hook.DeployEvent
+	12, // 24: hook.Callback.ReportBuild:output_type -> hook.EmptyMsg
+	19, // [19:25] is the sub-list for method output_type
+	13, // [13:19] is the sub-list for method input_type
+	13, // [13:13] is the sub-list for extension type_name
+	13, // [13:13] is the sub-list for extension extendee
+	0,  // [0:13] is the sub-list for field type_name
 }
 
 func init() { file_hook_proto_init() }
@@ -734,7 +1141,7 @@ func file_hook_proto_init() {
 			}
 		}
 		file_hook_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*Deployment); i {
+			switch v := v.(*Commit); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -746,7 +1153,7 @@ func file_hook_proto_init() {
 			}
 		}
 		file_hook_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*BuildBranchReq); i {
+			switch v := v.(*Deployment); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -758,7 +1165,7 @@ func file_hook_proto_init() {
 			}
 		}
 		file_hook_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*BuildBranchResp); i {
+			switch v := v.(*BuildBranchReq); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -770,7 +1177,7 @@ func file_hook_proto_init() {
 			}
 		}
 		file_hook_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DeployReq); i {
+			switch v := v.(*BuildBranchResp); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -782,7 +1189,7 @@ func file_hook_proto_init() {
 			}
 		}
 		file_hook_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DeployResp); i {
+			switch v := v.(*BuildBranchEvent); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -794,7 +1201,7 @@ func file_hook_proto_init() {
 			}
 		}
 		file_hook_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*DeployStatus); i {
+			switch v := v.(*DeployReq); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -806,7 +1213,7 @@ func file_hook_proto_init() {
 			}
 		}
 		file_hook_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
-			switch v := v.(*CleanBranchesReq); i {
+			switch v := v.(*DeployResp); i {
 			case 0:
 				return &v.state
 			case 1:
@@ -818,6 +1225,42 @@ func file_hook_proto_init() {
 			}
 		}
 		file_hook_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeployStatus); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_hook_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DeployEvent); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_hook_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CleanBranchesReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_hook_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*EmptyMsg); i {
 			case 0:
 				return &v.state
@@ -829,6 +1272,18 @@ func file_hook_proto_init() {
 				return nil
 			}
 		}
+		file_hook_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BuildReport); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -836,9 +1291,9 @@ func file_hook_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_hook_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   12,
+			NumMessages:   16,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_hook_proto_goTypes,
 		DependencyIndexes: file_hook_proto_depIdxs,
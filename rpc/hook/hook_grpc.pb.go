@@ -21,6 +21,10 @@ type HookClient interface {
 	BuildBranch(ctx context.Context, in *BuildBranchReq, opts ...grpc.CallOption) (*BuildBranchResp, error)
 	Deploy(ctx context.Context, in *DeployReq, opts ...grpc.CallOption) (*DeployResp, error)
 	CleanBranches(ctx context.Context, in *CleanBranchesReq, opts ...grpc.CallOption) (*EmptyMsg, error)
+	// StreamBuildBranch is BuildBranch that streams the build output, the handler may leave it unimplemented.
+	StreamBuildBranch(ctx context.Context, in *BuildBranchReq, opts ...grpc.CallOption) (Hook_StreamBuildBranchClient, error)
+	// StreamDeploy is Deploy that streams the deployment output, the handler may leave it unimplemented.
+	StreamDeploy(ctx context.Context, in *DeployReq, opts ...grpc.CallOption) (Hook_StreamDeployClient, error)
 }
 
 type hookClient struct {
@@ -58,6 +62,70 @@ func (c *hookClient) CleanBranches(ctx context.Context, in *CleanBranchesReq, op
 	return out, nil
 }
 
+func (c *hookClient) StreamBuildBranch(ctx context.Context, in *BuildBranchReq, opts ...grpc.CallOption) (Hook_StreamBuildBranchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Hook_ServiceDesc.Streams[0], "/hook.Hook/StreamBuildBranch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hookStreamBuildBranchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Hook_StreamBuildBranchClient interface {
+	Recv() (*BuildBranchEvent, error)
+	grpc.ClientStream
+}
+
+type hookStreamBuildBranchClient struct {
+	grpc.ClientStream
+}
+
+func (x *hookStreamBuildBranchClient) Recv() (*BuildBranchEvent, error) {
+	m := new(BuildBranchEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *hookClient) StreamDeploy(ctx context.Context, in *DeployReq, opts ...grpc.CallOption) (Hook_StreamDeployClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Hook_ServiceDesc.Streams[1], "/hook.Hook/StreamDeploy", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hookStreamDeployClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Hook_StreamDeployClient interface {
+	Recv() (*DeployEvent, error)
+	grpc.ClientStream
+}
+
+type hookStreamDeployClient struct {
+	grpc.ClientStream
+}
+
+func (x *hookStreamDeployClient) Recv() (*DeployEvent, error) {
+	m := new(DeployEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // HookServer is the server API for Hook service.
 // All implementations must embed UnimplementedHookServer
 // for forward compatibility
@@ -65,6 +133,10 @@ type HookServer interface {
 	BuildBranch(context.Context, *BuildBranchReq) (*BuildBranchResp, error)
 	Deploy(context.Context, *DeployReq) (*DeployResp, error)
 	CleanBranches(context.Context, *CleanBranchesReq) (*EmptyMsg, error)
+	// StreamBuildBranch is BuildBranch that streams the build output, the handler may leave it unimplemented.
+	StreamBuildBranch(*BuildBranchReq, Hook_StreamBuildBranchServer) error
+	// StreamDeploy is Deploy that streams the deployment output, the handler may leave it unimplemented.
+	StreamDeploy(*DeployReq, Hook_StreamDeployServer) error
 	mustEmbedUnimplementedHookServer()
 }
 
@@ -81,6 +153,12 @@ func (UnimplementedHookServer) Deploy(context.Context, *DeployReq) (*DeployResp,
 func (UnimplementedHookServer) CleanBranches(context.Context, *CleanBranchesReq) (*EmptyMsg, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method CleanBranches not implemented")
 }
+func (UnimplementedHookServer) StreamBuildBranch(*BuildBranchReq, Hook_StreamBuildBranchServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamBuildBranch not implemented")
+}
+func (UnimplementedHookServer) StreamDeploy(*DeployReq, Hook_StreamDeployServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamDeploy not implemented")
+}
 func (UnimplementedHookServer) mustEmbedUnimplementedHookServer() {}
 
 // UnsafeHookServer may be embedded to opt out of forward compatibility for this service.
@@ -148,6 +226,48 @@ func _Hook_CleanBranches_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Hook_StreamBuildBranch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(BuildBranchReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(HookServer).StreamBuildBranch(m, &hookStreamBuildBranchServer{stream})
+}
+
+type Hook_StreamBuildBranchServer interface {
+	Send(*BuildBranchEvent) error
+	grpc.ServerStream
+}
+
+type hookStreamBuildBranchServer struct {
+	grpc.ServerStream
+}
+
+func (x *hookStreamBuildBranchServer) Send(m *BuildBranchEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Hook_StreamDeploy_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DeployReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(HookServer).StreamDeploy(m, &hookStreamDeployServer{stream})
+}
+
+type Hook_StreamDeployServer interface {
+	Send(*DeployEvent) error
+	grpc.ServerStream
+}
+
+type hookStreamDeployServer struct {
+	grpc.ServerStream
+}
+
+func (x *hookStreamDeployServer) Send(m *DeployEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // Hook_ServiceDesc is the grpc.ServiceDesc for Hook service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -168,6 +288,103 @@ var Hook_ServiceDesc = grpc.ServiceDesc{
 			Handler:    _Hook_CleanBranches_Handler,
 		},
 	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamBuildBranch",
+			Handler:       _Hook_StreamBuildBranch_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamDeploy",
+			Handler:       _Hook_StreamDeploy_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "hook.proto",
+}
+
+// CallbackClient is the client API for Callback service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CallbackClient interface {
+	ReportBuild(ctx context.Context, in *BuildReport, opts ...grpc.CallOption) (*EmptyMsg, error)
+}
+
+type callbackClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCallbackClient(cc grpc.ClientConnInterface) CallbackClient {
+	return &callbackClient{cc}
+}
+
+func (c *callbackClient) ReportBuild(ctx context.Context, in *BuildReport, opts ...grpc.CallOption) (*EmptyMsg, error) {
+	out := new(EmptyMsg)
+	err := c.cc.Invoke(ctx, "/hook.Callback/ReportBuild", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CallbackServer is the server API for Callback service.
+// All implementations must embed UnimplementedCallbackServer
+// for forward compatibility
+type CallbackServer interface {
+	ReportBuild(context.Context, *BuildReport) (*EmptyMsg, error)
+	mustEmbedUnimplementedCallbackServer()
+}
+
+// UnimplementedCallbackServer must be embedded to have forward compatible implementations.
+type UnimplementedCallbackServer struct {
+}
+
+func (UnimplementedCallbackServer) ReportBuild(context.Context, *BuildReport) (*EmptyMsg, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportBuild not implemented")
+}
+func (UnimplementedCallbackServer) mustEmbedUnimplementedCallbackServer() {}
+
+// UnsafeCallbackServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CallbackServer will
+// result in compilation errors.
+type UnsafeCallbackServer interface {
+	mustEmbedUnimplementedCallbackServer()
+}
+
+func RegisterCallbackServer(s grpc.ServiceRegistrar, srv CallbackServer) {
+	s.RegisterService(&Callback_ServiceDesc, srv)
+}
+
+func _Callback_ReportBuild_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BuildReport)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallbackServer).ReportBuild(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/hook.Callback/ReportBuild",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallbackServer).ReportBuild(ctx, req.(*BuildReport))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Callback_ServiceDesc is the grpc.ServiceDesc for Callback service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Callback_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "hook.Callback",
+	HandlerType: (*CallbackServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReportBuild",
+			Handler:    _Callback_ReportBuild_Handler,
+		},
+	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "hook.proto",
 }